@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jcjc-dev/ipwhere/internal/enrich"
+	"github.com/jcjc-dev/ipwhere/internal/extract"
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+	"github.com/jcjc-dev/ipwhere/internal/mailtrace"
+	"github.com/jcjc-dev/ipwhere/internal/report"
+)
+
+// runCLI dispatches CLI mode. The first argument is either a subcommand or
+// an IP address to look up.
+func runCLI(geoReader *geo.Reader, args []string, asOf string) {
+	switch args[0] {
+	case "distance":
+		runDistance(geoReader, args[1:])
+	case "host":
+		runHost(geoReader, args[1:])
+	case "trace-email":
+		runTraceEmail(geoReader, args[1:])
+	case "extract":
+		runExtract(geoReader, args[1:])
+	case "enrich":
+		runEnrich(geoReader, args[1:])
+	case "report":
+		runReport(geoReader, args[1:])
+	default:
+		runLookup(geoReader, args[0], asOf)
+	}
+}
+
+// runLookup performs a direct IP lookup and prints the result as JSON.
+// If asOf is set, the newest database built on or before that date is used.
+func runLookup(geoReader *geo.Reader, ipStr, asOf string) {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		fatalf("invalid IP address: %s (use 'ipwhere --online host %s' to resolve hostnames)", ipStr, ipStr)
+	}
+
+	var info *geo.IPInfo
+	var err error
+	if asOf != "" {
+		date, parseErr := time.Parse(time.DateOnly, asOf)
+		if parseErr != nil {
+			fatalf("invalid --as-of date, expected YYYY-MM-DD: %s", asOf)
+		}
+		info, err = geoReader.LookupAsOf(context.Background(), ip, date)
+	} else {
+		info, err = geoReader.Lookup(context.Background(), ip)
+	}
+	if err != nil {
+		fatalf("lookup failed: %v", err)
+	}
+
+	printJSON(info)
+}
+
+// runDistance measures the distance between an IP address and another IP
+// address or a lat,lon pair
+func runDistance(geoReader *geo.Reader, args []string) {
+	if len(args) != 2 {
+		fatalf("usage: ipwhere distance <from-ip> <to-ip|lat,lon>")
+	}
+	if net.ParseIP(args[0]) == nil {
+		fatalf("invalid IP address: %s", args[0])
+	}
+
+	from, err := geo.ResolvePoint(context.Background(), geoReader, args[0])
+	if err != nil {
+		fatalf("%v", err)
+	}
+	to, err := geo.ResolvePoint(context.Background(), geoReader, args[1])
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	printJSON(geo.Measure(from, to, time.Now()))
+}
+
+// runHost resolves a hostname and geolocates each of its addresses
+func runHost(geoReader *geo.Reader, args []string) {
+	fs := flag.NewFlagSet("host", flag.ExitOnError)
+	cname := fs.Bool("cname", false, "Report the canonical name the host is an alias for")
+	mx := fs.Bool("mx", false, "Also resolve and geolocate the mail exchangers")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fatalf("usage: ipwhere --online host [--cname] [--mx] <hostname>")
+	}
+
+	result, err := geoReader.LookupHost(context.Background(), fs.Arg(0), geo.HostOptions{CNAME: *cname, MX: *mx})
+	switch {
+	case errors.Is(err, geo.ErrOnlineFeaturesDisabled):
+		fatalf("host lookups require --online")
+	case err != nil:
+		fatalf("%s: %v", fs.Arg(0), err)
+	}
+
+	printJSON(result)
+}
+
+// runTraceEmail traces the Received headers of an email read from a file or
+// standard input
+func runTraceEmail(geoReader *geo.Reader, args []string) {
+	in := openInput(args, "usage: ipwhere trace-email [file|-]")
+	defer in.Close()
+
+	trace, err := mailtrace.Parse(in)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if err := trace.Geolocate(context.Background(), geoReader); err != nil {
+		fatalf("lookup failed: %v", err)
+	}
+
+	printJSON(trace)
+}
+
+// runExtract finds, deduplicates and geolocates the IP addresses in a file or
+// standard input
+func runExtract(geoReader *geo.Reader, args []string) {
+	in := openInput(args, "usage: ipwhere extract [file|-]")
+	defer in.Close()
+
+	text, err := io.ReadAll(in)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	result, err := extract.Analyze(context.Background(), geoReader, string(text), 0)
+	if err != nil {
+		fatalf("lookup failed: %v", err)
+	}
+
+	printJSON(result)
+}
+
+// runEnrich adds geolocation fields to the records of a log, JSON lines or
+// CSV file and writes them to stdout in the same format
+func runEnrich(geoReader *geo.Reader, args []string) {
+	fs := flag.NewFlagSet("enrich", flag.ExitOnError)
+	format := fs.String("format", enrich.Combined, "Input format: combined (nginx, Apache), common (Apache), jsonl or csv")
+	ipField := fs.String("field", enrich.DefaultIPField, "JSON field (dotted path for nested objects) or CSV column holding the IP address")
+	fields := fs.String("fields", strings.Join(enrich.DefaultFields, ","), "Comma-separated geolocation fields to add")
+	workers := fs.Int("workers", 0, "Concurrent lookup workers (default: number of CPUs)")
+	fs.Parse(args)
+
+	in := openInput(fs.Args(), "usage: ipwhere enrich [--format F] [--field NAME] [--fields LIST] [--workers N] [file|-]")
+	defer in.Close()
+
+	var selected []string
+	for _, f := range strings.Split(*fields, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			selected = append(selected, f)
+		}
+	}
+
+	stats, err := enrich.Run(context.Background(), geoReader, in, os.Stdout, enrich.Options{
+		Format:  *format,
+		IPField: *ipField,
+		Fields:  selected,
+		Workers: *workers,
+	})
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Enriched %d of %d records (%d skipped)\n", stats.Enriched, stats.Records, stats.Skipped)
+}
+
+// runReport summarises the traffic in a list of addresses or a log file by
+// country, region, AS, network and network type
+func runReport(geoReader *geo.Reader, args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	format := fs.String("format", report.Table, "Output format: table, json, csv or html")
+	top := fs.Int("top", 10, "Entries to show per grouping (0 = all)")
+	fs.Parse(args)
+
+	if !slices.Contains(report.Formats, *format) {
+		fatalf("unknown format %q (want table, json, csv or html)", *format)
+	}
+
+	in := openInput(fs.Args(), "usage: ipwhere report [--format table|json|csv|html] [--top N] [file|-]")
+	defer in.Close()
+
+	result, err := report.Build(context.Background(), geoReader, in)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if err := report.Write(os.Stdout, result, *format, *top); err != nil {
+		fatalf("%v", err)
+	}
+}
+
+// openInput opens the file named by the only argument, or standard input if
+// there is none or it is "-"
+func openInput(args []string, usage string) io.ReadCloser {
+	if len(args) > 1 {
+		fatalf("%s", usage)
+	}
+	if len(args) == 0 || args[0] == "-" {
+		return io.NopCloser(os.Stdin)
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		fatalf("%v", err)
+	}
+	return f
+}
+
+// printJSON prints v as indented JSON to stdout
+func printJSON(v interface{}) {
+	output, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fatalf("failed to format output: %v", err)
+	}
+
+	fmt.Println(string(output))
+}
+
+// fatalf prints an error message to stderr and exits
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "Error: "+format+"\n", args...)
+	os.Exit(1)
+}
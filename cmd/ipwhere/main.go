@@ -17,20 +17,33 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"embed"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
-	"log"
-	"net"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/jcjc-dev/ipwhere/internal/api"
+	"github.com/jcjc-dev/ipwhere/internal/cloud"
 	"github.com/jcjc-dev/ipwhere/internal/geo"
-	"github.com/go-chi/chi/v5"
+	"github.com/jcjc-dev/ipwhere/internal/lists"
+	"github.com/jcjc-dev/ipwhere/internal/netclass"
+	"github.com/jcjc-dev/ipwhere/internal/policy"
+	"github.com/jcjc-dev/ipwhere/internal/privacy"
+	"github.com/jcjc-dev/ipwhere/internal/tlsconfig"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
@@ -38,9 +51,24 @@ import (
 var staticFiles embed.FS
 
 const (
-	defaultListenAddr = ":8080"
+	defaultListenAddr       = ":8080"
+	defaultReloadInterval   = 5 * time.Minute
+	defaultCacheTTL         = time.Hour
+	defaultCacheHostnameTTL = 5 * time.Minute
 )
 
+// stringList is a flag.Value that collects repeated flags
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
 // findDatabasePath searches for a database file in common locations
 func findDatabasePath(filename string) string {
 	execPath, _ := os.Executable()
@@ -59,6 +87,16 @@ func findDatabasePath(filename string) string {
 }
 
 func main() {
+	if err := run(); err != nil {
+		slog.Error("Exiting", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run starts ipwhere in CLI, proxy or server mode. Errors are returned
+// rather than exiting so that deferred cleanup, e.g. closing the databases,
+// always runs.
+func run() error {
 	// Parse command line flags
 	listenAddr := flag.String("l", "", "Address to listen on (default :8080)")
 	flag.StringVar(listenAddr, "listen", "", "Address to listen on (default :8080)")
@@ -72,8 +110,81 @@ func main() {
 	cityDBPath := flag.String("city-db", "", "Path to city MMDB database")
 	asnDBPath := flag.String("asn-db", "", "Path to ASN MMDB database")
 
+	historyDir := flag.String("history-dir", "", "Directory for retained database generations (enables historical lookups)")
+	historyKeep := flag.Int("history-keep", 0, "Maximum number of database generations to retain (0 = unlimited)")
+	historyMaxDays := flag.Int("history-max-days", 0, "Maximum age in days of retained database generations (0 = unlimited)")
+
+	asOf := flag.String("as-of", "", "CLI mode: use the newest database built on or before this date (YYYY-MM-DD)")
+
+	var cloudRangeSpecs stringList
+	flag.Var(&cloudRangeSpecs, "cloud-ranges", "Cloud provider range file as provider=path (repeatable; providers: aws, gcp, azure, oracle, cloudflare)")
+
+	var listSpecs stringList
+	flag.Var(&listSpecs, "list", "Threat list file as name[:category]=path (repeatable; Tor exit lists, Spamhaus DROP/EDROP, netsets, plain IP/CIDR lists)")
+
+	var networkTypeFiles stringList
+	flag.Var(&networkTypeFiles, "network-types", "Network type mapping file extending the bundled ASN mapping (repeatable)")
+
+	policyFile := flag.String("policy-file", "", "JSON file with named allow/deny policies for /api/policy")
+
+	var trustedProxySpecs stringList
+	flag.Var(&trustedProxySpecs, "trusted-proxies", "IP or CIDR of a reverse proxy whose X-Forwarded-For/X-Real-IP headers are trusted (repeatable)")
+
+	rdnsTimeout := flag.Duration("rdns-timeout", 0, "Deadline for reverse DNS lookups including verification (default 2s)")
+	rdnsWorkers := flag.Int("rdns-workers", 0, "Maximum concurrent reverse DNS lookups (default 32)")
+
+	var dnsServers stringList
+	flag.Var(&dnsServers, "dns-server", "DNS server for online features as host[:port] (repeatable; default: system resolver)")
+	dnsProtocol := flag.String("dns-protocol", "", "Protocol for --dns-server: udp, tcp or tls (default udp)")
+	dnsTLSServerName := flag.String("dns-tls-server-name", "", "Server name to verify for DNS over TLS (default: the server host)")
+	dnsPreferGo := flag.Bool("dns-prefer-go", false, "Use the pure-Go resolver instead of the system resolver library")
+
+	cacheSize := flag.Int("cache-size", 0, "Maximum number of networks held in the lookup cache (0 = disabled)")
+	cacheTTL := flag.Duration("cache-ttl", 0, "Lifetime of cached network lookups (default 1h)")
+	cacheHostnameTTL := flag.Duration("cache-hostname-ttl", 0, "Lifetime of cached reverse DNS results (default 5m)")
+
+	reloadInterval := flag.Duration("reload-interval", 0, "Interval for reloading changed databases, range, list and certificate files, 0 disables (default 5m, SIGHUP also reloads)")
+
+	privacyMode := flag.Bool("privacy", false, "Privacy mode: truncate addresses in logs and disable or redact /api/debug")
+	privacyIPv4Prefix := flag.Int("privacy-ipv4-prefix", 0, "Privacy mode: prefix length IPv4 addresses are truncated to (default 24)")
+	privacyIPv6Prefix := flag.Int("privacy-ipv6-prefix", 0, "Privacy mode: prefix length IPv6 addresses are truncated to (default 48)")
+	privacyHashRotation := flag.Duration("privacy-hash-rotation", 0, "Privacy mode: log a salted client hash, rotating the salt at this interval (default: no hashing)")
+	privacyDebug := flag.String("privacy-debug", "", "Privacy mode: /api/debug handling, disabled or redact (default disabled)")
+
+	readTimeout := flag.Duration("read-timeout", 0, "Maximum duration for reading a request including the body (default 30s)")
+	readHeaderTimeout := flag.Duration("read-header-timeout", 0, "Maximum duration for reading request headers (default 10s)")
+	writeTimeout := flag.Duration("write-timeout", 0, "Maximum duration before timing out writes of a response (default 90s)")
+	idleTimeout := flag.Duration("idle-timeout", 0, "Maximum time to wait for the next request on a keep-alive connection (default 2m)")
+	maxHeaderBytes := flag.Int("max-header-bytes", 0, "Maximum size of request headers in bytes (default 65536)")
+	shutdownDrain := flag.Duration("shutdown-drain", 0, "Time to keep serving after SIGTERM with /health failing, before closing the listener (default 0)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 0, "Time allowed for in-flight requests to complete on shutdown (default 30s)")
+
+	tlsCert := flag.String("tls-cert", "", "PEM certificate (chain) file; serves HTTPS when set together with --tls-key")
+	tlsKey := flag.String("tls-key", "", "PEM private key file for --tls-cert")
+	tlsMinVersion := flag.String("tls-min-version", "", "Minimum TLS version: 1.2 or 1.3 (default 1.2)")
+	tlsCiphers := flag.String("tls-ciphers", "", "Comma-separated TLS 1.2 cipher suites (default: Go's secure defaults)")
+	tlsClientCA := flag.String("tls-client-ca", "", "PEM CA bundle; /api/debug, /api/info and /api/lists then require a client certificate issued by it")
+	disableHTTP2 := flag.Bool("disable-http2", false, "Only serve HTTP/1.1 over TLS")
+	h2c := flag.Bool("h2c", false, "Accept HTTP/2 without TLS (h2c with prior knowledge) for internal plaintext deployments")
+
+	logFormat := flag.String("log-format", "", "Log output format: text or json (default text)")
+	logLevel := flag.String("log-level", "", "Minimum log level: debug, info, warn or error (default info)")
+
 	flag.Parse()
 
+	// Configure logging first so that startup errors are structured too
+	if *logFormat == "" {
+		*logFormat = os.Getenv("LOG_FORMAT")
+	}
+	if *logLevel == "" {
+		*logLevel = os.Getenv("LOG_LEVEL")
+	}
+	logger, err := newLogger(os.Stderr, *logFormat, *logLevel)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+
 	// Check environment variables
 	if *listenAddr == "" {
 		*listenAddr = os.Getenv("LISTEN_ADDR")
@@ -108,40 +219,373 @@ func main() {
 	}
 
 	if *cityDBPath == "" || *asnDBPath == "" {
-		log.Fatal("Database files not found. Please provide paths via --city-db and --asn-db flags or CITY_DB_PATH and ASN_DB_PATH environment variables")
+		return errors.New("database files not found, please provide paths via --city-db and --asn-db flags or CITY_DB_PATH and ASN_DB_PATH environment variables")
 	}
 
-	// Check if running in CLI mode (IP argument provided)
+	if *historyDir == "" {
+		*historyDir = os.Getenv("HISTORY_DIR")
+	}
+	if *historyKeep == 0 {
+		*historyKeep = envInt("HISTORY_KEEP")
+	}
+	if *historyMaxDays == 0 {
+		*historyMaxDays = envInt("HISTORY_MAX_DAYS")
+	}
+
+	if len(cloudRangeSpecs) == 0 {
+		cloudRangeSpecs = envList("CLOUD_RANGES")
+	}
+	if len(listSpecs) == 0 {
+		listSpecs = envList("THREAT_LISTS")
+	}
+	if len(networkTypeFiles) == 0 {
+		networkTypeFiles = envList("NETWORK_TYPES")
+	}
+	if *policyFile == "" {
+		*policyFile = os.Getenv("POLICY_FILE")
+	}
+	if len(trustedProxySpecs) == 0 {
+		trustedProxySpecs = envList("TRUSTED_PROXIES")
+	}
+	if *rdnsTimeout == 0 {
+		*rdnsTimeout = envDuration("RDNS_TIMEOUT", geo.DefaultRDNSTimeout)
+	}
+	if *rdnsWorkers == 0 {
+		*rdnsWorkers = envInt("RDNS_WORKERS")
+	}
+	if *rdnsWorkers == 0 {
+		*rdnsWorkers = geo.DefaultRDNSWorkers
+	}
+	if len(dnsServers) == 0 {
+		dnsServers = envList("DNS_SERVERS")
+	}
+	if *dnsProtocol == "" {
+		*dnsProtocol = os.Getenv("DNS_PROTOCOL")
+	}
+	if *dnsTLSServerName == "" {
+		*dnsTLSServerName = os.Getenv("DNS_TLS_SERVER_NAME")
+	}
+	if !*dnsPreferGo {
+		preferGoEnv := os.Getenv("DNS_PREFER_GO")
+		*dnsPreferGo = preferGoEnv == "true" || preferGoEnv == "1"
+	}
+	if *cacheSize == 0 {
+		*cacheSize = envInt("CACHE_SIZE")
+	}
+	if *cacheTTL == 0 {
+		*cacheTTL = envDuration("CACHE_TTL", defaultCacheTTL)
+	}
+	if *cacheHostnameTTL == 0 {
+		*cacheHostnameTTL = envDuration("CACHE_HOSTNAME_TTL", defaultCacheHostnameTTL)
+	}
+	// 0 disables periodic reloads, so the flag can't fall back on its zero value
+	if !isFlagSet(flag.CommandLine, "reload-interval") {
+		*reloadInterval = envDuration("RELOAD_INTERVAL", defaultReloadInterval)
+	}
+	srvCfg := serverConfig{
+		addr:              *listenAddr,
+		readTimeout:       *readTimeout,
+		readHeaderTimeout: *readHeaderTimeout,
+		writeTimeout:      *writeTimeout,
+		idleTimeout:       *idleTimeout,
+		maxHeaderBytes:    *maxHeaderBytes,
+		drainPeriod:       *shutdownDrain,
+		shutdownTimeout:   *shutdownTimeout,
+	}
+	if srvCfg.readTimeout == 0 {
+		srvCfg.readTimeout = envDuration("READ_TIMEOUT", defaultReadTimeout)
+	}
+	if srvCfg.readHeaderTimeout == 0 {
+		srvCfg.readHeaderTimeout = envDuration("READ_HEADER_TIMEOUT", defaultReadHeaderTimeout)
+	}
+	if srvCfg.writeTimeout == 0 {
+		srvCfg.writeTimeout = envDuration("WRITE_TIMEOUT", defaultWriteTimeout)
+	}
+	if srvCfg.idleTimeout == 0 {
+		srvCfg.idleTimeout = envDuration("IDLE_TIMEOUT", defaultIdleTimeout)
+	}
+	if srvCfg.maxHeaderBytes == 0 {
+		srvCfg.maxHeaderBytes = envInt("MAX_HEADER_BYTES")
+	}
+	if srvCfg.maxHeaderBytes == 0 {
+		srvCfg.maxHeaderBytes = defaultMaxHeaderBytes
+	}
+	if srvCfg.drainPeriod == 0 {
+		srvCfg.drainPeriod = envDuration("SHUTDOWN_DRAIN", 0)
+	}
+	if srvCfg.shutdownTimeout == 0 {
+		srvCfg.shutdownTimeout = envDuration("SHUTDOWN_TIMEOUT", defaultShutdownTimeout)
+	}
+	if *tlsCert == "" {
+		*tlsCert = os.Getenv("TLS_CERT_FILE")
+	}
+	if *tlsKey == "" {
+		*tlsKey = os.Getenv("TLS_KEY_FILE")
+	}
+	if *tlsMinVersion == "" {
+		*tlsMinVersion = os.Getenv("TLS_MIN_VERSION")
+	}
+	if *tlsCiphers == "" {
+		*tlsCiphers = os.Getenv("TLS_CIPHERS")
+	}
+	if *tlsClientCA == "" {
+		*tlsClientCA = os.Getenv("TLS_CLIENT_CA")
+	}
+	if !*disableHTTP2 {
+		disableHTTP2Env := os.Getenv("DISABLE_HTTP2")
+		*disableHTTP2 = disableHTTP2Env == "true" || disableHTTP2Env == "1"
+	}
+	if !*h2c {
+		h2cEnv := os.Getenv("H2C")
+		*h2c = h2cEnv == "true" || h2cEnv == "1"
+	}
+	srvCfg.disableHTTP2 = *disableHTTP2
+	srvCfg.h2c = *h2c
+	if !*privacyMode {
+		privacyEnv := os.Getenv("PRIVACY_MODE")
+		*privacyMode = privacyEnv == "true" || privacyEnv == "1"
+	}
+	if *privacyIPv4Prefix == 0 {
+		*privacyIPv4Prefix = envInt("PRIVACY_IPV4_PREFIX")
+	}
+	if *privacyIPv6Prefix == 0 {
+		*privacyIPv6Prefix = envInt("PRIVACY_IPV6_PREFIX")
+	}
+	if *privacyHashRotation == 0 {
+		*privacyHashRotation = envDuration("PRIVACY_HASH_ROTATION", 0)
+	}
+	if *privacyDebug == "" {
+		*privacyDebug = os.Getenv("PRIVACY_DEBUG")
+	}
+
+	var readerOpts []geo.Option
+	var reloadables []reloadable
+	if *historyDir != "" {
+		readerOpts = append(readerOpts, geo.WithHistory(geo.HistoryConfig{
+			Dir:    *historyDir,
+			Keep:   *historyKeep,
+			MaxAge: time.Duration(*historyMaxDays) * 24 * time.Hour,
+		}))
+	}
+	if *enableOnlineFeatures {
+		readerOpts = append(readerOpts, geo.WithRDNS(geo.RDNSConfig{
+			Timeout: *rdnsTimeout,
+			Workers: *rdnsWorkers,
+		}))
+
+		resolver, err := geo.NewResolver(geo.ResolverConfig{
+			Servers:   dnsServers,
+			Protocol:  *dnsProtocol,
+			PreferGo:  *dnsPreferGo,
+			TLSConfig: &tls.Config{ServerName: *dnsTLSServerName},
+		})
+		if err != nil {
+			return fmt.Errorf("invalid DNS resolver configuration: %w", err)
+		}
+		readerOpts = append(readerOpts, geo.WithResolver(resolver))
+	}
+	if *cacheSize > 0 {
+		readerOpts = append(readerOpts, geo.WithCache(geo.CacheConfig{
+			Size:        *cacheSize,
+			TTL:         *cacheTTL,
+			HostnameTTL: *cacheHostnameTTL,
+		}))
+	}
+
+	// Check if running in CLI mode (IP argument provided) or proxy mode
 	args := flag.Args()
-	cliMode := len(args) > 0
+	proxyMode := len(args) > 0 && args[0] == "proxy"
+	cliMode := len(args) > 0 && !proxyMode
+
+	if len(cloudRangeSpecs) > 0 {
+		var sources []cloud.Source
+		for _, spec := range cloudRangeSpecs {
+			src, err := cloud.ParseSource(spec)
+			if err != nil {
+				return fmt.Errorf("invalid --cloud-ranges: %w", err)
+			}
+			sources = append(sources, src)
+		}
+		ranges, err := cloud.Load(sources)
+		if err != nil {
+			return fmt.Errorf("failed to load cloud ranges: %w", err)
+		}
+		readerOpts = append(readerOpts, geo.WithCloudRanges(ranges))
+		reloadables = append(reloadables, reloadable{name: "cloud ranges", reload: ranges.Reload})
+		if !cliMode {
+			for _, src := range ranges.Sources() {
+				slog.Info("Loaded cloud ranges", "provider", src.Provider, "prefixes", src.Prefixes, "file", src.File)
+			}
+		}
+	}
+
+	if len(listSpecs) > 0 {
+		var specs []lists.Spec
+		for _, spec := range listSpecs {
+			ls, err := lists.ParseSpec(spec)
+			if err != nil {
+				return fmt.Errorf("invalid --list: %w", err)
+			}
+			specs = append(specs, ls)
+		}
+		set, err := lists.Load(specs)
+		if err != nil {
+			return fmt.Errorf("failed to load threat lists: %w", err)
+		}
+		readerOpts = append(readerOpts, geo.WithLists(set))
+		reloadables = append(reloadables, reloadable{name: "threat lists", reload: set.Reload})
+		if !cliMode {
+			for _, li := range set.Lists() {
+				slog.Info("Loaded threat list", "list", li.Name, "category", li.Category, "entries", li.Entries, "file", li.File)
+			}
+		}
+	}
+
+	if len(networkTypeFiles) > 0 {
+		classifier, err := netclass.Load(networkTypeFiles...)
+		if err != nil {
+			return fmt.Errorf("failed to load network types: %w", err)
+		}
+		readerOpts = append(readerOpts, geo.WithClassifier(classifier))
+	}
 
 	if !cliMode {
-		log.Printf("Using city database: %s", *cityDBPath)
-		log.Printf("Using ASN database: %s", *asnDBPath)
+		slog.Info("Using databases", "city_db", *cityDBPath, "asn_db", *asnDBPath)
+		if *historyDir != "" {
+			slog.Info("Using history directory", "dir", *historyDir)
+		}
+		if *cacheSize > 0 {
+			slog.Info("Caching lookups", "networks", *cacheSize, "ttl", *cacheTTL)
+		}
 	}
 
 	// Initialize geo reader
-	geoReader, err := geo.NewReader(*cityDBPath, *asnDBPath, *enableOnlineFeatures)
+	geoReader, err := geo.NewReader(*cityDBPath, *asnDBPath, *enableOnlineFeatures, readerOpts...)
 	if err != nil {
 		if cliMode {
 			fmt.Fprintf(os.Stderr, "Error: failed to initialize geo reader: %v\n", err)
 			os.Exit(1)
 		}
-		log.Fatalf("Failed to initialize geo reader: %v", err)
+		return fmt.Errorf("failed to initialize geo reader: %w", err)
 	}
 	defer geoReader.Close()
+	reloadables = append(reloadables, reloadable{name: "databases", reload: geoReader.Reload})
 
 	// CLI mode: lookup the IP and print result
 	if cliMode {
-		runCLI(geoReader, args[0])
-		return
+		runCLI(geoReader, args, *asOf)
+		return nil
+	}
+
+	var engine *policy.Engine
+	if *policyFile != "" {
+		engine, err = policy.Load(*policyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load policies: %w", err)
+		}
+		reloadables = append(reloadables, reloadable{name: "policies", reload: engine.Reload})
+		slog.Info("Loaded policies", "policies", engine.Names(), "file", *policyFile)
+	}
+
+	var trusted api.TrustedProxies
+	if len(trustedProxySpecs) > 0 {
+		trusted, err = api.ParseTrustedProxies(trustedProxySpecs)
+		if err != nil {
+			return fmt.Errorf("invalid --trusted-proxies: %w", err)
+		}
+		slog.Info("Trusting forwarding headers", "proxies", []string(trustedProxySpecs))
+	}
+
+	if *tlsCert != "" || *tlsKey != "" {
+		if *h2c {
+			return errors.New("--h2c only applies to plaintext servers, HTTP/2 is negotiated automatically over TLS")
+		}
+		var ciphers []string
+		for _, c := range strings.Split(*tlsCiphers, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				ciphers = append(ciphers, c)
+			}
+		}
+		tlsCfg, cert, err := tlsconfig.New(tlsconfig.Config{
+			CertFile:     *tlsCert,
+			KeyFile:      *tlsKey,
+			MinVersion:   *tlsMinVersion,
+			CipherSuites: ciphers,
+			ClientCAFile: *tlsClientCA,
+		})
+		if err != nil {
+			return fmt.Errorf("invalid TLS configuration: %w", err)
+		}
+		srvCfg.tlsConfig = tlsCfg
+		reloadables = append(reloadables, reloadable{name: "TLS certificate", reload: cert.Reload})
+		slog.Info("TLS enabled", "cert", *tlsCert, "expires", cert.NotAfter(), "http2", !*disableHTTP2, "client_ca", *tlsClientCA)
+	} else if *tlsClientCA != "" {
+		return errors.New("--tls-client-ca requires --tls-cert and --tls-key")
+	} else if *h2c {
+		slog.Info("Accepting HTTP/2 without TLS (h2c)")
+	}
+
+	var anonymizer *privacy.Anonymizer
+	if *privacyMode {
+		anonymizer, err = privacy.New(privacy.Config{
+			IPv4Prefix:   *privacyIPv4Prefix,
+			IPv6Prefix:   *privacyIPv6Prefix,
+			HashRotation: *privacyHashRotation,
+			Debug:        *privacyDebug,
+		})
+		if err != nil {
+			return fmt.Errorf("invalid privacy configuration: %w", err)
+		}
+		srvCfg.anonymizer = anonymizer
+		cfg := anonymizer.Config()
+		slog.Info("Privacy mode enabled", "ipv4_prefix", cfg.IPv4Prefix, "ipv6_prefix", cfg.IPv6Prefix, "hash_rotation", cfg.HashRotation, "debug", cfg.Debug)
+	}
+
+	// SIGINT and SIGTERM start a graceful shutdown; a second signal exits
+	// immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// Shutdown order: HTTP server (in serve), reloader, then the databases
+	// (deferred above)
+	reloadCtx, stopReloader := context.WithCancel(context.Background())
+	waitReloader := startReloader(reloadCtx, *reloadInterval, reloadables)
+	defer func() {
+		stopReloader()
+		waitReloader()
+		slog.Info("Shutdown complete")
+	}()
+
+	// Proxy mode: forward requests upstream with geolocation headers
+	if proxyMode {
+		return runProxy(ctx, geoReader, args[1:], srvCfg, engine, trusted, logger, anonymizer)
+	}
+
+	var handlerOpts []api.HandlerOption
+	if engine != nil {
+		handlerOpts = append(handlerOpts, api.WithPolicies(engine))
+	}
+	if anonymizer != nil {
+		handlerOpts = append(handlerOpts, api.WithPrivacy(anonymizer))
+	}
+	if *tlsClientCA != "" {
+		handlerOpts = append(handlerOpts, api.WithAdminAuth(api.RequireClientCert))
+	}
+
+	routerOpts := []api.RouterOption{api.WithLogger(logger), api.WithLogAnonymizer(anonymizer)}
+	if len(trusted) > 0 {
+		routerOpts = append(routerOpts, api.WithTrustedProxies(trusted))
 	}
 
 	// Create router
-	r := api.NewRouter()
+	r := api.NewRouter(routerOpts...)
 
 	// Setup API routes
-	handler := api.NewHandler(geoReader, *enableOnlineFeatures)
+	handler := api.NewHandler(geoReader, *enableOnlineFeatures, handlerOpts...)
 	handler.SetupRoutes(r)
 
 	// Setup Swagger
@@ -151,24 +595,24 @@ func main() {
 
 	// Serve frontend if not headless
 	if !*headless {
-		log.Println("Frontend enabled")
-		setupFrontend(r)
+		slog.Info("Frontend enabled")
+		if err := setupFrontend(r); err != nil {
+			return err
+		}
 	} else {
-		log.Println("Running in headless mode (API only)")
+		slog.Info("Running in headless mode (API only)")
 	}
 
 	// Start server
-	log.Printf("Starting server on %s", *listenAddr)
-	if err := http.ListenAndServe(*listenAddr, r); err != nil {
-		log.Fatalf("Server failed: %v", err)
-	}
+	slog.Info("Starting server", "addr", srvCfg.addr, "tls", srvCfg.tlsConfig != nil)
+	return serve(ctx, srvCfg.newServer(r), srvCfg, handler.Drain)
 }
 
-func setupFrontend(r *chi.Mux) {
+func setupFrontend(r *chi.Mux) error {
 	// Get the static subdirectory from embedded files
 	staticFS, err := fs.Sub(staticFiles, "static")
 	if err != nil {
-		log.Fatalf("Failed to get static files: %v", err)
+		return fmt.Errorf("failed to get static files: %w", err)
 	}
 
 	// Serve static files
@@ -193,27 +637,66 @@ func setupFrontend(r *chi.Mux) {
 
 		fileServer.ServeHTTP(w, req)
 	})
+	return nil
 }
 
-// runCLI performs a direct IP lookup and prints the result as JSON
-func runCLI(geoReader *geo.Reader, ipStr string) {
-	ip := net.ParseIP(ipStr)
-	if ip == nil {
-		fmt.Fprintf(os.Stderr, "Error: invalid IP address: %s\n", ipStr)
-		os.Exit(1)
+// newLogger creates a logger writing to w in the given format ("text" or
+// "json", default text) at the given minimum level (default info)
+func newLogger(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q (want debug, info, warn or error)", level)
+		}
 	}
 
-	info, err := geoReader.Lookup(ip)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: lookup failed: %v\n", err)
-		os.Exit(1)
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q (want text or json)", format)
 	}
+}
+
+// isFlagSet reports whether the named flag was given on the command line
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
 
-	output, err := json.MarshalIndent(info, "", "  ")
+// envList reads a comma-separated environment variable
+func envList(name string) []string {
+	var result []string
+	for _, v := range strings.Split(os.Getenv(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// envDuration reads a duration environment variable, returning def if unset or invalid
+func envDuration(name string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(name))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: failed to format output: %v\n", err)
-		os.Exit(1)
+		return def
 	}
+	return d
+}
 
-	fmt.Println(string(output))
+// envInt reads an integer environment variable, returning 0 if unset or invalid
+func envInt(name string) int {
+	n, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return 0
+	}
+	return n
 }
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"flag"
+	"testing"
+	"time"
+)
+
+func TestIsFlagSet(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	interval := fs.Duration("reload-interval", 0, "")
+	fs.Int("cache-size", 0, "")
+	if err := fs.Parse([]string{"--reload-interval", "0"}); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if !isFlagSet(fs, "reload-interval") || *interval != time.Duration(0) {
+		t.Error("expected reload-interval to be set to 0")
+	}
+	if isFlagSet(fs, "cache-size") {
+		t.Error("expected cache-size to be unset")
+	}
+}
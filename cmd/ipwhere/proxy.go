@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jcjc-dev/ipwhere/internal/api"
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+	"github.com/jcjc-dev/ipwhere/internal/policy"
+	"github.com/jcjc-dev/ipwhere/internal/privacy"
+)
+
+// runProxy runs the reverse proxy mode: ipwhere proxy --upstream URL [flags]
+func runProxy(ctx context.Context, geoReader *geo.Reader, args []string, srvCfg serverConfig, engine *policy.Engine, trusted api.TrustedProxies, logger *slog.Logger, anonymizer *privacy.Anonymizer) error {
+	fs := flag.NewFlagSet("proxy", flag.ExitOnError)
+	upstreamStr := fs.String("upstream", "", "Upstream URL to forward requests to")
+	policyName := fs.String("policy", "", "Policy from --policy-file to enforce (denied requests get 403)")
+	var headerSpecs stringList
+	fs.Var(&headerSpecs, "header", "Rename a geolocation header as field=Header-Name, empty name disables it (repeatable; fields: ip, country, city, asn, organization, latitude, longitude, decision, rule)")
+	fs.Parse(args)
+
+	if *upstreamStr == "" {
+		*upstreamStr = os.Getenv("PROXY_UPSTREAM")
+	}
+	if *policyName == "" {
+		*policyName = os.Getenv("PROXY_POLICY")
+	}
+	if len(headerSpecs) == 0 {
+		headerSpecs = envList("PROXY_HEADERS")
+	}
+
+	upstream, err := url.Parse(*upstreamStr)
+	if err != nil || upstream.Scheme == "" || upstream.Host == "" {
+		return fmt.Errorf("proxy mode requires a valid --upstream URL or PROXY_UPSTREAM, got %q", *upstreamStr)
+	}
+
+	headers := api.DefaultGeoHeaders()
+	for _, spec := range headerSpecs {
+		if err := headers.Rename(spec); err != nil {
+			return fmt.Errorf("invalid --header: %w", err)
+		}
+	}
+
+	opts := []api.ProxyOption{
+		api.WithProxyHeaders(headers),
+		api.WithProxyTrustedProxies(trusted),
+	}
+	if *policyName != "" {
+		if engine == nil {
+			return errors.New("proxy --policy requires --policy-file")
+		}
+		if _, ok := engine.Policy(*policyName); !ok {
+			return fmt.Errorf("unknown policy %q", *policyName)
+		}
+		opts = append(opts, api.WithProxyPolicy(engine, *policyName))
+		slog.Info("Enforcing policy", "policy", *policyName)
+	}
+
+	proxy := api.NewProxy(geoReader, upstream, opts...)
+	handler := middleware.RequestID(api.RequestLogger(logger, anonymizer)(proxy))
+
+	slog.Info("Proxying requests", "addr", srvCfg.addr, "upstream", upstream.String())
+	return serve(ctx, srvCfg.newServer(handler), srvCfg, nil)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// reloadable is a file-backed data source that can be refreshed at runtime
+type reloadable struct {
+	name   string
+	reload func() error
+}
+
+// startReloader refreshes the given data sources every interval and whenever
+// the process receives SIGHUP, until ctx is done. An interval of zero
+// disables periodic reloads. The returned function waits for the reloader
+// to stop, including any reload in progress.
+func startReloader(ctx context.Context, interval time.Duration, sources []reloadable) (wait func()) {
+	done := make(chan struct{})
+	if len(sources) == 0 {
+		close(done)
+		return func() { <-done }
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	var ticker *time.Ticker
+	if interval > 0 {
+		ticker = time.NewTicker(interval)
+		tick = ticker.C
+	}
+
+	go func() {
+		defer close(done)
+		defer signal.Stop(hup)
+		if ticker != nil {
+			defer ticker.Stop()
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				slog.Info("Received SIGHUP, reloading data sources")
+			case <-tick:
+			}
+			for _, s := range sources {
+				if err := s.reload(); err != nil {
+					slog.Error("Failed to reload data source", "source", s.name, "error", err)
+				}
+			}
+		}
+	}()
+	return func() { <-done }
+}
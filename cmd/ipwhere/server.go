@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jcjc-dev/ipwhere/internal/privacy"
+)
+
+const (
+	defaultReadTimeout       = 30 * time.Second
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultWriteTimeout      = 90 * time.Second
+	defaultIdleTimeout       = 2 * time.Minute
+	defaultMaxHeaderBytes    = 64 << 10
+	defaultShutdownTimeout   = 30 * time.Second
+)
+
+// serverConfig holds the HTTP server address, protocols, limits and
+// shutdown behaviour
+type serverConfig struct {
+	addr              string
+	tlsConfig         *tls.Config // Serve HTTPS if set
+	disableHTTP2      bool
+	h2c               bool // Accept HTTP/2 without TLS (prior knowledge only)
+	readTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	maxHeaderBytes    int
+	drainPeriod       time.Duration       // Time between the shutdown signal and closing the listener
+	shutdownTimeout   time.Duration       // Time allowed for in-flight requests to complete
+	anonymizer        *privacy.Anonymizer // Truncates addresses in server errors (privacy mode)
+}
+
+// newServer creates an HTTP server for h with the configured protocols and
+// limits
+func (c serverConfig) newServer(h http.Handler) *http.Server {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	if c.tlsConfig != nil {
+		protocols.SetHTTP2(!c.disableHTTP2)
+	} else {
+		protocols.SetUnencryptedHTTP2(c.h2c)
+	}
+
+	return &http.Server{
+		Addr:              c.addr,
+		Handler:           h,
+		TLSConfig:         c.tlsConfig,
+		Protocols:         &protocols,
+		ReadTimeout:       c.readTimeout,
+		ReadHeaderTimeout: c.readHeaderTimeout,
+		WriteTimeout:      c.writeTimeout,
+		IdleTimeout:       c.idleTimeout,
+		MaxHeaderBytes:    c.maxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(c.errorLogHandler(), slog.LevelWarn),
+	}
+}
+
+// errorLogHandler returns the handler for errors logged by net/http, such as
+// "TLS handshake error from <addr>", redacting addresses in privacy mode
+func (c serverConfig) errorLogHandler() slog.Handler {
+	h := slog.Default().Handler()
+	if c.anonymizer == nil {
+		return h
+	}
+	return redactingHandler{Handler: h, anon: c.anonymizer}
+}
+
+// redactingHandler truncates the addresses in log messages
+type redactingHandler struct {
+	slog.Handler
+	anon *privacy.Anonymizer
+}
+
+func (h redactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	r.Message = h.anon.Redact(r.Message)
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return redactingHandler{Handler: h.Handler.WithAttrs(attrs), anon: h.anon}
+}
+
+func (h redactingHandler) WithGroup(name string) slog.Handler {
+	return redactingHandler{Handler: h.Handler.WithGroup(name), anon: h.anon}
+}
+
+// serve runs srv until ctx is done and then shuts it down gracefully.
+// onDrain (if set) is called first and the server keeps accepting requests
+// for the drain period, giving load balancers time to notice failing health
+// checks. The listener is then closed and in-flight requests have the
+// shutdown timeout to complete before their connections are closed.
+func serve(ctx context.Context, srv *http.Server, cfg serverConfig, onDrain func()) error {
+	errc := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			// The certificate comes from TLSConfig.GetCertificate
+			errc <- srv.ListenAndServeTLS("", "")
+		} else {
+			errc <- srv.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down server", "drain", cfg.drainPeriod, "timeout", cfg.shutdownTimeout)
+	if onDrain != nil {
+		onDrain()
+	}
+	if cfg.drainPeriod > 0 {
+		select {
+		case <-time.After(cfg.drainPeriod):
+		case err := <-errc:
+			return err
+		}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("graceful shutdown failed: %w", err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jcjc-dev/ipwhere/internal/privacy"
+)
+
+// freeAddr returns a local address that is free to listen on
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestServeGracefulShutdown(t *testing.T) {
+	cfg := serverConfig{
+		addr:            freeAddr(t),
+		drainPeriod:     100 * time.Millisecond,
+		shutdownTimeout: 5 * time.Second,
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := cfg.newServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+		io.WriteString(w, "done")
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var drained atomic.Bool
+	result := make(chan error, 1)
+	go func() {
+		result <- serve(ctx, srv, cfg, func() { drained.Store(true) })
+	}()
+
+	// Wait for the server to accept connections
+	url := "http://" + cfg.addr
+	for i := 0; ; i++ {
+		if resp, err := http.Get(url + "/"); err == nil {
+			resp.Body.Close()
+			break
+		}
+		if i == 100 {
+			t.Fatal("server did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Start a request that is still in flight when shutdown begins
+	slow := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		slow <- string(body)
+	}()
+	<-started
+	cancel()
+
+	// New requests are still served during the drain period
+	time.Sleep(20 * time.Millisecond)
+	if !drained.Load() {
+		t.Error("onDrain was not called")
+	}
+	resp, err := http.Get(url + "/")
+	if err != nil {
+		t.Fatalf("request during drain failed: %v", err)
+	}
+	resp.Body.Close()
+
+	close(release)
+	if body := <-slow; body != "done" {
+		t.Errorf("in-flight request got %q, want done", body)
+	}
+	if err := <-result; err != nil {
+		t.Errorf("serve() error = %v", err)
+	}
+	if _, err := http.Get(url + "/"); err == nil {
+		t.Error("server still accepting requests after shutdown")
+	}
+}
+
+func TestServeListenError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	cfg := serverConfig{addr: l.Addr().String(), shutdownTimeout: time.Second}
+	if err := serve(context.Background(), cfg.newServer(http.NotFoundHandler()), cfg, nil); err == nil {
+		t.Error("serve() error = nil for an address in use")
+	}
+}
+
+func TestStartReloaderStops(t *testing.T) {
+	var reloads atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	wait := startReloader(ctx, 5*time.Millisecond, []reloadable{{
+		name:   "test",
+		reload: func() error { reloads.Add(1); return nil },
+	}})
+
+	time.Sleep(30 * time.Millisecond)
+	cancel()
+	wait()
+
+	n := reloads.Load()
+	if n == 0 {
+		t.Error("no reloads before cancellation")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if reloads.Load() != n {
+		t.Error("reloads continued after the reloader stopped")
+	}
+}
+
+// selfSignedCert creates a certificate for 127.0.0.1
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, roots
+}
+
+func TestServeProtocols(t *testing.T) {
+	cert, roots := selfSignedCert(t)
+	tlsConfig := func() *tls.Config {
+		return &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	var unencryptedHTTP2 http.Protocols
+	unencryptedHTTP2.SetUnencryptedHTTP2(true)
+
+	tests := []struct {
+		name      string
+		cfg       serverConfig
+		transport *http.Transport
+		wantProto string
+	}{
+		{
+			name:      "TLS negotiates HTTP/2",
+			cfg:       serverConfig{tlsConfig: tlsConfig()},
+			transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}, ForceAttemptHTTP2: true},
+			wantProto: "HTTP/2.0",
+		},
+		{
+			name:      "TLS with HTTP/2 disabled",
+			cfg:       serverConfig{tlsConfig: tlsConfig(), disableHTTP2: true},
+			transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}, ForceAttemptHTTP2: true},
+			wantProto: "HTTP/1.1",
+		},
+		{
+			name:      "h2c",
+			cfg:       serverConfig{h2c: true},
+			transport: &http.Transport{Protocols: &unencryptedHTTP2},
+			wantProto: "HTTP/2.0",
+		},
+		{
+			name:      "plaintext",
+			cfg:       serverConfig{},
+			transport: &http.Transport{},
+			wantProto: "HTTP/1.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.addr = freeAddr(t)
+			tt.cfg.shutdownTimeout = time.Second
+			srv := tt.cfg.newServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, r.Proto)
+			}))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			result := make(chan error, 1)
+			go func() {
+				result <- serve(ctx, srv, tt.cfg, nil)
+			}()
+			defer func() {
+				cancel()
+				if err := <-result; err != nil {
+					t.Errorf("serve() error = %v", err)
+				}
+			}()
+
+			scheme := "http://"
+			if tt.cfg.tlsConfig != nil {
+				scheme = "https://"
+			}
+			client := &http.Client{Transport: tt.transport}
+			defer tt.transport.CloseIdleConnections()
+			for i := 0; ; i++ {
+				resp, err := client.Get(scheme + tt.cfg.addr + "/")
+				if err == nil {
+					body, _ := io.ReadAll(resp.Body)
+					resp.Body.Close()
+					if string(body) != tt.wantProto {
+						t.Errorf("served over %s, want %s", body, tt.wantProto)
+					}
+					break
+				}
+				if i == 100 {
+					t.Fatalf("request failed: %v", err)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestServerErrorLogPrivacy(t *testing.T) {
+	anon, err := privacy.New(privacy.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	for _, cfg := range []serverConfig{{}, {anonymizer: anon}} {
+		buf.Reset()
+		srv := cfg.newServer(http.NotFoundHandler())
+		srv.ErrorLog.Printf("http: TLS handshake error from 81.2.69.142:51234: EOF")
+
+		full := strings.Contains(buf.String(), "81.2.69.142:51234")
+		if cfg.anonymizer == nil && !full {
+			t.Errorf("expected the full address without privacy mode: %s", buf.String())
+		}
+		if cfg.anonymizer != nil && (full || !strings.Contains(buf.String(), "81.2.69.0:51234")) {
+			t.Errorf("expected a truncated address in privacy mode: %s", buf.String())
+		}
+	}
+}
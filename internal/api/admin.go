@@ -0,0 +1,19 @@
+package api
+
+import "net/http"
+
+// RequireClientCert only lets requests through whose TLS connection
+// presented a client certificate that passed verification against the
+// server's client CAs; all other requests are rejected with 403 Forbidden.
+// It is meant for the admin routes when the server is configured with
+// tls.VerifyClientCertIfGiven, so that the public API stays open to clients
+// without a certificate.
+func RequireClientCert(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			writeError(w, http.StatusForbidden, "A verified client certificate is required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
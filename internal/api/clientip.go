@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/jcjc-dev/ipwhere/internal/netindex"
+)
+
+// clientIPKey is the context key for the client IP resolved by TrustedProxies.RealIP
+type clientIPKey struct{}
+
+// peerAddrKey is the context key for the address of the connected peer
+type peerAddrKey struct{}
+
+// TrustedProxies lists the networks of reverse proxies whose forwarding
+// headers are believed. Without trusted proxies, forwarding headers from any
+// peer are used as-is.
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies parses IP addresses and CIDR prefixes
+func ParseTrustedProxies(specs []string) (TrustedProxies, error) {
+	var t TrustedProxies
+	for _, s := range specs {
+		p, err := netindex.ParsePrefix(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", s)
+		}
+		t = append(t, p)
+	}
+	return t, nil
+}
+
+// trusts reports whether ip belongs to a trusted proxy
+func (t TrustedProxies) trusts(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range t {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client that sent the request. Forwarding
+// headers are only used when the peer is a trusted proxy; X-Forwarded-For is
+// walked from right to left and the first address that is not a trusted
+// proxy is the client.
+func (t TrustedProxies) ClientIP(r *http.Request) string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	if !t.trusts(peer) {
+		return peer
+	}
+
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	if len(hops) > 0 {
+		client := peer
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				// Anything left of a malformed entry cannot be trusted
+				break
+			}
+			client = hop
+			if !t.trusts(hop) {
+				break
+			}
+		}
+		return client
+	}
+
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(xri) != nil {
+		return xri
+	}
+	return peer
+}
+
+// RealIP is a middleware that replaces RemoteAddr with the client IP and
+// records it for getClientIP, ignoring forwarding headers from untrusted peers
+func (t TrustedProxies) RealIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := t.ClientIP(r)
+		r.RemoteAddr = ip
+		ctx := context.WithValue(r.Context(), clientIPKey{}, ip)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// recordPeer remembers the address of the connected peer before RealIP
+// middleware replaces RemoteAddr with an address from forwarding headers
+func recordPeer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), peerAddrKey{}, r.RemoteAddr)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// trustedClientIP returns the client IP for access decisions. Unlike
+// getClientIP it never believes forwarding headers from untrusted peers: it
+// is the address resolved by TrustedProxies.RealIP if trusted proxies are
+// configured, and the peer address otherwise.
+func trustedClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	peer := r.RemoteAddr
+	if p, ok := r.Context().Value(peerAddrKey{}).(string); ok {
+		peer = p
+	}
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		return host
+	}
+	return peer
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedProxiesClientIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		xri        string
+		expected   string
+	}{
+		{
+			name:       "untrusted peer headers ignored",
+			remoteAddr: "203.0.113.9:1234",
+			xff:        []string{"198.51.100.1"},
+			xri:        "198.51.100.2",
+			expected:   "203.0.113.9",
+		},
+		{
+			name:       "trusted peer",
+			remoteAddr: "10.0.0.5:1234",
+			xff:        []string{"198.51.100.1"},
+			expected:   "198.51.100.1",
+		},
+		{
+			name:       "spoofed entries left of the client are skipped",
+			remoteAddr: "10.0.0.5:1234",
+			xff:        []string{"1.2.3.4, 198.51.100.1, 10.1.1.1"},
+			expected:   "198.51.100.1",
+		},
+		{
+			name:       "multiple headers",
+			remoteAddr: "192.0.2.1:1234",
+			xff:        []string{"1.2.3.4", "198.51.100.1, 10.1.1.1"},
+			expected:   "198.51.100.1",
+		},
+		{
+			name:       "all hops trusted",
+			remoteAddr: "10.0.0.5:1234",
+			xff:        []string{"10.2.2.2, 10.1.1.1"},
+			expected:   "10.2.2.2",
+		},
+		{
+			name:       "malformed hop stops the walk",
+			remoteAddr: "10.0.0.5:1234",
+			xff:        []string{"198.51.100.1, unknown, 10.1.1.1"},
+			expected:   "10.1.1.1",
+		},
+		{
+			name:       "X-Real-IP from trusted peer",
+			remoteAddr: "[2001:db8::1]:1234",
+			xri:        "198.51.100.3",
+			expected:   "198.51.100.3",
+		},
+		{
+			name:       "trusted peer without headers",
+			remoteAddr: "10.0.0.5:1234",
+			expected:   "10.0.0.5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.xri != "" {
+				req.Header.Set("X-Real-IP", tt.xri)
+			}
+
+			if got := trusted.ClientIP(req); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+
+	if _, err := ParseTrustedProxies([]string{"not-a-network"}); err == nil {
+		t.Error("expected an invalid trusted proxy to fail")
+	}
+}
+
+func TestRouterTrustedProxies(t *testing.T) {
+	trusted, _ := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	r := NewRouter(WithTrustedProxies(trusted))
+	NewHandler(&MockGeoReader{}, false).SetupRoutes(r)
+
+	req := httptest.NewRequest("GET", "/api/ip?return=country", nil)
+	req.RemoteAddr = "203.0.113.9:1234"
+	req.Header.Set("X-Forwarded-For", "8.8.8.8")
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var resp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if resp["ip"] != "203.0.113.9" {
+		t.Errorf("expected spoofed X-Forwarded-For to be ignored, got ip %v", resp["ip"])
+	}
+}
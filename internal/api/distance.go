@@ -0,0 +1,68 @@
+package api
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+)
+
+// Distance godoc
+// @Summary      Distance between two locations
+// @Description  Returns the great-circle distance, initial bearing and timezone offset difference between an IP address and another IP address or a coordinate pair. The min/max range accounts for the accuracy radius of each location.
+// @Tags         lookup
+// @Produce      json
+// @Param        from  query     string  false  "IP address to measure from (defaults to client IP)"
+// @Param        to    query     string  true   "IP address or lat,lon pair to measure to"
+// @Success      200   {object}  geo.Distance
+// @Failure      400   {object}  ErrorResponse
+// @Failure      404   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Router       /api/distance [get]
+func (h *Handler) Distance(w http.ResponseWriter, r *http.Request) {
+	fromStr := r.URL.Query().Get("from")
+	if fromStr == "" {
+		fromStr = getClientIP(r)
+		noStore(w)
+	}
+	if net.ParseIP(fromStr) == nil {
+		writeError(w, http.StatusBadRequest, "Invalid from IP address")
+		return
+	}
+
+	toStr := r.URL.Query().Get("to")
+	if toStr == "" {
+		writeError(w, http.StatusBadRequest, "Missing to parameter")
+		return
+	}
+
+	from, ok := h.resolvePoint(w, r, fromStr)
+	if !ok {
+		return
+	}
+	to, ok := h.resolvePoint(w, r, toStr)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, geo.Measure(from, to, time.Now()))
+}
+
+// resolvePoint resolves an IP address or coordinate pair, writing an error
+// response and returning false on failure
+func (h *Handler) resolvePoint(w http.ResponseWriter, r *http.Request, s string) (geo.Point, bool) {
+	p, err := geo.ResolvePoint(r.Context(), h.geoReader, s)
+	switch {
+	case err == nil:
+		return p, true
+	case errors.Is(err, geo.ErrInvalidPoint):
+		writeError(w, http.StatusBadRequest, "Invalid location, expected IP address or lat,lon")
+	case errors.Is(err, geo.ErrNoCoordinates):
+		writeError(w, http.StatusNotFound, "No location data for "+s)
+	default:
+		writeError(w, http.StatusInternalServerError, "Failed to lookup IP")
+	}
+	return geo.Point{}, false
+}
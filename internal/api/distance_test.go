@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	r := setupTestRouter()
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+		checkResponse  func(*testing.T, map[string]interface{})
+	}{
+		{
+			name:           "IP to IP",
+			url:            "/api/distance?from=8.8.8.8&to=8.8.4.4",
+			expectedStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, resp map[string]interface{}) {
+				if resp["distance_km"] != float64(0) {
+					t.Errorf("expected distance_km to be 0, got %v", resp["distance_km"])
+				}
+				to, _ := resp["to"].(map[string]interface{})
+				if to["ip"] != "8.8.4.4" {
+					t.Errorf("expected to.ip to be 8.8.4.4, got %v", to["ip"])
+				}
+			},
+		},
+		{
+			name:           "IP to point",
+			url:            "/api/distance?from=8.8.8.8&to=40.7128,-74.0060",
+			expectedStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, resp map[string]interface{}) {
+				km, _ := resp["distance_km"].(float64)
+				if km < 4000 || km > 4200 {
+					t.Errorf("expected distance_km of ~4100, got %v", resp["distance_km"])
+				}
+				if resp["bearing"] == nil {
+					t.Error("expected bearing to be present")
+				}
+				if resp["timezone_offset_diff_hours"] != nil {
+					t.Error("expected no timezone offset difference for a coordinate pair")
+				}
+			},
+		},
+		{
+			name:           "defaults from to client IP",
+			url:            "/api/distance?to=1.1.1.1",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "missing to",
+			url:            "/api/distance?from=8.8.8.8",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid from",
+			url:            "/api/distance?from=invalid&to=8.8.8.8",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid to",
+			url:            "/api/distance?from=8.8.8.8&to=somewhere",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			req.RemoteAddr = "192.0.2.1:12345"
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			var resp map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+
+			if tt.checkResponse != nil {
+				tt.checkResponse(t, resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/jcjc-dev/ipwhere/internal/extract"
+)
+
+const (
+	// maxExtractBodyBytes limits the size of an extract request body
+	maxExtractBodyBytes = 1 << 20
+	// maxExtractAddresses limits the number of distinct addresses geolocated
+	// per request
+	maxExtractAddresses = 1000
+)
+
+// ExtractRequest is the JSON request body for address extraction
+type ExtractRequest struct {
+	Text string `json:"text"`
+}
+
+// Extract godoc
+// @Summary      Extract and geolocate IP addresses from text
+// @Description  Finds IPv4 and IPv6 addresses in free text, including bracketed addresses, addresses with ports and defanged forms such as 1[.]2[.]3[.]4. Distinct addresses are geolocated (private and reserved ones are only marked) and returned with their byte offsets in the text and a summary by country and ASN. Send the text as text/plain, or JSON with a text field. At most 1000 distinct addresses are returned.
+// @Tags         lookup
+// @Accept       plain
+// @Accept       json
+// @Produce      json
+// @Param        request  body      ExtractRequest  true  "Text to scan"
+// @Success      200      {object}  extract.Result
+// @Failure      400      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Router       /api/extract [post]
+func (h *Handler) Extract(w http.ResponseWriter, r *http.Request) {
+	body := http.MaxBytesReader(w, r.Body, maxExtractBodyBytes)
+
+	var text string
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		var req ExtractRequest
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		text = req.Text
+	} else {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		text = string(data)
+	}
+
+	result, err := extract.Analyze(r.Context(), h.geoReader, text, maxExtractAddresses)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to lookup IP")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jcjc-dev/ipwhere/internal/extract"
+)
+
+func TestExtract(t *testing.T) {
+	r := setupTestRouter()
+
+	text := "blocked 8.8.8.8:443 and 1[.]1[.]1[.]1, internal 192.168.0.10, again 8.8.8.8"
+	jsonBody, _ := json.Marshal(ExtractRequest{Text: text})
+
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		expectedStatus int
+		addresses      int
+	}{
+		{name: "Plain text", contentType: "text/plain", body: text, expectedStatus: http.StatusOK, addresses: 3},
+		{name: "JSON", contentType: "application/json", body: string(jsonBody), expectedStatus: http.StatusOK, addresses: 3},
+		{name: "No addresses", contentType: "text/plain", body: "nothing to see", expectedStatus: http.StatusOK},
+		{name: "Invalid JSON", contentType: "application/json", body: "{", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/extract", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var result extract.Result
+			if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(result.Addresses) != tt.addresses {
+				t.Fatalf("expected %d addresses, got %d", tt.addresses, len(result.Addresses))
+			}
+			if tt.addresses == 0 {
+				return
+			}
+			if first := result.Addresses[0]; first.IP != "8.8.8.8" || len(first.Occurrences) != 2 || first.Occurrences[0].Port != 443 {
+				t.Errorf("unexpected first address: %+v", first)
+			}
+			if !result.Addresses[1].Occurrences[0].Defanged {
+				t.Error("expected the defanged address to be marked")
+			}
+			if len(result.Countries) != 1 || result.Countries[0].Count != 2 {
+				t.Errorf("unexpected country summary: %+v", result.Countries)
+			}
+		})
+	}
+}
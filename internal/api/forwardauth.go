@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jcjc-dev/ipwhere/internal/policy"
+)
+
+// ForwardAuth godoc
+// @Summary      Forward-auth target for reverse proxies
+// @Description  Looks up the client IP and answers with geo headers for the proxy to copy upstream (nginx auth_request, Traefik ForwardAuth, Caddy forward_auth). Without a policy name the response is always 200; with one, the policy decides between 200 and 403. Responses have no body. Forwarding headers are only honoured from trusted proxies.
+// @Tags         policy
+// @Param        name  path  string  false  "Policy name"
+// @Success      200
+// @Failure      400
+// @Failure      403
+// @Failure      404
+// @Failure      500
+// @Header       200,403  {string}  X-Geo-Country   "ISO country code"
+// @Header       200,403  {string}  X-Geo-City      "City name"
+// @Header       200,403  {string}  X-Geo-ASN       "Autonomous System Number"
+// @Header       200,403  {string}  X-Geo-Org       "AS organization name"
+// @Header       200,403  {string}  X-Geo-Decision  "Policy decision (allow or deny)"
+// @Header       200,403  {string}  X-Geo-Rule      "Deciding policy rule"
+// @Router       /api/forward-auth/{name} [get]
+// @Router       /api/forward-auth/{name} [head]
+func (h *Handler) ForwardAuth(w http.ResponseWriter, r *http.Request) {
+	var p *policy.Policy
+	if name := chi.URLParam(r, "name"); name != "" {
+		var ok bool
+		if h.policies != nil {
+			p, ok = h.policies.Policy(name)
+		}
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+	}
+
+	noStore(w)
+	// Forwarding headers are client-controlled unless set by a trusted proxy
+	ip := net.ParseIP(trustedClientIP(r))
+	if ip == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	info, err := h.geoReader.Lookup(r.Context(), ip)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	headers := DefaultGeoHeaders()
+	headers.Set(w.Header(), info)
+
+	status := http.StatusOK
+	if p != nil {
+		action, rule := p.Evaluate(info)
+		headers.SetDecision(w.Header(), action, rule)
+		if action == policy.Deny {
+			status = http.StatusForbidden
+		}
+	}
+	w.WriteHeader(status)
+}
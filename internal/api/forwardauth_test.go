@@ -0,0 +1,146 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForwardAuth(t *testing.T) {
+	r := setupPolicyRouter(t)
+
+	tests := []struct {
+		name             string
+		method           string
+		url              string
+		expectedStatus   int
+		expectedDecision string
+		expectedRule     string
+	}{
+		{
+			name:           "tag only",
+			method:         "GET",
+			url:            "/api/forward-auth",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:             "allowed by policy",
+			method:           "GET",
+			url:              "/api/forward-auth/us-only",
+			expectedStatus:   http.StatusOK,
+			expectedDecision: "allow",
+			expectedRule:     "united-states",
+		},
+		{
+			name:             "denied by policy",
+			method:           "HEAD",
+			url:              "/api/forward-auth/no-google",
+			expectedStatus:   http.StatusForbidden,
+			expectedDecision: "deny",
+			expectedRule:     "google",
+		},
+		{
+			name:           "other methods",
+			method:         "POST",
+			url:            "/api/forward-auth/us-only",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "unknown policy",
+			method:         "GET",
+			url:            "/api/forward-auth/missing",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, nil)
+			req.RemoteAddr = "8.8.8.8:40000"
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Body.Len() != 0 {
+				t.Errorf("expected an empty body, got %q", w.Body.String())
+			}
+			if tt.expectedStatus == http.StatusNotFound || tt.expectedStatus == http.StatusMethodNotAllowed {
+				return
+			}
+
+			if got := w.Header().Get(HeaderGeoIP); got != "8.8.8.8" {
+				t.Errorf("expected %s 8.8.8.8, got %q", HeaderGeoIP, got)
+			}
+			if got := w.Header().Get(HeaderGeoCountry); got != "US" {
+				t.Errorf("expected %s US, got %q", HeaderGeoCountry, got)
+			}
+			if got := w.Header().Get(HeaderGeoASN); got != "15169" {
+				t.Errorf("expected %s 15169, got %q", HeaderGeoASN, got)
+			}
+			if got := w.Header().Get(HeaderGeoDecision); got != tt.expectedDecision {
+				t.Errorf("expected %s %q, got %q", HeaderGeoDecision, tt.expectedDecision, got)
+			}
+			if got := w.Header().Get(HeaderGeoRule); got != tt.expectedRule {
+				t.Errorf("expected %s %q, got %q", HeaderGeoRule, tt.expectedRule, got)
+			}
+		})
+	}
+}
+
+func TestForwardAuthIgnoresSpoofedHeaders(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		opts       []RouterOption
+		remoteAddr string
+		expectedIP string
+	}{
+		{
+			name:       "no trusted proxies",
+			remoteAddr: "192.0.2.1:1234",
+			expectedIP: "192.0.2.1",
+		},
+		{
+			name:       "untrusted peer",
+			opts:       []RouterOption{WithTrustedProxies(trusted)},
+			remoteAddr: "192.0.2.1:1234",
+			expectedIP: "192.0.2.1",
+		},
+		{
+			name:       "trusted proxy",
+			opts:       []RouterOption{WithTrustedProxies(trusted)},
+			remoteAddr: "10.0.0.2:1234",
+			expectedIP: "8.8.8.8",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRouter(tt.opts...)
+			NewHandler(&MockGeoReader{}, false).SetupRoutes(r)
+
+			req := httptest.NewRequest("GET", "/api/forward-auth", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", "8.8.8.8")
+			req.Header.Set("X-Real-IP", "8.8.8.8")
+			req.Header.Set("True-Client-IP", "8.8.8.8")
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if got := w.Header().Get(HeaderGeoIP); got != tt.expectedIP {
+				t.Errorf("expected %s %s, got %q", HeaderGeoIP, tt.expectedIP, got)
+			}
+		})
+	}
+}
@@ -2,26 +2,65 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net"
 	"net/http"
 	"strings"
+	"sync/atomic"
+	"time"
 
-	"github.com/jcjc-dev/ipwhere/internal/geo"
 	"github.com/go-chi/chi/v5"
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+	"github.com/jcjc-dev/ipwhere/internal/lists"
+	"github.com/jcjc-dev/ipwhere/internal/policy"
+	"github.com/jcjc-dev/ipwhere/internal/privacy"
 )
 
 // Handler holds the dependencies for HTTP handlers
 type Handler struct {
 	geoReader            geo.ReaderInterface
 	enableOnlineFeatures bool
+	policies             *policy.Engine
+	privacy              *privacy.Anonymizer
+	adminAuth            func(http.Handler) http.Handler
+	draining             atomic.Bool
+}
+
+// HandlerOption configures optional Handler behaviour
+type HandlerOption func(*Handler)
+
+// WithPolicies enables policy evaluation with the given engine
+func WithPolicies(e *policy.Engine) HandlerOption {
+	return func(h *Handler) {
+		h.policies = e
+	}
+}
+
+// WithPrivacy disables or redacts the debug endpoint as configured in a
+func WithPrivacy(a *privacy.Anonymizer) HandlerOption {
+	return func(h *Handler) {
+		h.privacy = a
+	}
+}
+
+// WithAdminAuth guards the admin routes (/api/debug, /api/info and
+// /api/lists) with the given middleware, e.g. RequireClientCert
+func WithAdminAuth(mw func(http.Handler) http.Handler) HandlerOption {
+	return func(h *Handler) {
+		h.adminAuth = mw
+	}
 }
 
 // NewHandler creates a new Handler with the given geo reader
-func NewHandler(geoReader geo.ReaderInterface, enableOnlineFeatures bool) *Handler {
-	return &Handler{
+func NewHandler(geoReader geo.ReaderInterface, enableOnlineFeatures bool, opts ...HandlerOption) *Handler {
+	h := &Handler{
 		geoReader:            geoReader,
 		enableOnlineFeatures: enableOnlineFeatures,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 // ErrorResponse represents an error response
@@ -45,9 +84,21 @@ func writeError(w http.ResponseWriter, status int, message string) {
 	})
 }
 
+// noStore marks a response as specific to the caller, e.g. because it
+// contains their own address, so that shared caches never store it
+func noStore(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "no-store")
+}
+
 // getClientIP extracts the client IP from the request.
-// It checks proxy headers (X-Forwarded-For, X-Real-IP) before falling back to RemoteAddr.
+// If trusted proxies are configured, the address resolved by TrustedProxies.RealIP
+// is used. Otherwise it checks proxy headers (X-Forwarded-For, X-Real-IP) before
+// falling back to RemoteAddr.
 func getClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+
 	// Check X-Forwarded-For header first (for proxies)
 	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
 		// Take the first IP in the chain (original client)
@@ -73,21 +124,34 @@ func getClientIP(r *http.Request) string {
 
 // IPLookup godoc
 // @Summary      Look up IP geolocation
-// @Description  Returns geolocation data for the requesting IP or specified IP address
+// @Description  Returns geolocation data for the requesting IP or specified IP address. With host (online features only), resolves the hostname and returns a geo.HostInfo with every address geolocated.
 // @Tags         lookup
 // @Accept       json
 // @Produce      json
 // @Param        ip      query     string  false  "IP address to lookup (defaults to client IP)"
-// @Param        return  query     []string  false  "Fields to return (can be repeated). Valid values: hostname, country, iso_code, in_eu, city, region, latitude, longitude, timezone, asn, organization"
+// @Param        host    query     string  false  "Hostname to resolve and geolocate instead of an IP address (requires online features)"
+// @Param        cname   query     bool    false  "With host: report the canonical name the host is an alias for"
+// @Param        mx      query     bool    false  "With host: also resolve and geolocate the mail exchangers"
+// @Param        return  query     []string  false  "Fields to return (can be repeated). Valid values: hostname, hostnames, hostname_verified, country, iso_code, in_eu, city, region, latitude, longitude, accuracy_radius, timezone, local_time, utc_offset, is_dst, tz_abbreviation, asn, organization, network_type, is_hosting, currency, calling_code, languages, capital, continent, flag_emoji, tld, in_eea, in_schengen, gdpr_adequate, cloud_provider, cloud_region, cloud_service, lists"
+// @Param        as_of   query     string  false  "Use the newest database built on or before this date (YYYY-MM-DD)"
+// @Param        at      query     string  false  "Instant for local time fields (RFC 3339, defaults to now)"
 // @Success      200     {object}  geo.IPInfo
 // @Failure      400     {object}  ErrorResponse
+// @Failure      404     {object}  ErrorResponse
 // @Failure      500     {object}  ErrorResponse
+// @Failure      502     {object}  ErrorResponse
 // @Router       /api/ip [get]
 func (h *Handler) IPLookup(w http.ResponseWriter, r *http.Request) {
+	if host := r.URL.Query().Get("host"); host != "" {
+		h.hostLookup(w, r, host)
+		return
+	}
+
 	// Get IP to lookup
 	ipStr := r.URL.Query().Get("ip")
 	if ipStr == "" {
 		ipStr = getClientIP(r)
+		noStore(w)
 	}
 
 	// Parse IP
@@ -97,12 +161,40 @@ func (h *Handler) IPLookup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Lookup IP
-	info, err := h.geoReader.Lookup(ip)
+	// Parse the instant used for local time fields
+	var at time.Time
+	if atStr := r.URL.Query().Get("at"); atStr != "" {
+		var err error
+		if at, err = time.Parse(time.RFC3339, atStr); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid at time, expected RFC 3339")
+			return
+		}
+	}
+
+	// Lookup IP, optionally against a historical database generation
+	var info *geo.IPInfo
+	var err error
+	if asOfStr := r.URL.Query().Get("as_of"); asOfStr != "" {
+		asOf, parseErr := time.Parse(time.DateOnly, asOfStr)
+		if parseErr != nil {
+			writeError(w, http.StatusBadRequest, "Invalid as_of date, expected YYYY-MM-DD")
+			return
+		}
+		info, err = h.geoReader.LookupAsOf(r.Context(), ip, asOf)
+	} else {
+		info, err = h.geoReader.Lookup(r.Context(), ip)
+	}
+	if errors.Is(err, geo.ErrNoDatabaseForDate) {
+		writeError(w, http.StatusNotFound, "No database available for the requested date")
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to lookup IP")
 		return
 	}
+	if !at.IsZero() {
+		info.SetLocalTime(at)
+	}
 
 	// Check for field filtering
 	returnFields := r.URL.Query()["return"]
@@ -122,49 +214,124 @@ func (h *Handler) IPLookup(w http.ResponseWriter, r *http.Request) {
 
 // Health godoc
 // @Summary      Health check
-// @Description  Returns health status of the service
+// @Description  Returns health status of the service. Fails with 503 while the server is draining before shutdown.
 // @Tags         health
 // @Produce      json
 // @Success      200  {object}  map[string]string
+// @Failure      503  {object}  map[string]string
 // @Router       /health [get]
 func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
+	if h.draining.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"status": "draining",
+		})
+		return
+	}
 	writeJSON(w, http.StatusOK, map[string]string{
 		"status": "ok",
 	})
 }
 
+// Drain makes the health check fail so that load balancers stop sending
+// new requests ahead of a shutdown
+func (h *Handler) Drain() {
+	h.draining.Store(true)
+}
+
 // Debug godoc
 // @Summary      Debug request headers
-// @Description  Returns all request headers and connection info for debugging
+// @Description  Returns all request headers and connection info for debugging. In privacy mode the endpoint is disabled (404), or addresses are truncated and credentials removed.
 // @Tags         debug
 // @Produce      json
 // @Success      200  {object}  map[string]interface{}
+// @Failure      404  {object}  ErrorResponse
 // @Router       /api/debug [get]
 func (h *Handler) Debug(w http.ResponseWriter, r *http.Request) {
+	noStore(w)
+
+	// redact and redactURI are the identity function unless privacy mode
+	// redacts; redactURI also decodes percent-encoded addresses
+	redact := func(s string) string { return s }
+	redactURI := redact
+	if h.privacy != nil {
+		if h.privacy.Config().Debug != privacy.DebugRedacted {
+			writeError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		redact = h.privacy.Redact
+		redactURI = h.privacy.RedactURI
+	}
+
 	headers := make(map[string]string)
 	for name, values := range r.Header {
-		headers[name] = values[0]
+		switch {
+		case h.privacy != nil && credentialHeaders[name]:
+			headers[name] = "[redacted]"
+		case name == "Referer":
+			headers[name] = redactURI(values[0])
+		default:
+			headers[name] = redact(values[0])
+		}
 	}
 
 	debugInfo := map[string]interface{}{
-		"remoteAddr":       r.RemoteAddr,
+		"remoteAddr":       redact(r.RemoteAddr),
 		"host":             r.Host,
-		"requestURI":       r.RequestURI,
+		"requestURI":       redactURI(r.RequestURI),
 		"headers":          headers,
-		"xForwardedFor":    r.Header.Get("X-Forwarded-For"),
-		"xRealIP":          r.Header.Get("X-Real-IP"),
-		"xAzureClientIP":   r.Header.Get("X-Azure-ClientIP"),
+		"xForwardedFor":    redact(r.Header.Get("X-Forwarded-For")),
+		"xRealIP":          redact(r.Header.Get("X-Real-IP")),
+		"xAzureClientIP":   redact(r.Header.Get("X-Azure-ClientIP")),
 		"xOriginalHost":    r.Header.Get("X-Original-Host"),
-		"xClientIP":        r.Header.Get("X-Client-IP"),
-		"cfConnectingIP":   r.Header.Get("CF-Connecting-IP"),
-		"trueClientIP":     r.Header.Get("True-Client-IP"),
-		"forwardedHeader":  r.Header.Get("Forwarded"),
-		"detectedClientIP": getClientIP(r),
+		"xClientIP":        redact(r.Header.Get("X-Client-IP")),
+		"cfConnectingIP":   redact(r.Header.Get("CF-Connecting-IP")),
+		"trueClientIP":     redact(r.Header.Get("True-Client-IP")),
+		"forwardedHeader":  redact(r.Header.Get("Forwarded")),
+		"detectedClientIP": redact(getClientIP(r)),
 	}
 
 	writeJSON(w, http.StatusOK, debugInfo)
 }
 
+// credentialHeaders are omitted from redacted debug output
+var credentialHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"X-Api-Key":           true,
+}
+
+// Info godoc
+// @Summary      Data source metadata
+// @Description  Returns build dates and ages of the loaded databases, retained historical generations, cloud range files, threat lists and lookup cache statistics
+// @Tags         info
+// @Produce      json
+// @Success      200  {object}  geo.Metadata
+// @Router       /api/info [get]
+func (h *Handler) Info(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.geoReader.Metadata())
+}
+
+// ListsResponse represents the loaded threat lists
+type ListsResponse struct {
+	Lists []lists.Info `json:"lists"`
+}
+
+// Lists godoc
+// @Summary      Loaded threat lists
+// @Description  Returns the threat-intel lists loaded for matching, with entry counts, file modification times and load times
+// @Tags         info
+// @Produce      json
+// @Success      200  {object}  ListsResponse
+// @Router       /api/lists [get]
+func (h *Handler) Lists(w http.ResponseWriter, r *http.Request) {
+	loaded := h.geoReader.Metadata().Lists
+	if loaded == nil {
+		loaded = []lists.Info{}
+	}
+	writeJSON(w, http.StatusOK, ListsResponse{Lists: loaded})
+}
+
 // FeaturesResponse represents the feature flags response
 type FeaturesResponse struct {
 	OnlineFeatures bool `json:"onlineFeatures"`
@@ -186,7 +353,25 @@ func (h *Handler) Features(w http.ResponseWriter, r *http.Request) {
 // SetupRoutes configures the API routes
 func (h *Handler) SetupRoutes(r chi.Router) {
 	r.Get("/api/ip", h.IPLookup)
-	r.Get("/api/debug", h.Debug)
+	r.Get("/api/distance", h.Distance)
+	r.Post("/api/travel", h.Travel)
+	r.Post("/api/trace/email", h.TraceEmail)
+	r.Post("/api/extract", h.Extract)
 	r.Get("/api/features", h.Features)
+	r.Get("/api/policy/{name}", h.Policy)
+	r.Get("/api/forward-auth", h.ForwardAuth)
+	r.Head("/api/forward-auth", h.ForwardAuth)
+	r.Get("/api/forward-auth/{name}", h.ForwardAuth)
+	r.Head("/api/forward-auth/{name}", h.ForwardAuth)
 	r.Get("/health", h.Health)
+
+	// Admin routes expose request details and the loaded data sources
+	r.Group(func(r chi.Router) {
+		if h.adminAuth != nil {
+			r.Use(h.adminAuth)
+		}
+		r.Get("/api/debug", h.Debug)
+		r.Get("/api/info", h.Info)
+		r.Get("/api/lists", h.Lists)
+	})
 }
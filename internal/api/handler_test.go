@@ -1,20 +1,27 @@
 package api
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
-	"github.com/jcjc-dev/ipwhere/internal/geo"
 	"github.com/go-chi/chi/v5"
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+	"github.com/jcjc-dev/ipwhere/internal/lists"
+	"github.com/jcjc-dev/ipwhere/internal/privacy"
 )
 
 // MockGeoReader implements geo.ReaderInterface for testing
 type MockGeoReader struct{}
 
-func (m *MockGeoReader) Lookup(ip net.IP) (*geo.IPInfo, error) {
+func (m *MockGeoReader) Lookup(ctx context.Context, ip net.IP) (*geo.IPInfo, error) {
 	lat := 37.4056
 	lon := -122.0775
 	asn := uint(15169)
@@ -31,10 +38,55 @@ func (m *MockGeoReader) Lookup(ip net.IP) (*geo.IPInfo, error) {
 		Timezone:     "America/Los_Angeles",
 		ASN:          &asn,
 		Organization: "Google LLC",
+		NetworkType:  "hosting",
+		IsHosting:    true,
 		Attribution:  geo.Attribution,
 	}, nil
 }
 
+func (m *MockGeoReader) LookupAsOf(ctx context.Context, ip net.IP, asOf time.Time) (*geo.IPInfo, error) {
+	if asOf.Before(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		return nil, geo.ErrNoDatabaseForDate
+	}
+	info, _ := m.Lookup(ctx, ip)
+	info.DatabaseBuild = "2024-01-01"
+	return info, nil
+}
+
+// LookupHost resolves example.com to one address, and its MX when requested
+func (m *MockGeoReader) LookupHost(ctx context.Context, host string, opts geo.HostOptions) (*geo.HostInfo, error) {
+	if host != "example.com" {
+		return nil, geo.ErrHostNotFound
+	}
+	info, _ := m.Lookup(ctx, net.ParseIP("93.184.216.34"))
+	result := &geo.HostInfo{
+		Host:        host,
+		Chain:       []geo.DNSRecord{{Name: host, Type: "A", Value: info.IP}},
+		Addresses:   []geo.HostAddress{{Name: host, IPInfo: info}},
+		Attribution: geo.Attribution,
+	}
+	if opts.MX {
+		mx, _ := m.Lookup(ctx, net.ParseIP("93.184.216.35"))
+		result.Chain = append(result.Chain,
+			geo.DNSRecord{Name: host, Type: "MX", Value: "mail.example.com", Preference: 10},
+			geo.DNSRecord{Name: "mail.example.com", Type: "A", Value: mx.IP})
+		result.Addresses = append(result.Addresses, geo.HostAddress{Name: "mail.example.com", IPInfo: mx})
+	}
+	return result, nil
+}
+
+func (m *MockGeoReader) Metadata() *geo.Metadata {
+	return &geo.Metadata{
+		Databases: []geo.DatabaseInfo{
+			{Type: "DBIP-City-Lite", File: "dbip-city-lite.mmdb"},
+			{Type: "DBIP-ASN-Lite", File: "dbip-asn-lite.mmdb"},
+		},
+		Lists: []lists.Info{
+			{Name: "tor", Category: "anonymizer", File: "tor-exits.txt", Entries: 1200},
+		},
+	}
+}
+
 func (m *MockGeoReader) Close() error {
 	return nil
 }
@@ -114,6 +166,75 @@ func TestIPLookup(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:           "historical lookup",
+			url:            "/api/ip?ip=8.8.8.8&as_of=2024-06-01",
+			expectedStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, resp map[string]interface{}) {
+				if resp["database_build"] != "2024-01-01" {
+					t.Errorf("expected database_build to be 2024-01-01, got %v", resp["database_build"])
+				}
+			},
+		},
+		{
+			name:           "historical lookup with field filter",
+			url:            "/api/ip?ip=8.8.8.8&as_of=2024-06-01&return=country",
+			expectedStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, resp map[string]interface{}) {
+				if resp["database_build"] != "2024-01-01" {
+					t.Errorf("expected database_build to be 2024-01-01, got %v", resp["database_build"])
+				}
+			},
+		},
+		{
+			name:           "historical lookup before oldest database",
+			url:            "/api/ip?ip=8.8.8.8&as_of=2023-06-01",
+			expectedStatus: http.StatusNotFound,
+			checkResponse: func(t *testing.T, resp map[string]interface{}) {
+				if resp["error"] == nil {
+					t.Error("expected error message")
+				}
+			},
+		},
+		{
+			name:           "invalid as_of date",
+			url:            "/api/ip?ip=8.8.8.8&as_of=June",
+			expectedStatus: http.StatusBadRequest,
+			checkResponse: func(t *testing.T, resp map[string]interface{}) {
+				if resp["error"] == nil {
+					t.Error("expected error message")
+				}
+			},
+		},
+		{
+			name:           "local time at instant",
+			url:            "/api/ip?ip=8.8.8.8&at=2024-01-15T11:00:00Z&return=local_time&return=is_dst&return=tz_abbreviation",
+			expectedStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, resp map[string]interface{}) {
+				if resp["local_time"] != "2024-01-15T03:00:00-08:00" {
+					t.Errorf("expected local_time to be 2024-01-15T03:00:00-08:00, got %v", resp["local_time"])
+				}
+				if resp["is_dst"] != false {
+					t.Errorf("expected is_dst to be false, got %v", resp["is_dst"])
+				}
+				if resp["tz_abbreviation"] != "PST" {
+					t.Errorf("expected tz_abbreviation to be PST, got %v", resp["tz_abbreviation"])
+				}
+				if resp["timezone"] != nil {
+					t.Error("expected timezone to not be present when not requested")
+				}
+			},
+		},
+		{
+			name:           "invalid at time",
+			url:            "/api/ip?ip=8.8.8.8&at=yesterday",
+			expectedStatus: http.StatusBadRequest,
+			checkResponse: func(t *testing.T, resp map[string]interface{}) {
+				if resp["error"] == nil {
+					t.Error("expected error message")
+				}
+			},
+		},
 		{
 			name:           "invalid IP",
 			url:            "/api/ip?ip=invalid",
@@ -171,6 +292,50 @@ func TestHealth(t *testing.T) {
 	}
 }
 
+func TestInfo(t *testing.T) {
+	r := setupTestRouter()
+
+	req := httptest.NewRequest("GET", "/api/info", nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	var resp geo.Metadata
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+
+	if len(resp.Databases) != 2 {
+		t.Errorf("expected 2 databases, got %d", len(resp.Databases))
+	}
+}
+
+func TestLists(t *testing.T) {
+	r := setupTestRouter()
+
+	req := httptest.NewRequest("GET", "/api/lists", nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	var resp ListsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+
+	if len(resp.Lists) != 1 || resp.Lists[0].Name != "tor" || resp.Lists[0].Entries != 1200 {
+		t.Errorf("unexpected lists: %+v", resp.Lists)
+	}
+}
+
 func TestGetClientIP(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -226,3 +391,146 @@ func TestGetClientIP(t *testing.T) {
 		})
 	}
 }
+
+func TestDebug(t *testing.T) {
+	redacting, _ := privacy.New(privacy.Config{Debug: privacy.DebugRedacted})
+	disabled, _ := privacy.New(privacy.Config{})
+
+	tests := []struct {
+		name     string
+		opts     []HandlerOption
+		status   int
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "Default",
+			status:   http.StatusOK,
+			contains: []string{"81.2.69.142", "secret"},
+		},
+		{
+			name:   "Privacy mode",
+			opts:   []HandlerOption{WithPrivacy(disabled)},
+			status: http.StatusNotFound,
+		},
+		{
+			name:     "Privacy mode redacted",
+			opts:     []HandlerOption{WithPrivacy(redacting)},
+			status:   http.StatusOK,
+			contains: []string{`"xForwardedFor":"81.2.69.0, 10.0.0.0"`, `"remoteAddr":"192.0.2.0:1234"`, `"Authorization":"[redacted]"`, `"User-Agent":"test/1.0"`, `"X-Client":"client:81.2.69.0"`, `?ip=2001:db8:1::`, `src=ip_81.2.69.0`, `"X-Note":"ip_81.2.69.0"`},
+			excludes: []string{"81.2.69.142", "81.2.69.143", "81.2.69.144", "81.2.69.145", "secret", "192.0.2.1", "%3A", "db8:1:2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			NewHandler(&MockGeoReader{}, false, tt.opts...).SetupRoutes(r)
+
+			req := httptest.NewRequest("GET", "/api/debug?ip=2001%3Adb8%3A1%3A2%3A%3A1&src=ip_81.2.69.144", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header.Set("X-Forwarded-For", "81.2.69.142, 10.0.0.7")
+			req.Header.Set("Authorization", "Bearer secret")
+			req.Header.Set("User-Agent", "test/1.0")
+			req.Header.Set("X-Client", "client:81.2.69.143")
+			req.Header.Set("X-Note", "ip_81.2.69.145")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+			if cc := w.Header().Get("Cache-Control"); cc != "no-store" {
+				t.Errorf("expected Cache-Control no-store, got %q", cc)
+			}
+			body := w.Body.String()
+			for _, s := range tt.contains {
+				if !strings.Contains(body, s) {
+					t.Errorf("response does not contain %s: %s", s, body)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(body, s) {
+					t.Errorf("response contains %s: %s", s, body)
+				}
+			}
+		})
+	}
+}
+
+func TestNoStoreForCallerAddress(t *testing.T) {
+	r := setupTestRouter()
+
+	tests := []struct {
+		path    string
+		noStore bool
+	}{
+		{path: "/api/ip", noStore: true},
+		{path: "/api/ip?ip=8.8.8.8", noStore: false},
+		{path: "/api/distance?to=8.8.8.8", noStore: true},
+		{path: "/api/distance?from=8.8.4.4&to=8.8.8.8", noStore: false},
+		{path: "/api/forward-auth", noStore: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.RemoteAddr = "8.8.4.4:1234"
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if got := w.Header().Get("Cache-Control") == "no-store"; got != tt.noStore {
+				t.Errorf("no-store = %v, want %v (status %d)", got, tt.noStore, w.Code)
+			}
+		})
+	}
+}
+
+func TestHealthDraining(t *testing.T) {
+	r := chi.NewRouter()
+	handler := NewHandler(&MockGeoReader{}, false)
+	handler.SetupRoutes(r)
+	handler.Drain()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "draining") {
+		t.Errorf("unexpected body: %s", w.Body.String())
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+	r := chi.NewRouter()
+	handler := NewHandler(&MockGeoReader{}, false, WithAdminAuth(RequireClientCert))
+	handler.SetupRoutes(r)
+
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+	tests := []struct {
+		path string
+		tls  *tls.ConnectionState
+		want int
+	}{
+		{"/api/info", nil, http.StatusForbidden},
+		{"/api/lists", &tls.ConnectionState{}, http.StatusForbidden},
+		{"/api/debug", &tls.ConnectionState{}, http.StatusForbidden},
+		{"/api/info", verified, http.StatusOK},
+		{"/api/debug", verified, http.StatusOK},
+		{"/api/ip?ip=8.8.8.8", nil, http.StatusOK},
+		{"/health", nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		req.TLS = tt.tls
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("%s (verified=%v): expected status %d, got %d", tt.path, tt.tls == verified, tt.want, w.Code)
+		}
+	}
+}
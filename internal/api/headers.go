@@ -0,0 +1,127 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+	"github.com/jcjc-dev/ipwhere/internal/policy"
+)
+
+// Default geolocation header names
+const (
+	HeaderGeoIP           = "X-Geo-IP"
+	HeaderGeoCountry      = "X-Geo-Country"
+	HeaderGeoCity         = "X-Geo-City"
+	HeaderGeoASN          = "X-Geo-ASN"
+	HeaderGeoOrganization = "X-Geo-Org"
+	HeaderGeoLatitude     = "X-Geo-Latitude"
+	HeaderGeoLongitude    = "X-Geo-Longitude"
+	HeaderGeoDecision     = "X-Geo-Decision"
+	HeaderGeoRule         = "X-Geo-Rule"
+)
+
+// GeoHeaders names the headers that carry geolocation data. Fields with an
+// empty name are not sent.
+type GeoHeaders struct {
+	IP           string
+	Country      string
+	City         string
+	ASN          string
+	Organization string
+	Latitude     string
+	Longitude    string
+	Decision     string
+	Rule         string
+}
+
+// DefaultGeoHeaders returns the default X-Geo-* header names
+func DefaultGeoHeaders() GeoHeaders {
+	return GeoHeaders{
+		IP:           HeaderGeoIP,
+		Country:      HeaderGeoCountry,
+		City:         HeaderGeoCity,
+		ASN:          HeaderGeoASN,
+		Organization: HeaderGeoOrganization,
+		Latitude:     HeaderGeoLatitude,
+		Longitude:    HeaderGeoLongitude,
+		Decision:     HeaderGeoDecision,
+		Rule:         HeaderGeoRule,
+	}
+}
+
+// fields maps configuration keys to header name fields
+func (g *GeoHeaders) fields() map[string]*string {
+	return map[string]*string{
+		"ip":           &g.IP,
+		"country":      &g.Country,
+		"city":         &g.City,
+		"asn":          &g.ASN,
+		"organization": &g.Organization,
+		"latitude":     &g.Latitude,
+		"longitude":    &g.Longitude,
+		"decision":     &g.Decision,
+		"rule":         &g.Rule,
+	}
+}
+
+// Rename applies a "field=Header-Name" override. An empty name disables the
+// header. Valid fields: ip, country, city, asn, organization, latitude,
+// longitude, decision, rule.
+func (g *GeoHeaders) Rename(spec string) error {
+	field, name, ok := strings.Cut(spec, "=")
+	if !ok {
+		return fmt.Errorf("invalid header %q, expected field=Header-Name", spec)
+	}
+	target, ok := g.fields()[strings.ToLower(strings.TrimSpace(field))]
+	if !ok {
+		return fmt.Errorf("unknown header field %q", field)
+	}
+	*target = http.CanonicalHeaderKey(strings.TrimSpace(name))
+	return nil
+}
+
+// Strip removes every configured header and every default X-Geo-* header,
+// e.g. copies sent by the client. The defaults are removed even when a
+// field is renamed or disabled, since upstreams may still trust them.
+func (g GeoHeaders) Strip(h http.Header) {
+	for _, set := range []GeoHeaders{DefaultGeoHeaders(), g} {
+		for _, name := range set.fields() {
+			if *name != "" {
+				h.Del(*name)
+			}
+		}
+	}
+}
+
+// Set adds the lookup result as headers
+func (g GeoHeaders) Set(h http.Header, info *geo.IPInfo) {
+	set := func(name, value string) {
+		if name != "" && value != "" {
+			h.Set(name, value)
+		}
+	}
+	set(g.IP, info.IP)
+	set(g.Country, info.ISOCode)
+	set(g.City, info.City)
+	set(g.Organization, info.Organization)
+	if info.ASN != nil {
+		set(g.ASN, strconv.FormatUint(uint64(*info.ASN), 10))
+	}
+	if info.Latitude != nil && info.Longitude != nil {
+		set(g.Latitude, strconv.FormatFloat(*info.Latitude, 'f', -1, 64))
+		set(g.Longitude, strconv.FormatFloat(*info.Longitude, 'f', -1, 64))
+	}
+}
+
+// SetDecision adds a policy decision as headers
+func (g GeoHeaders) SetDecision(h http.Header, action policy.Action, rule string) {
+	if g.Decision != "" {
+		h.Set(g.Decision, string(action))
+	}
+	if g.Rule != "" {
+		h.Set(g.Rule, rule)
+	}
+}
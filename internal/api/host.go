@@ -0,0 +1,97 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+)
+
+// FilteredHostResponse is a host lookup whose addresses were reduced to the
+// requested fields
+type FilteredHostResponse struct {
+	Host        string                   `json:"host"`
+	Chain       []geo.DNSRecord          `json:"chain"`
+	Addresses   []map[string]interface{} `json:"addresses"`
+	Attribution string                   `json:"attribution"`
+}
+
+// hostLookup serves /api/ip?host=: it resolves the hostname and geolocates
+// each address. Called by IPLookup, whose godoc documents the parameters.
+func (h *Handler) hostLookup(w http.ResponseWriter, r *http.Request, host string) {
+	if !h.enableOnlineFeatures {
+		writeError(w, http.StatusBadRequest, "Host lookups require online features")
+		return
+	}
+	query := r.URL.Query()
+	if query.Get("as_of") != "" {
+		writeError(w, http.StatusBadRequest, "as_of is not supported for host lookups")
+		return
+	}
+
+	var at time.Time
+	if atStr := query.Get("at"); atStr != "" {
+		var err error
+		if at, err = time.Parse(time.RFC3339, atStr); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid at time, expected RFC 3339")
+			return
+		}
+	}
+
+	opts := geo.HostOptions{
+		CNAME: queryBool(query.Get("cname")),
+		MX:    queryBool(query.Get("mx")),
+	}
+	result, err := h.geoReader.LookupHost(r.Context(), host, opts)
+	switch {
+	case errors.Is(err, geo.ErrInvalidHost):
+		writeError(w, http.StatusBadRequest, "Invalid hostname")
+		return
+	case errors.Is(err, geo.ErrHostNotFound):
+		writeError(w, http.StatusNotFound, "Hostname not found")
+		return
+	case errors.Is(err, geo.ErrOnlineFeaturesDisabled):
+		writeError(w, http.StatusBadRequest, "Host lookups require online features")
+		return
+	case err != nil:
+		writeError(w, http.StatusBadGateway, "Failed to resolve hostname")
+		return
+	}
+
+	if !at.IsZero() {
+		for _, a := range result.Addresses {
+			a.SetLocalTime(at)
+		}
+	}
+
+	returnFields := query["return"]
+	if len(returnFields) == 0 {
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+
+	fields := make([]string, len(returnFields))
+	for i, f := range returnFields {
+		fields[i] = strings.ToLower(f)
+	}
+	filtered := FilteredHostResponse{
+		Host:        result.Host,
+		Chain:       result.Chain,
+		Addresses:   make([]map[string]interface{}, len(result.Addresses)),
+		Attribution: result.Attribution,
+	}
+	for i, a := range result.Addresses {
+		filtered.Addresses[i] = a.FilterFields(fields)
+		filtered.Addresses[i]["name"] = a.Name
+	}
+	writeJSON(w, http.StatusOK, filtered)
+}
+
+// queryBool parses a boolean query parameter; anything unparsable is false
+func queryBool(s string) bool {
+	b, _ := strconv.ParseBool(s)
+	return b
+}
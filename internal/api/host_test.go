@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+)
+
+func setupOnlineRouter() *chi.Mux {
+	r := chi.NewRouter()
+	handler := NewHandler(&MockGeoReader{}, true)
+	handler.SetupRoutes(r)
+	return r
+}
+
+func TestHostLookup(t *testing.T) {
+	r := setupOnlineRouter()
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+		addresses      int
+		chain          int
+	}{
+		{name: "Host", url: "/api/ip?host=example.com", expectedStatus: http.StatusOK, addresses: 1, chain: 1},
+		{name: "Host with MX", url: "/api/ip?host=example.com&mx=true", expectedStatus: http.StatusOK, addresses: 2, chain: 3},
+		{name: "Unknown host", url: "/api/ip?host=nxdomain.example", expectedStatus: http.StatusNotFound},
+		{name: "as_of with host", url: "/api/ip?host=example.com&as_of=2024-06-01", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid at", url: "/api/ip?host=example.com&at=yesterday", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var resp geo.HostInfo
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Host != "example.com" || resp.Attribution == "" {
+				t.Errorf("unexpected response: %+v", resp)
+			}
+			if len(resp.Addresses) != tt.addresses || len(resp.Chain) != tt.chain {
+				t.Errorf("expected %d addresses and %d records, got %d and %d", tt.addresses, tt.chain, len(resp.Addresses), len(resp.Chain))
+			}
+			if resp.Addresses[0].Name != "example.com" || resp.Addresses[0].ISOCode != "US" {
+				t.Errorf("expected geolocated address for example.com, got %+v", resp.Addresses[0])
+			}
+		})
+	}
+}
+
+func TestHostLookupFields(t *testing.T) {
+	r := setupOnlineRouter()
+
+	req := httptest.NewRequest("GET", "/api/ip?host=example.com&return=country", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var resp FilteredHostResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	addr := resp.Addresses[0]
+	if addr["name"] != "example.com" || addr["country"] != "United States" {
+		t.Errorf("expected name and country, got %v", addr)
+	}
+	if _, ok := addr["city"]; ok {
+		t.Error("expected unrequested fields to be omitted")
+	}
+}
+
+func TestHostLookupOffline(t *testing.T) {
+	r := setupTestRouter()
+
+	req := httptest.NewRequest("GET", "/api/ip?host=example.com", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 without online features, got %d", w.Code)
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+	"github.com/jcjc-dev/ipwhere/internal/privacy"
+)
+
+// RequestLogger logs every request as a structured record once it has been
+// served. Besides the request and response it records the route pattern,
+// the ID assigned by middleware.RequestID and, for requests that looked up
+// addresses, the first address queried, the number of lookups and cache hits
+// and the time spent on reverse DNS. Server errors are logged at error level.
+//
+// With an anonymizer (privacy mode), addresses are truncated and, if hashing
+// is enabled, a salted hash of the client address is logged as client_hash.
+func RequestLogger(logger *slog.Logger, anon *privacy.Anonymizer) func(http.Handler) http.Handler {
+	redact := func(s string) string { return s }
+	if anon != nil {
+		redact = anon.Redact
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ctx, stats := geo.WithLookupStats(r.Context())
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			defer func() {
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+				attrs := []slog.Attr{
+					slog.String("method", r.Method),
+					slog.String("path", redact(r.URL.Path)),
+					slog.Int("status", status),
+					slog.Int("bytes", ww.BytesWritten()),
+					slog.Float64("latency_ms", milliseconds(time.Since(start))),
+					slog.String("remote_addr", redact(r.RemoteAddr)),
+				}
+				if anon != nil && anon.Hashing() {
+					if addr, err := netip.ParseAddr(getClientIP(r)); err == nil {
+						attrs = append(attrs, slog.String("client_hash", anon.Hash(addr)))
+					}
+				}
+				if id := middleware.GetReqID(ctx); id != "" {
+					attrs = append(attrs, slog.String("request_id", id))
+				}
+				if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+					attrs = append(attrs, slog.String("route", rctx.RoutePattern()))
+				}
+				if n := stats.Lookups(); n > 0 {
+					attrs = append(attrs,
+						slog.String("ip", redact(stats.IP())),
+						slog.Int("lookups", n),
+						slog.Int("cache_hits", stats.CacheHits()),
+					)
+				}
+				if d := stats.RDNS(); d > 0 {
+					attrs = append(attrs, slog.Float64("rdns_ms", milliseconds(d)))
+				}
+
+				level := slog.LevelInfo
+				if status >= http.StatusInternalServerError {
+					level = slog.LevelError
+				}
+				logger.LogAttrs(ctx, level, "request", attrs...)
+			}()
+
+			next.ServeHTTP(ww, r.WithContext(ctx))
+		})
+	}
+}
+
+// milliseconds converts d to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jcjc-dev/ipwhere/internal/privacy"
+)
+
+func TestRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewRouter(WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	r.Get("/api/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if chi.URLParam(r, "id") == "broken" {
+			writeError(w, http.StatusInternalServerError, "broken")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"ok": "yes"})
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		level  string
+		route  string
+	}{
+		{name: "Success", path: "/api/items/1", status: http.StatusOK, level: "INFO", route: "/api/items/{id}"},
+		{name: "Server error", path: "/api/items/broken", status: http.StatusInternalServerError, level: "ERROR", route: "/api/items/{id}"},
+		{name: "Not found", path: "/missing", status: http.StatusNotFound, level: "INFO"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("invalid log record %q: %v", buf.String(), err)
+			}
+			if record["level"] != tt.level || record["msg"] != "request" {
+				t.Errorf("level, msg = %v, %v; want %s, request", record["level"], record["msg"], tt.level)
+			}
+			if record["status"] != float64(tt.status) || record["method"] != "GET" || record["path"] != tt.path {
+				t.Errorf("status, method, path = %v, %v, %v", record["status"], record["method"], record["path"])
+			}
+			if route, _ := record["route"].(string); route != tt.route {
+				t.Errorf("route = %q, want %q", route, tt.route)
+			}
+			if id, _ := record["request_id"].(string); id == "" {
+				t.Error("request_id missing")
+			}
+			if _, ok := record["latency_ms"].(float64); !ok {
+				t.Error("latency_ms missing")
+			}
+			if record["remote_addr"] != "192.0.2.1:1234" {
+				t.Errorf("remote_addr = %v, want 192.0.2.1:1234", record["remote_addr"])
+			}
+			if _, ok := record["ip"]; ok {
+				t.Error("ip logged for a request without lookups")
+			}
+		})
+	}
+}
+
+func TestRequestLoggerPrivacy(t *testing.T) {
+	anon, err := privacy.New(privacy.Config{HashRotation: time.Hour})
+	if err != nil {
+		t.Fatalf("privacy.New() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	r := NewRouter(WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))), WithLogAnonymizer(anon))
+	r.Get("/api/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"ok": "yes"})
+	})
+
+	hashes := make(map[any]bool)
+	for _, remote := range []string{"81.2.69.142:1234", "81.2.69.142:5678", "81.2.69.143:1234"} {
+		buf.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/api/items/ip:2a00:1450:4009:81f::200e", nil)
+		req.RemoteAddr = remote
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		var record map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+			t.Fatalf("invalid log record %q: %v", buf.String(), err)
+		}
+		if strings.Contains(buf.String(), "81.2.69.14") || strings.Contains(buf.String(), "81f") {
+			t.Errorf("log record contains a full address: %s", buf.String())
+		}
+		if record["path"] != "/api/items/ip:2a00:1450:4009::" {
+			t.Errorf("path = %v", record["path"])
+		}
+		hashes[record["client_hash"]] = true
+	}
+	if len(hashes) != 2 {
+		t.Errorf("got %d distinct client hashes for 2 clients", len(hashes))
+	}
+}
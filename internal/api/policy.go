@@ -0,0 +1,52 @@
+package api
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jcjc-dev/ipwhere/internal/policy"
+)
+
+// Policy godoc
+// @Summary      Evaluate a geo policy
+// @Description  Looks up an IP address and evaluates the named allow/deny policy against it. Rules are evaluated in order; the first matching rule decides, otherwise the policy default applies and rule is "default".
+// @Tags         policy
+// @Produce      json
+// @Param        name  path      string  true   "Policy name"
+// @Param        ip    query     string  false  "IP address to evaluate (defaults to client IP)"
+// @Success      200   {object}  policy.Decision
+// @Failure      400   {object}  ErrorResponse
+// @Failure      404   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Router       /api/policy/{name} [get]
+func (h *Handler) Policy(w http.ResponseWriter, r *http.Request) {
+	if h.policies == nil {
+		writeError(w, http.StatusNotFound, "Unknown policy")
+		return
+	}
+
+	ipStr := r.URL.Query().Get("ip")
+	if ipStr == "" {
+		ipStr = getClientIP(r)
+		noStore(w)
+	}
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		writeError(w, http.StatusBadRequest, "Invalid IP address")
+		return
+	}
+
+	decision, err := h.policies.Evaluate(r.Context(), h.geoReader, chi.URLParam(r, "name"), ip)
+	if errors.Is(err, policy.ErrUnknownPolicy) {
+		writeError(w, http.StatusNotFound, "Unknown policy")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to lookup IP")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, decision)
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jcjc-dev/ipwhere/internal/policy"
+)
+
+// loadTestPolicies loads policies matching the MockGeoReader lookup result
+func loadTestPolicies(t *testing.T) *policy.Engine {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policies.json")
+	data := `{"policies": {
+		"us-only": {"default": "deny", "rules": [{"name": "united-states", "action": "allow", "countries": ["US"]}]},
+		"no-google": {"rules": [{"name": "google", "action": "deny", "asns": [15169]}]}
+	}}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := policy.Load(path)
+	if err != nil {
+		t.Fatalf("failed to load policies: %v", err)
+	}
+	return engine
+}
+
+func setupPolicyRouter(t *testing.T) *chi.Mux {
+	t.Helper()
+	r := chi.NewRouter()
+	NewHandler(&MockGeoReader{}, false, WithPolicies(loadTestPolicies(t))).SetupRoutes(r)
+	return r
+}
+
+func TestPolicy(t *testing.T) {
+	r := setupPolicyRouter(t)
+
+	tests := []struct {
+		name             string
+		url              string
+		expectedStatus   int
+		expectedDecision policy.Action
+		expectedRule     string
+	}{
+		{
+			name:             "allow by country",
+			url:              "/api/policy/us-only?ip=8.8.8.8",
+			expectedStatus:   http.StatusOK,
+			expectedDecision: policy.Allow,
+			expectedRule:     "united-states",
+		},
+		{
+			name:             "deny by ASN",
+			url:              "/api/policy/no-google?ip=8.8.8.8",
+			expectedStatus:   http.StatusOK,
+			expectedDecision: policy.Deny,
+			expectedRule:     "google",
+		},
+		{
+			name:             "client IP",
+			url:              "/api/policy/us-only",
+			expectedStatus:   http.StatusOK,
+			expectedDecision: policy.Allow,
+			expectedRule:     "united-states",
+		},
+		{
+			name:           "invalid IP",
+			url:            "/api/policy/us-only?ip=invalid",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unknown policy",
+			url:            "/api/policy/missing?ip=8.8.8.8",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var resp policy.Decision
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			if resp.Decision != tt.expectedDecision || resp.Rule != tt.expectedRule {
+				t.Errorf("expected %s by %q, got %s by %q", tt.expectedDecision, tt.expectedRule, resp.Decision, resp.Rule)
+			}
+		})
+	}
+}
+
+func TestPolicyNotConfigured(t *testing.T) {
+	r := setupTestRouter()
+
+	req := httptest.NewRequest("GET", "/api/policy/us-only?ip=8.8.8.8", nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+	"github.com/jcjc-dev/ipwhere/internal/policy"
+)
+
+// proxyInfoKey is the context key for the lookup result of a proxied request
+type proxyInfoKey struct{}
+
+// decisionKey is the context key for the policy decision of a proxied request
+type decisionKey struct{}
+
+type decision struct {
+	action policy.Action
+	rule   string
+}
+
+// Proxy is a reverse proxy that adds geolocation headers to requests before
+// forwarding them upstream, for applications that cannot call the API
+type Proxy struct {
+	geoReader  geo.ReaderInterface
+	headers    GeoHeaders
+	trusted    TrustedProxies
+	policies   *policy.Engine
+	policyName string
+	proxy      *httputil.ReverseProxy
+}
+
+// ProxyOption configures optional Proxy behaviour
+type ProxyOption func(*Proxy)
+
+// WithProxyHeaders sets the geolocation header names
+func WithProxyHeaders(headers GeoHeaders) ProxyOption {
+	return func(p *Proxy) {
+		p.headers = headers
+	}
+}
+
+// WithProxyTrustedProxies resolves the client IP from forwarding headers set
+// by the given proxies. By default the peer address is the client.
+func WithProxyTrustedProxies(t TrustedProxies) ProxyOption {
+	return func(p *Proxy) {
+		p.trusted = t
+	}
+}
+
+// WithProxyPolicy rejects requests denied by the named policy with 403
+func WithProxyPolicy(e *policy.Engine, name string) ProxyOption {
+	return func(p *Proxy) {
+		p.policies = e
+		p.policyName = name
+	}
+}
+
+// NewProxy creates a reverse proxy forwarding to upstream
+func NewProxy(geoReader geo.ReaderInterface, upstream *url.URL, opts ...ProxyOption) *Proxy {
+	p := &Proxy{
+		geoReader: geoReader,
+		headers:   DefaultGeoHeaders(),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	p.proxy = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(upstream)
+
+			// Keep the forwarding chain only when it comes from a trusted proxy
+			peer, _, _ := net.SplitHostPort(pr.In.RemoteAddr)
+			if p.trusted.trusts(peer) {
+				pr.Out.Header["X-Forwarded-For"] = pr.In.Header["X-Forwarded-For"]
+			}
+			pr.SetXForwarded()
+
+			// Never pass on geolocation headers supplied by the client
+			p.headers.Strip(pr.Out.Header)
+			if info, ok := pr.In.Context().Value(proxyInfoKey{}).(*geo.IPInfo); ok {
+				p.headers.Set(pr.Out.Header, info)
+			}
+			if d, ok := pr.In.Context().Value(decisionKey{}).(decision); ok {
+				p.headers.SetDecision(pr.Out.Header, d.action, d.rule)
+			}
+		},
+	}
+	return p
+}
+
+// ServeHTTP looks up the client, applies the policy and forwards the request
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var pol *policy.Policy
+	if p.policies != nil {
+		var ok bool
+		if pol, ok = p.policies.Policy(p.policyName); !ok {
+			writeError(w, http.StatusInternalServerError, "Unknown policy")
+			return
+		}
+	}
+
+	ctx := r.Context()
+	var info *geo.IPInfo
+	if ip := net.ParseIP(p.trusted.ClientIP(r)); ip != nil {
+		var err error
+		if info, err = p.geoReader.Lookup(ctx, ip); err != nil {
+			slog.WarnContext(ctx, "Proxy lookup failed", "error", err, "request_id", middleware.GetReqID(ctx))
+			info = nil
+		}
+	}
+	if info != nil {
+		ctx = context.WithValue(ctx, proxyInfoKey{}, info)
+	}
+
+	if pol != nil {
+		// Without a lookup result the policy cannot be applied, so fail closed
+		if info == nil {
+			writeError(w, http.StatusInternalServerError, "Failed to lookup IP")
+			return
+		}
+		action, rule := pol.Evaluate(info)
+		if action == policy.Deny {
+			p.headers.SetDecision(w.Header(), action, rule)
+			writeError(w, http.StatusForbidden, "Access denied")
+			return
+		}
+		ctx = context.WithValue(ctx, decisionKey{}, decision{action: action, rule: rule})
+	}
+
+	p.proxy.ServeHTTP(w, r.WithContext(ctx))
+}
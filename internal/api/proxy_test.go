@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// echoUpstream responds with the headers it received
+func echoUpstream(t *testing.T) *url.URL {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(r.Header)
+	}))
+	t.Cleanup(srv.Close)
+	u, _ := url.Parse(srv.URL)
+	return u
+}
+
+func proxyRequest(t *testing.T, p *Proxy, remoteAddr string, headers map[string]string) (*httptest.ResponseRecorder, http.Header) {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/app", nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+
+	var upstream http.Header
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &upstream); err != nil {
+			t.Fatalf("failed to parse upstream response: %v", err)
+		}
+	}
+	return w, upstream
+}
+
+func TestProxyHeaders(t *testing.T) {
+	p := NewProxy(&MockGeoReader{}, echoUpstream(t))
+
+	w, upstream := proxyRequest(t, p, "8.8.8.8:1234", map[string]string{
+		"X-Geo-Country":   "FR",
+		"X-Geo-Rule":      "spoofed",
+		"X-Forwarded-For": "1.2.3.4",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	expected := map[string]string{
+		"X-Geo-Ip":        "8.8.8.8",
+		"X-Geo-Country":   "US",
+		"X-Geo-City":      "Mountain View",
+		"X-Geo-Asn":       "15169",
+		"X-Geo-Org":       "Google LLC",
+		"X-Geo-Latitude":  "37.4056",
+		"X-Geo-Longitude": "-122.0775",
+		"X-Forwarded-For": "8.8.8.8",
+	}
+	for name, value := range expected {
+		if got := upstream.Get(name); got != value {
+			t.Errorf("expected %s %q, got %q", name, value, got)
+		}
+	}
+	if got := upstream.Values("X-Geo-Country"); len(got) != 1 {
+		t.Errorf("expected a single X-Geo-Country header, got %v", got)
+	}
+	if got := upstream.Get("X-Geo-Rule"); got != "" {
+		t.Errorf("expected spoofed X-Geo-Rule to be stripped, got %q", got)
+	}
+}
+
+func TestProxyRenamedHeaders(t *testing.T) {
+	headers := DefaultGeoHeaders()
+	for _, spec := range []string{"country=CF-IPCountry", "city="} {
+		if err := headers.Rename(spec); err != nil {
+			t.Fatalf("Rename(%q) failed: %v", spec, err)
+		}
+	}
+	if err := headers.Rename("postcode=X-Postcode"); err == nil {
+		t.Error("expected an unknown field to fail")
+	}
+
+	p := NewProxy(&MockGeoReader{}, echoUpstream(t), WithProxyHeaders(headers))
+	_, upstream := proxyRequest(t, p, "8.8.8.8:1234", map[string]string{
+		"Cf-Ipcountry":  "FR",
+		"X-Geo-Country": "FR",
+		"X-Geo-City":    "Paris",
+	})
+
+	if got := upstream.Get("Cf-Ipcountry"); got != "US" {
+		t.Errorf("expected CF-IPCountry US, got %q", got)
+	}
+	if got := upstream.Get("X-Geo-Country"); got != "" {
+		t.Errorf("expected the spoofed default country header to be stripped, got %q", got)
+	}
+	if got := upstream.Get("X-Geo-City"); got != "" {
+		t.Errorf("expected the spoofed disabled city header to be stripped, got %q", got)
+	}
+}
+
+func TestProxyTrustedProxies(t *testing.T) {
+	trusted, _ := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	p := NewProxy(&MockGeoReader{}, echoUpstream(t), WithProxyTrustedProxies(trusted))
+
+	_, upstream := proxyRequest(t, p, "10.0.0.5:1234", map[string]string{"X-Forwarded-For": "8.8.4.4"})
+	if got := upstream.Get("X-Geo-Ip"); got != "8.8.4.4" {
+		t.Errorf("expected client IP from trusted proxy, got %q", got)
+	}
+	if got := upstream.Get("X-Forwarded-For"); got != "8.8.4.4, 10.0.0.5" {
+		t.Errorf("expected forwarding chain to be kept, got %q", got)
+	}
+}
+
+func TestProxyPolicy(t *testing.T) {
+	engine := loadTestPolicies(t)
+	upstream := echoUpstream(t)
+
+	allowed := NewProxy(&MockGeoReader{}, upstream, WithProxyPolicy(engine, "us-only"))
+	w, headers := proxyRequest(t, allowed, "8.8.8.8:1234", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if headers.Get("X-Geo-Decision") != "allow" || headers.Get("X-Geo-Rule") != "united-states" {
+		t.Errorf("unexpected decision headers: %v", headers)
+	}
+
+	denied := NewProxy(&MockGeoReader{}, upstream, WithProxyPolicy(engine, "no-google"))
+	w, _ = proxyRequest(t, denied, "8.8.8.8:1234", nil)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", w.Code)
+	}
+	if got := w.Header().Get("X-Geo-Rule"); got != "google" {
+		t.Errorf("expected deciding rule header, got %q", got)
+	}
+}
@@ -1,23 +1,65 @@
 package api
 
 import (
+	"log/slog"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/jcjc-dev/ipwhere/internal/privacy"
 )
 
+// RouterOption configures optional router behaviour
+type RouterOption func(*routerConfig)
+
+type routerConfig struct {
+	trustedProxies TrustedProxies
+	logger         *slog.Logger
+	anonymizer     *privacy.Anonymizer
+}
+
+// WithTrustedProxies only honours forwarding headers set by the given proxies
+func WithTrustedProxies(t TrustedProxies) RouterOption {
+	return func(c *routerConfig) {
+		c.trustedProxies = t
+	}
+}
+
+// WithLogger sets the logger for request logs (default slog.Default())
+func WithLogger(l *slog.Logger) RouterOption {
+	return func(c *routerConfig) {
+		c.logger = l
+	}
+}
+
+// WithLogAnonymizer truncates (and optionally hashes) addresses in request logs
+func WithLogAnonymizer(a *privacy.Anonymizer) RouterOption {
+	return func(c *routerConfig) {
+		c.anonymizer = a
+	}
+}
+
 // SetupMiddleware configures common middleware for the router
-func SetupMiddleware(r *chi.Mux) {
+func SetupMiddleware(r *chi.Mux, opts ...RouterOption) {
+	cfg := routerConfig{logger: slog.Default()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
 	// Request ID
 	r.Use(middleware.RequestID)
 
-	// Real IP (for proxies)
-	r.Use(middleware.RealIP)
+	// Real IP (for proxies); the peer address is kept for forward auth
+	r.Use(recordPeer)
+	if len(cfg.trustedProxies) > 0 {
+		r.Use(cfg.trustedProxies.RealIP)
+	} else {
+		r.Use(middleware.RealIP)
+	}
 
 	// Logger
-	r.Use(middleware.Logger)
+	r.Use(RequestLogger(cfg.logger, cfg.anonymizer))
 
 	// Recoverer
 	r.Use(middleware.Recoverer)
@@ -28,7 +70,7 @@ func SetupMiddleware(r *chi.Mux) {
 	// CORS
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Content-Type"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: false,
@@ -37,8 +79,8 @@ func SetupMiddleware(r *chi.Mux) {
 }
 
 // NewRouter creates and configures a new chi router
-func NewRouter() *chi.Mux {
+func NewRouter(opts ...RouterOption) *chi.Mux {
 	r := chi.NewRouter()
-	SetupMiddleware(r)
+	SetupMiddleware(r, opts...)
 	return r
 }
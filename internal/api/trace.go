@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/jcjc-dev/ipwhere/internal/mailtrace"
+)
+
+// maxTraceBodyBytes limits the size of an email trace request body
+const maxTraceBodyBytes = 1 << 20
+
+// EmailTraceRequest is the JSON request body for email header tracing
+type EmailTraceRequest struct {
+	Headers string `json:"headers"`
+}
+
+// TraceEmail godoc
+// @Summary      Trace email headers
+// @Description  Parses the Received headers of an email, oldest hop first, geolocates each public relay IP and reports timestamps, delays between hops and the likely originating IP. Send the raw headers as text/plain, or JSON with a headers field. A full message may be sent; parsing stops at the body.
+// @Tags         lookup
+// @Accept       plain
+// @Accept       json
+// @Produce      json
+// @Param        request  body      EmailTraceRequest  true  "Raw email headers"
+// @Success      200      {object}  mailtrace.Trace
+// @Failure      400      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Router       /api/trace/email [post]
+func (h *Handler) TraceEmail(w http.ResponseWriter, r *http.Request) {
+	body := http.MaxBytesReader(w, r.Body, maxTraceBodyBytes)
+
+	var headers io.Reader = body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		var req EmailTraceRequest
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		headers = strings.NewReader(req.Headers)
+	}
+
+	trace, err := mailtrace.Parse(headers)
+	if errors.Is(err, mailtrace.ErrNoReceivedHeaders) {
+		writeError(w, http.StatusBadRequest, "No Received headers found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := trace.Geolocate(r.Context(), h.geoReader); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to lookup IP")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, trace)
+}
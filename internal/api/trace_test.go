@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jcjc-dev/ipwhere/internal/mailtrace"
+)
+
+const traceHeaders = "Received: from mx.example.net (mx.example.net [8.8.4.4]) by relay.example.net; Tue, 2 Jan 2024 18:00:07 +0000\n" +
+	"Received: from sender.example.org (sender.example.org [81.2.69.142]) by mx.example.net; Tue, 2 Jan 2024 18:00:05 +0000\n" +
+	"Received: from laptop (unknown [192.168.1.20]) by sender.example.org; Tue, 2 Jan 2024 18:00:00 +0000\n" +
+	"Subject: test\n"
+
+func TestTraceEmail(t *testing.T) {
+	r := setupTestRouter()
+
+	jsonBody, _ := json.Marshal(EmailTraceRequest{Headers: traceHeaders})
+
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Plain text", contentType: "text/plain", body: traceHeaders, expectedStatus: http.StatusOK},
+		{name: "JSON", contentType: "application/json; charset=utf-8", body: string(jsonBody), expectedStatus: http.StatusOK},
+		{name: "No Received headers", contentType: "text/plain", body: "Subject: hi\n", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid JSON", contentType: "application/json", body: "{", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/trace/email", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var trace mailtrace.Trace
+			if err := json.NewDecoder(w.Body).Decode(&trace); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(trace.Hops) != 3 {
+				t.Fatalf("expected 3 hops, got %d", len(trace.Hops))
+			}
+			if trace.Hops[0].Info != nil || !trace.Hops[0].Private {
+				t.Error("expected the private first hop not to be geolocated")
+			}
+			if trace.Hops[1].Info == nil || trace.Hops[1].Info.ISOCode != "US" {
+				t.Errorf("expected hop 2 to be geolocated, got %+v", trace.Hops[1].Info)
+			}
+			if trace.OriginIP != "81.2.69.142" || trace.Origin == nil {
+				t.Errorf("expected origin 81.2.69.142, got %q", trace.OriginIP)
+			}
+			if trace.TotalDelaySeconds == nil || *trace.TotalDelaySeconds != 7 {
+				t.Errorf("expected total delay 7s, got %v", trace.TotalDelaySeconds)
+			}
+		})
+	}
+}
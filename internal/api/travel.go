@@ -0,0 +1,258 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+)
+
+const (
+	// defaultMaxSpeedKmh is the default travel speed threshold, slightly
+	// above the cruising speed of a commercial airliner
+	defaultMaxSpeedKmh = 1000
+	// maxTravelEvents limits the number of events accepted per request
+	maxTravelEvents = 1000
+	// maxTravelBodyBytes limits the size of a travel request body
+	maxTravelBodyBytes = 1 << 20
+)
+
+// Travel verdicts
+const (
+	VerdictOK         = "ok"
+	VerdictSuspicious = "suspicious"
+	VerdictImpossible = "impossible"
+	VerdictUnknown    = "unknown"
+)
+
+// TravelEvent is a single sign-in or activity event
+type TravelEvent struct {
+	IP        string    `json:"ip"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// TravelRequest is the request body for impossible-travel detection
+type TravelRequest struct {
+	Events      []TravelEvent `json:"events"`
+	MaxSpeedKmh float64       `json:"max_speed_kmh,omitempty"`
+}
+
+// TravelLocation is a geolocated event
+type TravelLocation struct {
+	IP             string    `json:"ip"`
+	Timestamp      time.Time `json:"timestamp"`
+	Country        string    `json:"country,omitempty"`
+	City           string    `json:"city,omitempty"`
+	Latitude       *float64  `json:"latitude,omitempty"`
+	Longitude      *float64  `json:"longitude,omitempty"`
+	AccuracyRadius uint16    `json:"accuracy_radius,omitempty"`
+	ASN            *uint     `json:"asn,omitempty"`
+	Organization   string    `json:"organization,omitempty"`
+}
+
+// TravelLeg describes the movement between two consecutive events.
+// MinSpeedKmh is derived from the minimum distance allowed by the accuracy
+// radius of both locations and is the value compared against the threshold.
+type TravelLeg struct {
+	From           TravelLocation `json:"from"`
+	To             TravelLocation `json:"to"`
+	DistanceKm     *float64       `json:"distance_km,omitempty"`
+	MinDistanceKm  *float64       `json:"min_distance_km,omitempty"`
+	ElapsedSeconds float64        `json:"elapsed_seconds"`
+	SpeedKmh       *float64       `json:"speed_kmh,omitempty"`
+	MinSpeedKmh    *float64       `json:"min_speed_kmh,omitempty"`
+	SameASN        bool           `json:"same_asn"`
+	Verdict        string         `json:"verdict"`
+	Reason         string         `json:"reason,omitempty"`
+}
+
+// TravelResponse is the result of impossible-travel detection. Flagged is
+// set only if at least one leg is impossible.
+type TravelResponse struct {
+	MaxSpeedKmh float64     `json:"max_speed_kmh"`
+	Legs        []TravelLeg `json:"legs"`
+	Flagged     bool        `json:"flagged"`
+	Attribution string      `json:"attribution"`
+}
+
+// Travel godoc
+// @Summary      Impossible-travel detection
+// @Description  Geolocates a sequence of (ip, timestamp) events for one user and flags consecutive pairs whose implied travel speed exceeds the threshold. Accuracy radius and shared ASN are taken into account to reduce false positives.
+// @Tags         lookup
+// @Accept       json
+// @Produce      json
+// @Param        request  body      TravelRequest  true  "Events to evaluate"
+// @Success      200      {object}  TravelResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Router       /api/travel [post]
+func (h *Handler) Travel(w http.ResponseWriter, r *http.Request) {
+	var req TravelRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxTravelBodyBytes)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(req.Events) < 2 {
+		writeError(w, http.StatusBadRequest, "At least two events are required")
+		return
+	}
+	if len(req.Events) > maxTravelEvents {
+		writeError(w, http.StatusBadRequest, "Too many events")
+		return
+	}
+	if req.MaxSpeedKmh < 0 {
+		writeError(w, http.StatusBadRequest, "Invalid max_speed_kmh")
+		return
+	}
+	if req.MaxSpeedKmh == 0 {
+		req.MaxSpeedKmh = defaultMaxSpeedKmh
+	}
+
+	// Geolocate each distinct IP once
+	infos := make(map[string]*geo.IPInfo, len(req.Events))
+	for _, e := range req.Events {
+		ip := net.ParseIP(e.IP)
+		if ip == nil {
+			writeError(w, http.StatusBadRequest, "Invalid IP address: "+e.IP)
+			return
+		}
+		if e.Timestamp.IsZero() {
+			writeError(w, http.StatusBadRequest, "Missing timestamp for "+e.IP)
+			return
+		}
+		if _, ok := infos[e.IP]; ok {
+			continue
+		}
+		info, err := h.geoReader.Lookup(r.Context(), ip)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to lookup IP")
+			return
+		}
+		infos[e.IP] = info
+	}
+
+	legs := evaluateTravel(req.Events, infos, req.MaxSpeedKmh)
+
+	writeJSON(w, http.StatusOK, TravelResponse{
+		MaxSpeedKmh: req.MaxSpeedKmh,
+		Legs:        legs,
+		Flagged:     travelFlagged(legs),
+		Attribution: geo.Attribution,
+	})
+}
+
+// travelFlagged reports whether any leg is impossible. Suspicious legs are
+// left to the caller so the same-ASN heuristic actually reduces false positives.
+func travelFlagged(legs []TravelLeg) bool {
+	for _, leg := range legs {
+		if leg.Verdict == VerdictImpossible {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluateTravel orders events by time and computes a verdict for each
+// consecutive pair
+func evaluateTravel(events []TravelEvent, infos map[string]*geo.IPInfo, maxSpeedKmh float64) []TravelLeg {
+	sorted := make([]TravelEvent, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	legs := make([]TravelLeg, 0, len(sorted)-1)
+	for i := 1; i < len(sorted); i++ {
+		legs = append(legs, evaluateLeg(sorted[i-1], sorted[i], infos, maxSpeedKmh))
+	}
+	return legs
+}
+
+// evaluateLeg computes distance, speed and verdict between two events
+func evaluateLeg(fromEvent, toEvent TravelEvent, infos map[string]*geo.IPInfo, maxSpeedKmh float64) TravelLeg {
+	fromInfo, toInfo := infos[fromEvent.IP], infos[toEvent.IP]
+	leg := TravelLeg{
+		From:           travelLocation(fromEvent, fromInfo),
+		To:             travelLocation(toEvent, toInfo),
+		ElapsedSeconds: toEvent.Timestamp.Sub(fromEvent.Timestamp).Seconds(),
+		SameASN:        fromInfo.ASN != nil && toInfo.ASN != nil && *fromInfo.ASN == *toInfo.ASN,
+	}
+
+	if fromEvent.IP == toEvent.IP {
+		leg.Verdict = VerdictOK
+		leg.Reason = "same IP address"
+		return leg
+	}
+
+	fromPoint, err := geo.PointFromInfo(fromInfo)
+	if err != nil {
+		leg.Verdict = VerdictUnknown
+		leg.Reason = "no location data for " + fromEvent.IP
+		return leg
+	}
+	toPoint, err := geo.PointFromInfo(toInfo)
+	if err != nil {
+		leg.Verdict = VerdictUnknown
+		leg.Reason = "no location data for " + toEvent.IP
+		return leg
+	}
+
+	d := geo.Measure(fromPoint, toPoint, toEvent.Timestamp)
+	leg.DistanceKm = &d.Kilometers
+	leg.MinDistanceKm = &d.MinKilometers
+
+	speed := travelSpeed(d.Kilometers, leg.ElapsedSeconds)
+	minSpeed := travelSpeed(d.MinKilometers, leg.ElapsedSeconds)
+	if !math.IsInf(speed, 1) {
+		leg.SpeedKmh = &speed
+	}
+	if !math.IsInf(minSpeed, 1) {
+		leg.MinSpeedKmh = &minSpeed
+	}
+
+	switch {
+	case minSpeed <= maxSpeedKmh:
+		leg.Verdict = VerdictOK
+		if speed > maxSpeedKmh {
+			leg.Reason = "within accuracy radius"
+		}
+	case leg.SameASN:
+		leg.Verdict = VerdictSuspicious
+		leg.Reason = "same ASN, possibly geolocation variance within one network"
+	default:
+		leg.Verdict = VerdictImpossible
+		leg.Reason = "implied speed exceeds threshold"
+	}
+	return leg
+}
+
+// travelSpeed returns the speed in km/h needed to cover km in the given
+// number of seconds. Covering a non-zero distance in no time yields +Inf.
+func travelSpeed(km, seconds float64) float64 {
+	if km == 0 {
+		return 0
+	}
+	if seconds <= 0 {
+		return math.Inf(1)
+	}
+	return math.Round(km/(seconds/3600)*100) / 100
+}
+
+// travelLocation combines an event with its lookup result
+func travelLocation(e TravelEvent, info *geo.IPInfo) TravelLocation {
+	return TravelLocation{
+		IP:             e.IP,
+		Timestamp:      e.Timestamp,
+		Country:        info.Country,
+		City:           info.City,
+		Latitude:       info.Latitude,
+		Longitude:      info.Longitude,
+		AccuracyRadius: info.AccuracyRadius,
+		ASN:            info.ASN,
+		Organization:   info.Organization,
+	}
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+)
+
+func travelInfo(ip string, lat, lon float64, radius uint16, asn uint) *geo.IPInfo {
+	return &geo.IPInfo{
+		IP:             ip,
+		Latitude:       &lat,
+		Longitude:      &lon,
+		AccuracyRadius: radius,
+		ASN:            &asn,
+		Attribution:    geo.Attribution,
+	}
+}
+
+func TestEvaluateTravel(t *testing.T) {
+	infos := map[string]*geo.IPInfo{
+		"192.0.2.1":    travelInfo("192.0.2.1", 51.5074, -0.1278, 0, 100),   // London
+		"192.0.2.2":    travelInfo("192.0.2.2", 48.8566, 2.3522, 0, 200),    // Paris
+		"192.0.2.3":    travelInfo("192.0.2.3", 40.7128, -74.0060, 0, 300),  // New York
+		"192.0.2.4":    travelInfo("192.0.2.4", 40.7128, -74.0060, 0, 100),  // New York, same ASN as London
+		"192.0.2.5":    travelInfo("192.0.2.5", 48.8566, 2.3522, 200, 200),  // Paris, coarse location
+		"192.0.2.6":    travelInfo("192.0.2.6", 51.5074, -0.1278, 200, 300), // London, coarse location
+		"198.51.100.1": {IP: "198.51.100.1", Attribution: geo.Attribution},  // No location
+	}
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		from, to string
+		elapsed  time.Duration
+		expected string
+	}{
+		{name: "plausible flight", from: "192.0.2.1", to: "192.0.2.2", elapsed: 2 * time.Hour, expected: VerdictOK},
+		{name: "impossible travel", from: "192.0.2.1", to: "192.0.2.3", elapsed: time.Hour, expected: VerdictImpossible},
+		{name: "same ASN", from: "192.0.2.1", to: "192.0.2.4", elapsed: time.Hour, expected: VerdictSuspicious},
+		{name: "within accuracy radius", from: "192.0.2.5", to: "192.0.2.6", elapsed: 10 * time.Minute, expected: VerdictOK},
+		{name: "same IP", from: "192.0.2.1", to: "192.0.2.1", elapsed: 0, expected: VerdictOK},
+		{name: "simultaneous", from: "192.0.2.1", to: "192.0.2.2", elapsed: 0, expected: VerdictImpossible},
+		{name: "no location", from: "192.0.2.1", to: "198.51.100.1", elapsed: time.Hour, expected: VerdictUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := []TravelEvent{
+				{IP: tt.from, Timestamp: start},
+				{IP: tt.to, Timestamp: start.Add(tt.elapsed)},
+			}
+			legs := evaluateTravel(events, infos, defaultMaxSpeedKmh)
+			if len(legs) != 1 {
+				t.Fatalf("expected 1 leg, got %d", len(legs))
+			}
+			if legs[0].Verdict != tt.expected {
+				t.Errorf("expected verdict %s, got %s (%s)", tt.expected, legs[0].Verdict, legs[0].Reason)
+			}
+		})
+	}
+}
+
+func TestEvaluateTravelOrdersEvents(t *testing.T) {
+	infos := map[string]*geo.IPInfo{
+		"192.0.2.1": travelInfo("192.0.2.1", 51.5074, -0.1278, 0, 100),
+		"192.0.2.2": travelInfo("192.0.2.2", 48.8566, 2.3522, 0, 200),
+	}
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	events := []TravelEvent{
+		{IP: "192.0.2.2", Timestamp: start.Add(3 * time.Hour)},
+		{IP: "192.0.2.1", Timestamp: start},
+	}
+
+	legs := evaluateTravel(events, infos, defaultMaxSpeedKmh)
+	if legs[0].From.IP != "192.0.2.1" || legs[0].ElapsedSeconds != 3*3600 {
+		t.Errorf("expected events to be ordered by timestamp, got leg %+v", legs[0])
+	}
+}
+
+func TestTravelFlagged(t *testing.T) {
+	tests := []struct {
+		name     string
+		verdicts []string
+		expected bool
+	}{
+		{name: "all ok", verdicts: []string{VerdictOK, VerdictOK}, expected: false},
+		{name: "suspicious", verdicts: []string{VerdictOK, VerdictSuspicious}, expected: false},
+		{name: "unknown", verdicts: []string{VerdictUnknown}, expected: false},
+		{name: "impossible", verdicts: []string{VerdictSuspicious, VerdictImpossible}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			legs := make([]TravelLeg, len(tt.verdicts))
+			for i, v := range tt.verdicts {
+				legs[i].Verdict = v
+			}
+			if got := travelFlagged(legs); got != tt.expected {
+				t.Errorf("expected flagged %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTravel(t *testing.T) {
+	r := setupTestRouter()
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "valid events",
+			body:           `{"events":[{"ip":"8.8.8.8","timestamp":"2024-01-01T00:00:00Z"},{"ip":"8.8.4.4","timestamp":"2024-01-01T01:00:00Z"}]}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "single event",
+			body:           `{"events":[{"ip":"8.8.8.8","timestamp":"2024-01-01T00:00:00Z"}]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid IP",
+			body:           `{"events":[{"ip":"8.8.8.8","timestamp":"2024-01-01T00:00:00Z"},{"ip":"invalid","timestamp":"2024-01-01T01:00:00Z"}]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing timestamp",
+			body:           `{"events":[{"ip":"8.8.8.8","timestamp":"2024-01-01T00:00:00Z"},{"ip":"8.8.4.4"}]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "malformed body",
+			body:           `{"events":`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/travel", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			if w.Code == http.StatusOK {
+				var resp TravelResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("failed to parse response: %v", err)
+				}
+				if len(resp.Legs) != 1 || resp.Legs[0].Verdict != VerdictOK {
+					t.Errorf("expected one ok leg, got %+v", resp.Legs)
+				}
+				if resp.MaxSpeedKmh != defaultMaxSpeedKmh {
+					t.Errorf("expected default max speed, got %v", resp.MaxSpeedKmh)
+				}
+			}
+		})
+	}
+}
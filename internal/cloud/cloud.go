@@ -0,0 +1,205 @@
+// Package cloud indexes the IP ranges published by cloud providers so that
+// addresses can be tagged with the provider, region and service they belong to.
+//
+// Range files are read from local paths; downloading them is left to the
+// operator (e.g. a cron job or init container).
+package cloud
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jcjc-dev/ipwhere/internal/netindex"
+)
+
+// Range describes the cloud network an address belongs to
+type Range struct {
+	Provider string `json:"provider"`
+	Region   string `json:"region,omitempty"`
+	Service  string `json:"service,omitempty"`
+}
+
+// Source is a provider range file on disk
+type Source struct {
+	Provider string
+	Path     string
+}
+
+// SourceInfo reports the state of a loaded range file
+type SourceInfo struct {
+	Provider   string    `json:"provider"`
+	File       string    `json:"file"`
+	Prefixes   int       `json:"prefixes"`
+	Modified   time.Time `json:"modified"`
+	LoadedAt   time.Time `json:"loaded_at"`
+	AgeSeconds int64     `json:"age_seconds"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// ParseSource parses a "provider=path" specification
+func ParseSource(spec string) (Source, error) {
+	provider, path, ok := strings.Cut(spec, "=")
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	path = strings.TrimSpace(path)
+	if !ok || path == "" {
+		return Source{}, fmt.Errorf("invalid cloud range source %q, expected provider=path", spec)
+	}
+	if _, ok := parsers[provider]; !ok {
+		return Source{}, fmt.Errorf("unknown cloud provider %q", provider)
+	}
+	return Source{Provider: provider, Path: path}, nil
+}
+
+// Ranges is a reloadable index of cloud provider IP ranges
+type Ranges struct {
+	sources []Source
+
+	mu      sync.RWMutex
+	index   *netindex.Index[Range]
+	info    []SourceInfo
+	modTime map[string]time.Time
+}
+
+// Load reads and indexes the given range files
+func Load(sources []Source) (*Ranges, error) {
+	r := &Ranges{sources: sources}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload re-reads the range files if any of them changed on disk.
+// On failure the previously loaded ranges stay in use.
+func (r *Ranges) Reload() error {
+	changed, err := r.changed()
+	if err != nil || !changed {
+		return err
+	}
+	return r.load()
+}
+
+// changed reports whether any source file was modified since it was loaded
+func (r *Ranges) changed() (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, s := range r.sources {
+		st, err := os.Stat(s.Path)
+		if err != nil {
+			return false, fmt.Errorf("failed to stat %s ranges: %w", s.Provider, err)
+		}
+		if !st.ModTime().Equal(r.modTime[s.Path]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// load parses all sources into a new index and swaps it in
+func (r *Ranges) load() error {
+	index := netindex.New[Range]()
+	info := make([]SourceInfo, 0, len(r.sources))
+	modTime := make(map[string]time.Time, len(r.sources))
+	now := time.Now()
+
+	var errs []error
+	for _, s := range r.sources {
+		si := SourceInfo{Provider: s.Provider, File: filepath.Base(s.Path), LoadedAt: now}
+
+		entries, mod, err := readSource(s)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		// Prefixes are unique within a file; across files the earlier
+		// source wins on lookup
+		for p, e := range entries {
+			index.Insert(p, e.Range)
+		}
+
+		si.Prefixes = len(entries)
+		si.Modified = mod
+		modTime[s.Path] = mod
+		info = append(info, si)
+	}
+	if len(errs) > 0 {
+		r.recordError(errors.Join(errs...))
+		return errors.Join(errs...)
+	}
+
+	r.mu.Lock()
+	r.index = index
+	r.info = info
+	r.modTime = modTime
+	r.mu.Unlock()
+	return nil
+}
+
+// recordError attaches a reload error to the currently loaded sources
+func (r *Ranges) recordError(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.info {
+		r.info[i].Error = err.Error()
+	}
+}
+
+// readSource parses a single range file
+func readSource(s Source) (map[netip.Prefix]scored, time.Time, error) {
+	st, err := os.Stat(s.Path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read %s ranges: %w", s.Provider, err)
+	}
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read %s ranges: %w", s.Provider, err)
+	}
+	entries, err := parsers[s.Provider](data)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to parse %s ranges from %s: %w", s.Provider, s.Path, err)
+	}
+	return entries, st.ModTime(), nil
+}
+
+// Lookup returns the most specific cloud range containing ip
+func (r *Ranges) Lookup(ip net.IP) (Range, bool) {
+	addr, ok := netindex.AddrFromIP(ip)
+	if !ok {
+		return Range{}, false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.index == nil {
+		return Range{}, false
+	}
+	_, values, ok := r.index.Lookup(addr)
+	if !ok {
+		return Range{}, false
+	}
+	return values[0], true
+}
+
+// Sources returns the state of each loaded range file
+func (r *Ranges) Sources() []SourceInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	result := make([]SourceInfo, len(r.info))
+	for i, si := range r.info {
+		si.AgeSeconds = int64(now.Sub(si.Modified).Seconds())
+		result[i] = si
+	}
+	return result
+}
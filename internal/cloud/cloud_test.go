@@ -0,0 +1,179 @@
+package cloud
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	awsRanges = `{
+  "syncToken": "1700000000",
+  "prefixes": [
+    {"ip_prefix": "3.5.140.0/22", "region": "ap-northeast-2", "service": "AMAZON", "network_border_group": "ap-northeast-2"},
+    {"ip_prefix": "3.5.140.0/22", "region": "ap-northeast-2", "service": "S3", "network_border_group": "ap-northeast-2"},
+    {"ip_prefix": "52.94.0.0/16", "region": "GLOBAL", "service": "AMAZON", "network_border_group": "GLOBAL"}
+  ],
+  "ipv6_prefixes": [
+    {"ipv6_prefix": "2600:1f14::/35", "region": "us-west-2", "service": "EC2", "network_border_group": "us-west-2"}
+  ]
+}`
+	gcpRanges = `{
+  "syncToken": "1700000000",
+  "prefixes": [
+    {"ipv4Prefix": "34.80.0.0/15", "service": "Google Cloud", "scope": "asia-east1"},
+    {"ipv6Prefix": "2600:1900:4000::/44", "service": "Google Cloud", "scope": "us-central1"}
+  ]
+}`
+	azureRanges = `{
+  "changeNumber": 1,
+  "values": [
+    {"name": "AzureCloud", "properties": {"region": "", "systemService": "", "addressPrefixes": ["20.38.0.0/16"]}},
+    {"name": "AzureCloud.eastus", "properties": {"region": "eastus", "systemService": "", "addressPrefixes": ["20.38.0.0/16"]}},
+    {"name": "Storage.EastUS", "properties": {"region": "eastus", "systemService": "AzureStorage", "addressPrefixes": ["20.38.98.0/24"]}}
+  ]
+}`
+	oracleRanges = `{
+  "last_updated_timestamp": "2024-01-01T00:00:00",
+  "regions": [
+    {"region": "us-phoenix-1", "cidrs": [{"cidr": "129.146.0.0/21", "tags": ["OCI"]}]}
+  ]
+}`
+	cloudflareRanges = "173.245.48.0/20\n# comment\n\n2400:cb00::/32\n"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadAndLookup(t *testing.T) {
+	dir := t.TempDir()
+	ranges, err := Load([]Source{
+		{Provider: AWS, Path: writeFile(t, dir, "aws.json", awsRanges)},
+		{Provider: GCP, Path: writeFile(t, dir, "gcp.json", gcpRanges)},
+		{Provider: Azure, Path: writeFile(t, dir, "azure.json", azureRanges)},
+		{Provider: Oracle, Path: writeFile(t, dir, "oracle.json", oracleRanges)},
+		{Provider: Cloudflare, Path: writeFile(t, dir, "cloudflare.txt", cloudflareRanges)},
+	})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	tests := []struct {
+		ip       string
+		expected Range
+	}{
+		{ip: "3.5.140.10", expected: Range{Provider: AWS, Region: "ap-northeast-2", Service: "S3"}},
+		{ip: "52.94.1.1", expected: Range{Provider: AWS, Service: "AMAZON"}},
+		{ip: "2600:1f14::1", expected: Range{Provider: AWS, Region: "us-west-2", Service: "EC2"}},
+		{ip: "34.81.0.1", expected: Range{Provider: GCP, Region: "asia-east1", Service: "Google Cloud"}},
+		{ip: "2600:1900:4000::1", expected: Range{Provider: GCP, Region: "us-central1", Service: "Google Cloud"}},
+		{ip: "20.38.1.1", expected: Range{Provider: Azure, Region: "eastus", Service: "AzureCloud"}},
+		{ip: "20.38.98.7", expected: Range{Provider: Azure, Region: "eastus", Service: "AzureStorage"}},
+		{ip: "129.146.1.1", expected: Range{Provider: Oracle, Region: "us-phoenix-1", Service: "OCI"}},
+		{ip: "173.245.50.1", expected: Range{Provider: Cloudflare}},
+		{ip: "2400:cb00::1", expected: Range{Provider: Cloudflare}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			got, ok := ranges.Lookup(net.ParseIP(tt.ip))
+			if !ok {
+				t.Fatalf("expected %s to match a cloud range", tt.ip)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+
+	if _, ok := ranges.Lookup(net.ParseIP("192.0.2.1")); ok {
+		t.Error("expected no match for a non-cloud address")
+	}
+
+	sources := ranges.Sources()
+	if len(sources) != 5 {
+		t.Fatalf("expected 5 sources, got %d", len(sources))
+	}
+	if sources[0].Provider != AWS || sources[0].Prefixes != 3 || sources[0].File != "aws.json" {
+		t.Errorf("unexpected AWS source info: %+v", sources[0])
+	}
+}
+
+func TestCloudflareJSON(t *testing.T) {
+	entries, err := parseCloudflare([]byte(`{"result":{"ipv4_cidrs":["104.16.0.0/13"],"ipv6_cidrs":["2606:4700::/32"]},"success":true}`))
+	if err != nil {
+		t.Fatalf("parseCloudflare failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected 2 prefixes, got %d", len(entries))
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "cloudflare.txt", "173.245.48.0/20\n")
+	ranges, err := Load([]Source{{Provider: Cloudflare, Path: path}})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	// Unchanged files are not reparsed
+	if err := ranges.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	writeFile(t, dir, "cloudflare.txt", "104.16.0.0/13\n")
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if err := ranges.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if _, ok := ranges.Lookup(net.ParseIP("104.16.0.1")); !ok {
+		t.Error("expected reloaded range to match")
+	}
+	if _, ok := ranges.Lookup(net.ParseIP("173.245.48.1")); ok {
+		t.Error("expected removed range to no longer match")
+	}
+
+	// A broken file keeps the previous ranges in place
+	writeFile(t, dir, "cloudflare.txt", "not-a-prefix\n")
+	later := future.Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := ranges.Reload(); err == nil {
+		t.Error("expected reload of an invalid file to fail")
+	}
+	if _, ok := ranges.Lookup(net.ParseIP("104.16.0.1")); !ok {
+		t.Error("expected previous ranges to remain after a failed reload")
+	}
+	if ranges.Sources()[0].Error == "" {
+		t.Error("expected the reload error to be reported")
+	}
+}
+
+func TestParseSource(t *testing.T) {
+	s, err := ParseSource("AWS=/data/ip-ranges.json")
+	if err != nil {
+		t.Fatalf("ParseSource failed: %v", err)
+	}
+	if s.Provider != AWS || s.Path != "/data/ip-ranges.json" {
+		t.Errorf("unexpected source: %+v", s)
+	}
+
+	for _, spec := range []string{"aws", "aws=", "digitalocean=/data/ranges.csv"} {
+		if _, err := ParseSource(spec); err == nil {
+			t.Errorf("expected ParseSource(%q) to fail", spec)
+		}
+	}
+}
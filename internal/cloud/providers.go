@@ -0,0 +1,238 @@
+package cloud
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/jcjc-dev/ipwhere/internal/netindex"
+)
+
+// Supported providers
+const (
+	AWS        = "aws"
+	GCP        = "gcp"
+	Azure      = "azure"
+	Oracle     = "oracle"
+	Cloudflare = "cloudflare"
+)
+
+// parsers maps each provider to the parser for its published range file
+var parsers = map[string]func([]byte) (map[netip.Prefix]scored, error){
+	AWS:        parseAWS,
+	GCP:        parseGCP,
+	Azure:      parseAzure,
+	Oracle:     parseOracle,
+	Cloudflare: parseCloudflare,
+}
+
+// scored is a range with a specificity score. Providers often publish the
+// same prefix several times, e.g. once for the whole cloud and once for a
+// specific service; the most specific entry wins.
+type scored struct {
+	Range
+	score int
+}
+
+// add records a range for the prefix unless a more specific one exists
+func add(entries map[netip.Prefix]scored, prefix string, r Range, score int) error {
+	p, err := netindex.ParsePrefix(strings.TrimSpace(prefix))
+	if err != nil {
+		return fmt.Errorf("invalid prefix %q: %w", prefix, err)
+	}
+	if existing, ok := entries[p]; ok && existing.score >= score {
+		return nil
+	}
+	entries[p] = scored{Range: r, score: score}
+	return nil
+}
+
+// parseAWS parses https://ip-ranges.amazonaws.com/ip-ranges.json
+func parseAWS(data []byte) (map[netip.Prefix]scored, error) {
+	var doc struct {
+		Prefixes []struct {
+			IPPrefix string `json:"ip_prefix"`
+			Region   string `json:"region"`
+			Service  string `json:"service"`
+		} `json:"prefixes"`
+		IPv6Prefixes []struct {
+			IPv6Prefix string `json:"ipv6_prefix"`
+			Region     string `json:"region"`
+			Service    string `json:"service"`
+		} `json:"ipv6_prefixes"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	// "AMAZON" covers every prefix; specific services take precedence
+	score := func(service string) int {
+		if service == "AMAZON" {
+			return 0
+		}
+		return 1
+	}
+
+	entries := make(map[netip.Prefix]scored)
+	for _, p := range doc.Prefixes {
+		r := Range{Provider: AWS, Region: awsRegion(p.Region), Service: p.Service}
+		if err := add(entries, p.IPPrefix, r, score(p.Service)); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range doc.IPv6Prefixes {
+		r := Range{Provider: AWS, Region: awsRegion(p.Region), Service: p.Service}
+		if err := add(entries, p.IPv6Prefix, r, score(p.Service)); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// awsRegion drops the GLOBAL pseudo-region
+func awsRegion(region string) string {
+	if region == "GLOBAL" {
+		return ""
+	}
+	return region
+}
+
+// parseGCP parses https://www.gstatic.com/ipranges/cloud.json (and goog.json)
+func parseGCP(data []byte) (map[netip.Prefix]scored, error) {
+	var doc struct {
+		Prefixes []struct {
+			IPv4Prefix string `json:"ipv4Prefix"`
+			IPv6Prefix string `json:"ipv6Prefix"`
+			Service    string `json:"service"`
+			Scope      string `json:"scope"`
+		} `json:"prefixes"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	entries := make(map[netip.Prefix]scored)
+	for _, p := range doc.Prefixes {
+		prefix := p.IPv4Prefix
+		if prefix == "" {
+			prefix = p.IPv6Prefix
+		}
+		region := p.Scope
+		if region == "global" {
+			region = ""
+		}
+		r := Range{Provider: GCP, Region: region, Service: p.Service}
+		if err := add(entries, prefix, r, 0); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// parseAzure parses the weekly ServiceTags_Public_*.json download
+func parseAzure(data []byte) (map[netip.Prefix]scored, error) {
+	var doc struct {
+		Values []struct {
+			Name       string `json:"name"`
+			Properties struct {
+				Region          string   `json:"region"`
+				SystemService   string   `json:"systemService"`
+				AddressPrefixes []string `json:"addressPrefixes"`
+			} `json:"properties"`
+		} `json:"values"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	entries := make(map[netip.Prefix]scored)
+	for _, v := range doc.Values {
+		// Service tags overlap heavily: prefer tags naming both a service
+		// and a region over regional or global umbrella tags
+		score := 0
+		if v.Properties.Region != "" {
+			score++
+		}
+		service := v.Properties.SystemService
+		if service != "" {
+			score += 2
+		} else {
+			service, _, _ = strings.Cut(v.Name, ".")
+		}
+
+		r := Range{Provider: Azure, Region: v.Properties.Region, Service: service}
+		for _, prefix := range v.Properties.AddressPrefixes {
+			if err := add(entries, prefix, r, score); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return entries, nil
+}
+
+// parseOracle parses https://docs.oracle.com/iaas/tools/public_ip_ranges.json
+func parseOracle(data []byte) (map[netip.Prefix]scored, error) {
+	var doc struct {
+		Regions []struct {
+			Region string `json:"region"`
+			CIDRs  []struct {
+				CIDR string   `json:"cidr"`
+				Tags []string `json:"tags"`
+			} `json:"cidrs"`
+		} `json:"regions"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	entries := make(map[netip.Prefix]scored)
+	for _, region := range doc.Regions {
+		for _, c := range region.CIDRs {
+			r := Range{Provider: Oracle, Region: region.Region, Service: strings.Join(c.Tags, ",")}
+			if err := add(entries, c.CIDR, r, 0); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return entries, nil
+}
+
+// parseCloudflare parses either the plain-text https://www.cloudflare.com/ips-v4
+// and ips-v6 lists or the JSON response of the /client/v4/ips API
+func parseCloudflare(data []byte) (map[netip.Prefix]scored, error) {
+	r := Range{Provider: Cloudflare}
+	entries := make(map[netip.Prefix]scored)
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var doc struct {
+			Result struct {
+				IPv4CIDRs []string `json:"ipv4_cidrs"`
+				IPv6CIDRs []string `json:"ipv6_cidrs"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		for _, prefix := range append(doc.Result.IPv4CIDRs, doc.Result.IPv6CIDRs...) {
+			if err := add(entries, prefix, r, 0); err != nil {
+				return nil, err
+			}
+		}
+		return entries, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := add(entries, line, r, 0); err != nil {
+			return nil, err
+		}
+	}
+	return entries, scanner.Err()
+}
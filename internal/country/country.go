@@ -0,0 +1,126 @@
+// Package country provides reference data for ISO 3166-1 countries.
+//
+// The dataset is embedded in the binary so that lookups never touch the
+// network or the filesystem.
+package country
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+//go:embed countries.csv
+var countriesCSV []byte
+
+// Info holds reference data for a single country.
+// Continent is a two-letter continent code (AF, AN, AS, EU, NA, OC, SA).
+// GDPRAdequate reports whether the European Commission has issued an adequacy
+// decision for the country; for Canada and the United States the decision
+// only covers certain organizations.
+type Info struct {
+	ISOCode      string   `json:"iso_code"`
+	Capital      string   `json:"capital,omitempty"`
+	Continent    string   `json:"continent"`
+	Currency     string   `json:"currency,omitempty"`
+	CallingCode  string   `json:"calling_code,omitempty"`
+	Languages    []string `json:"languages,omitempty"`
+	TLD          string   `json:"tld,omitempty"`
+	FlagEmoji    string   `json:"flag_emoji"`
+	InEU         bool     `json:"in_eu"`
+	InEEA        bool     `json:"in_eea"`
+	InSchengen   bool     `json:"in_schengen"`
+	GDPRAdequate bool     `json:"gdpr_adequate"`
+}
+
+// Membership lists, keyed by ISO code
+var (
+	euMembers = set("AT", "BE", "BG", "CY", "CZ", "DE", "DK", "EE", "ES", "FI", "FR", "GR", "HR", "HU",
+		"IE", "IT", "LT", "LU", "LV", "MT", "NL", "PL", "PT", "RO", "SE", "SI", "SK")
+	eeaNonEUMembers = set("IS", "LI", "NO")
+	schengenMembers = set("AT", "BE", "BG", "CH", "CZ", "DE", "DK", "EE", "ES", "FI", "FR", "GR", "HR", "HU",
+		"IS", "IT", "LI", "LT", "LU", "LV", "MT", "NL", "NO", "PL", "PT", "RO", "SE", "SI", "SK")
+	gdprAdequate = set("AD", "AR", "CA", "CH", "FO", "GB", "GG", "IL", "IM", "JE", "JP", "KR", "NZ", "US", "UY")
+)
+
+var (
+	loadOnce  sync.Once
+	countries map[string]*Info
+)
+
+// Lookup returns reference data for the given ISO 3166-1 alpha-2 code.
+// The lookup is case insensitive.
+func Lookup(isoCode string) (*Info, bool) {
+	loadOnce.Do(func() {
+		var err error
+		countries, err = parse(countriesCSV)
+		if err != nil {
+			// The dataset is embedded, so this can only fail at build time
+			panic(fmt.Sprintf("country: invalid embedded dataset: %v", err))
+		}
+	})
+
+	info, ok := countries[strings.ToUpper(isoCode)]
+	return info, ok
+}
+
+// parse reads the embedded CSV dataset
+func parse(data []byte) (map[string]*Info, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("no records")
+	}
+
+	result := make(map[string]*Info, len(records)-1)
+	for _, rec := range records[1:] {
+		if len(rec) != 7 {
+			return nil, fmt.Errorf("invalid record %v", rec)
+		}
+		code := rec[0]
+		info := &Info{
+			ISOCode:      code,
+			Capital:      rec[1],
+			Continent:    rec[2],
+			Currency:     rec[3],
+			CallingCode:  rec[4],
+			Languages:    strings.Fields(rec[5]),
+			TLD:          rec[6],
+			FlagEmoji:    flagEmoji(code),
+			InEU:         euMembers[code],
+			InEEA:        euMembers[code] || eeaNonEUMembers[code],
+			InSchengen:   schengenMembers[code],
+			GDPRAdequate: gdprAdequate[code],
+		}
+		result[code] = info
+	}
+	return result, nil
+}
+
+// flagEmoji converts an ISO code into its regional indicator flag emoji
+func flagEmoji(code string) string {
+	if len(code) != 2 {
+		return ""
+	}
+	var b strings.Builder
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return ""
+		}
+		b.WriteRune(0x1F1E6 + c - 'A')
+	}
+	return b.String()
+}
+
+func set(codes ...string) map[string]bool {
+	m := make(map[string]bool, len(codes))
+	for _, c := range codes {
+		m[c] = true
+	}
+	return m
+}
@@ -0,0 +1,88 @@
+package country
+
+import (
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		code     string
+		capital  string
+		currency string
+		calling  string
+		tld      string
+		flag     string
+		eu       bool
+		eea      bool
+		schengen bool
+		adequate bool
+	}{
+		{code: "DE", capital: "Berlin", currency: "EUR", calling: "+49", tld: ".de", flag: "🇩🇪", eu: true, eea: true, schengen: true},
+		{code: "IE", capital: "Dublin", currency: "EUR", calling: "+353", tld: ".ie", flag: "🇮🇪", eu: true, eea: true},
+		{code: "NO", capital: "Oslo", currency: "NOK", calling: "+47", tld: ".no", flag: "🇳🇴", eea: true, schengen: true},
+		{code: "CH", capital: "Bern", currency: "CHF", calling: "+41", tld: ".ch", flag: "🇨🇭", schengen: true, adequate: true},
+		{code: "GB", capital: "London", currency: "GBP", calling: "+44", tld: ".uk", flag: "🇬🇧", adequate: true},
+		{code: "us", capital: "Washington, D.C.", currency: "USD", calling: "+1", tld: ".us", flag: "🇺🇸", adequate: true},
+		{code: "CN", capital: "Beijing", currency: "CNY", calling: "+86", tld: ".cn", flag: "🇨🇳"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			info, ok := Lookup(tt.code)
+			if !ok {
+				t.Fatalf("expected %s to be found", tt.code)
+			}
+			if info.Capital != tt.capital {
+				t.Errorf("capital: expected %s, got %s", tt.capital, info.Capital)
+			}
+			if info.Currency != tt.currency {
+				t.Errorf("currency: expected %s, got %s", tt.currency, info.Currency)
+			}
+			if info.CallingCode != tt.calling {
+				t.Errorf("calling code: expected %s, got %s", tt.calling, info.CallingCode)
+			}
+			if info.TLD != tt.tld {
+				t.Errorf("tld: expected %s, got %s", tt.tld, info.TLD)
+			}
+			if info.FlagEmoji != tt.flag {
+				t.Errorf("flag: expected %s, got %s", tt.flag, info.FlagEmoji)
+			}
+			if info.InEU != tt.eu || info.InEEA != tt.eea || info.InSchengen != tt.schengen || info.GDPRAdequate != tt.adequate {
+				t.Errorf("membership: expected eu=%v eea=%v schengen=%v adequate=%v, got %+v",
+					tt.eu, tt.eea, tt.schengen, tt.adequate, info)
+			}
+		})
+	}
+}
+
+func TestLookupUnknown(t *testing.T) {
+	for _, code := range []string{"", "ZZ", "USA"} {
+		if _, ok := Lookup(code); ok {
+			t.Errorf("expected %q to be unknown", code)
+		}
+	}
+}
+
+func TestDatasetConsistency(t *testing.T) {
+	continents := map[string]bool{"AF": true, "AN": true, "AS": true, "EU": true, "NA": true, "OC": true, "SA": true}
+
+	Lookup("")
+	for code, info := range countries {
+		if !continents[info.Continent] {
+			t.Errorf("%s: invalid continent %q", code, info.Continent)
+		}
+		if info.FlagEmoji == "" {
+			t.Errorf("%s: missing flag emoji", code)
+		}
+	}
+	for _, members := range []map[string]bool{euMembers, eeaNonEUMembers, schengenMembers, gdprAdequate} {
+		for code := range members {
+			if _, ok := countries[code]; !ok {
+				t.Errorf("membership list references unknown country %s", code)
+			}
+		}
+	}
+	if len(euMembers) != 27 {
+		t.Errorf("expected 27 EU members, got %d", len(euMembers))
+	}
+}
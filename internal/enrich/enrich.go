@@ -0,0 +1,298 @@
+// Package enrich adds geolocation fields to access logs and other record
+// streams.
+//
+// Records are read, enriched by a pool of workers and written back in input
+// order, in the same format they were read in. Memory use is bounded by the
+// number of batches in flight, so inputs of any size can be streamed.
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+	"github.com/jcjc-dev/ipwhere/internal/lists"
+)
+
+// Formats
+const (
+	Combined = "combined" // nginx/Apache combined log format
+	Common   = "common"   // Apache common log format
+	JSONL    = "jsonl"    // JSON object per line
+	CSV      = "csv"      // CSV with a header row
+)
+
+// DefaultFields are the geolocation fields added when none are configured
+var DefaultFields = []string{"iso_code", "city", "asn", "organization"}
+
+const (
+	// DefaultBatchSize is the number of records enriched per work unit
+	DefaultBatchSize = 512
+	// DefaultIPField is the JSON field or CSV column holding the address
+	DefaultIPField = "ip"
+	// FieldPrefix prefixes the added fields in log lines and CSV headers
+	FieldPrefix = "geo_"
+	// JSONKey is the object key holding the added fields in JSON lines
+	JSONKey = "geo"
+
+	// memoSize bounds the per-run cache of lookup results
+	memoSize = 1 << 16
+)
+
+// Options configures Run
+type Options struct {
+	Format    string   // Combined, Common, JSONL or CSV
+	IPField   string   // JSONL field (dotted path for nested objects) or CSV column
+	Fields    []string // Geolocation fields to add, as accepted by IPInfo.FilterFields
+	Workers   int      // Concurrent workers (default GOMAXPROCS)
+	BatchSize int      // Records per batch (default DefaultBatchSize)
+}
+
+// Stats counts the records processed by Run. Skipped records did not match
+// the format or had no valid IP address and were passed through unchanged.
+type Stats struct {
+	Records  int `json:"records"`
+	Enriched int `json:"enriched"`
+	Skipped  int `json:"skipped"`
+}
+
+// ValidateFields returns an error naming the first unknown field
+func ValidateFields(fields []string) error {
+	empty := &geo.IPInfo{}
+	for _, f := range fields {
+		if _, ok := empty.FilterFields([]string{f})[f]; !ok || f == "ip" || f == "attribution" {
+			return fmt.Errorf("unknown field %q", f)
+		}
+	}
+	return nil
+}
+
+// Run reads records from in, adds the configured geolocation fields and
+// writes them to out in input order
+func Run(ctx context.Context, reader geo.ReaderInterface, in io.Reader, out io.Writer, opts Options) (Stats, error) {
+	if len(opts.Fields) == 0 {
+		opts.Fields = DefaultFields
+	}
+	if err := ValidateFields(opts.Fields); err != nil {
+		return Stats{}, err
+	}
+	if opts.IPField == "" {
+		opts.IPField = DefaultIPField
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	e := &enricher{reader: reader, fields: opts.Fields, memo: make(map[string]*geo.IPInfo)}
+	switch opts.Format {
+	case Combined, Common:
+		return runLines(ctx, in, out, opts, logLine(opts.Format, e))
+	case JSONL:
+		return runLines(ctx, in, out, opts, jsonLine(opts.IPField, e))
+	case CSV:
+		return runCSV(ctx, in, out, opts, e)
+	default:
+		return Stats{}, fmt.Errorf("unknown format %q (want combined, common, jsonl or csv)", opts.Format)
+	}
+}
+
+// enricher looks up addresses and renders the configured fields. Lookups
+// are memoized for the run, as logs repeat the same clients.
+type enricher struct {
+	reader geo.ReaderInterface
+	fields []string
+	mu     sync.Mutex
+	memo   map[string]*geo.IPInfo
+}
+
+// lookup returns the selected fields for ip in configuration order, or false
+// if ip is not a valid address
+func (e *enricher) lookup(ctx context.Context, ipStr string) ([]any, bool) {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return nil, false
+	}
+
+	e.mu.Lock()
+	info, ok := e.memo[ipStr]
+	e.mu.Unlock()
+	if !ok {
+		var err error
+		if info, err = e.reader.Lookup(ctx, ip); err != nil {
+			return nil, false
+		}
+		e.mu.Lock()
+		if len(e.memo) >= memoSize {
+			clear(e.memo)
+		}
+		e.memo[ipStr] = info
+		e.mu.Unlock()
+	}
+
+	filtered := info.FilterFields(e.fields)
+	values := make([]any, len(e.fields))
+	for i, f := range e.fields {
+		values[i] = filtered[f]
+	}
+	return values, true
+}
+
+// formatValue renders a field value as plain text: strings as is, lists
+// comma-separated and missing values empty
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10)
+	case *bool:
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatBool(*v)
+	case *uint:
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatUint(uint64(*v), 10)
+	case *float64:
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*v, 'f', -1, 64)
+	case []string:
+		return strings.Join(v, ",")
+	case []lists.Match:
+		names := make([]string, len(v))
+		for i, m := range v {
+			names[i] = m.Name
+		}
+		return strings.Join(names, ",")
+	default:
+		data, err := json.Marshal(v)
+		if err != nil || string(data) == "null" {
+			return ""
+		}
+		return strings.Trim(string(data), `"`)
+	}
+}
+
+// batch is a unit of work. done is closed once out is filled.
+type batch[In, Out any] struct {
+	in   []In
+	out  []Out
+	ok   []bool
+	done chan struct{}
+}
+
+// pipeline reads records with read until io.EOF, transforms batches of them
+// concurrently and passes the results to write in input order. transform
+// reports whether a record was enriched.
+func pipeline[In, Out any](ctx context.Context, opts Options, read func() (In, error), transform func(context.Context, In) (Out, bool), write func(Out) error) (Stats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	work := make(chan *batch[In, Out], opts.Workers)
+	ordered := make(chan *batch[In, Out], opts.Workers*2)
+
+	var wg sync.WaitGroup
+	for range opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range work {
+				for i, rec := range b.in {
+					b.out[i], b.ok[i] = transform(ctx, rec)
+				}
+				close(b.done)
+			}
+		}()
+	}
+
+	// The reader queues each batch for the workers and, in the same order,
+	// for the writer, which waits for each batch to complete
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(ordered)
+		defer close(work)
+		for {
+			b := &batch[In, Out]{done: make(chan struct{})}
+			var err error
+			for len(b.in) < opts.BatchSize {
+				var rec In
+				if rec, err = read(); err != nil {
+					break
+				}
+				b.in = append(b.in, rec)
+			}
+			if len(b.in) > 0 {
+				b.out, b.ok = make([]Out, len(b.in)), make([]bool, len(b.in))
+				select {
+				case work <- b:
+				case <-ctx.Done():
+					readErr <- ctx.Err()
+					return
+				}
+				select {
+				case ordered <- b:
+				case <-ctx.Done():
+					readErr <- ctx.Err()
+					return
+				}
+			}
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					readErr <- err
+				}
+				return
+			}
+		}
+	}()
+
+	var stats Stats
+	var writeErr error
+	for b := range ordered {
+		<-b.done
+		if writeErr != nil {
+			continue
+		}
+		for i, rec := range b.out {
+			stats.Records++
+			if b.ok[i] {
+				stats.Enriched++
+			} else {
+				stats.Skipped++
+			}
+			if writeErr = write(rec); writeErr != nil {
+				cancel()
+				break
+			}
+		}
+	}
+	wg.Wait()
+
+	if writeErr != nil {
+		return stats, writeErr
+	}
+	select {
+	case err := <-readErr:
+		return stats, err
+	default:
+		return stats, nil
+	}
+}
@@ -0,0 +1,167 @@
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+	"github.com/jcjc-dev/ipwhere/internal/geo/geotest"
+)
+
+// testReader geolocates public addresses to Google in the US
+func testReader() *geotest.Reader {
+	return &geotest.Reader{
+		Networks: map[string]geo.IPInfo{"10.0.0.0/8": {}, "172.16.0.0/12": {}, "192.168.0.0/16": {}},
+		Default: geo.IPInfo{
+			ISOCode:      "US",
+			Country:      "United States",
+			City:         "Mountain View",
+			ASN:          geotest.ASN(15169),
+			Organization: "Google LLC",
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		input    string
+		expected string
+		stats    Stats
+	}{
+		{
+			name:     "Combined",
+			opts:     Options{Format: Combined},
+			input:    `8.8.8.8 - frank [10/Oct/2000:13:55:36 -0700] "GET /a.gif HTTP/1.0" 200 2326 "http://example.com/" "Mozilla/4.08"` + "\n",
+			expected: `8.8.8.8 - frank [10/Oct/2000:13:55:36 -0700] "GET /a.gif HTTP/1.0" 200 2326 "http://example.com/" "Mozilla/4.08" geo_iso_code="US" geo_city="Mountain View" geo_asn="15169" geo_organization="Google LLC"` + "\n",
+			stats:    Stats{Records: 1, Enriched: 1},
+		},
+		{
+			name:     "Common with CRLF and no final newline",
+			opts:     Options{Format: Common, Fields: []string{"country"}},
+			input:    "10.0.0.1 - - [10/Oct/2000:13:55:36 -0700] \"GET / HTTP/1.1\" 304 -\r\n2001:4860::8888 - - [10/Oct/2000:13:55:37 -0700] \"GET / HTTP/1.1\" 200 12",
+			expected: "10.0.0.1 - - [10/Oct/2000:13:55:36 -0700] \"GET / HTTP/1.1\" 304 - geo_country=\"\"\r\n2001:4860::8888 - - [10/Oct/2000:13:55:37 -0700] \"GET / HTTP/1.1\" 200 12 geo_country=\"United States\"",
+			stats:    Stats{Records: 2, Enriched: 2},
+		},
+		{
+			name:     "Unparsed lines pass through",
+			opts:     Options{Format: Combined, Fields: []string{"iso_code"}},
+			input:    "# comment\nnot-an-ip - - [10/Oct/2000:13:55:36 -0700] \"GET / HTTP/1.1\" 200 1 \"-\" \"-\"\n\n",
+			expected: "# comment\nnot-an-ip - - [10/Oct/2000:13:55:36 -0700] \"GET / HTTP/1.1\" 200 1 \"-\" \"-\"\n\n",
+			stats:    Stats{Records: 3, Skipped: 3},
+		},
+		{
+			name:     "JSON lines",
+			opts:     Options{Format: JSONL, Fields: []string{"iso_code", "asn"}},
+			input:    `{"ts":1.50,"ip":"8.8.8.8","path":"/"}` + "\n" + `{"ip":"8.8.8.8"} ` + "\n" + `{"path":"/"}` + "\n" + `[1,2]` + "\n",
+			expected: `{"ts":1.50,"ip":"8.8.8.8","path":"/","geo":{"asn":15169,"iso_code":"US"}}` + "\n" + `{"ip":"8.8.8.8","geo":{"asn":15169,"iso_code":"US"}}` + "\n" + `{"path":"/"}` + "\n" + `[1,2]` + "\n",
+			stats:    Stats{Records: 4, Enriched: 2, Skipped: 2},
+		},
+		{
+			name:     "JSON lines nested field",
+			opts:     Options{Format: JSONL, IPField: "client.addr", Fields: []string{"city"}},
+			input:    `{"client":{"addr":"8.8.4.4"}}` + "\n",
+			expected: `{"client":{"addr":"8.8.4.4"},"geo":{"city":"Mountain View"}}` + "\n",
+			stats:    Stats{Records: 1, Enriched: 1},
+		},
+		{
+			name:     "JSON lines already enriched",
+			opts:     Options{Format: JSONL, Fields: []string{"iso_code"}},
+			input:    `{"ip":"8.8.8.8","geo":{"iso_code":"US"}}` + "\n" + `{"ip":"8.8.8.8","geo":null}` + "\n",
+			expected: `{"ip":"8.8.8.8","geo":{"iso_code":"US"}}` + "\n" + `{"ip":"8.8.8.8","geo":null}` + "\n",
+			stats:    Stats{Records: 2, Skipped: 2},
+		},
+		{
+			name:     "CSV",
+			opts:     Options{Format: CSV, IPField: "client", Fields: []string{"organization", "asn"}},
+			input:    "time,client,note\n1,8.8.8.8,\"a, b\"\n2,bogus,c\n3,192.168.1.1,d\n",
+			expected: "time,client,note,geo_organization,geo_asn\n1,8.8.8.8,\"a, b\",Google LLC,15169\n2,bogus,c,,\n3,192.168.1.1,d,,\n",
+			stats:    Stats{Records: 3, Enriched: 2, Skipped: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			stats, err := Run(context.Background(), testReader(), strings.NewReader(tt.input), &out, tt.opts)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("output =\n%s\nwant\n%s", out.String(), tt.expected)
+			}
+			if stats != tt.stats {
+				t.Errorf("stats = %+v, want %+v", stats, tt.stats)
+			}
+		})
+	}
+}
+
+func TestRunPreservesOrder(t *testing.T) {
+	var in, want strings.Builder
+	for i := range 10000 {
+		ip := fmt.Sprintf("8.8.%d.%d", i/256%256, i%256)
+		fmt.Fprintf(&in, "{\"n\":%d,\"ip\":%q}\n", i, ip)
+		fmt.Fprintf(&want, "{\"n\":%d,\"ip\":%q,\"geo\":{\"iso_code\":\"US\"}}\n", i, ip)
+	}
+
+	reader := testReader()
+	var out bytes.Buffer
+	stats, err := Run(context.Background(), reader, strings.NewReader(in.String()), &out, Options{
+		Format:    JSONL,
+		Fields:    []string{"iso_code"},
+		Workers:   8,
+		BatchSize: 7,
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if out.String() != want.String() {
+		t.Error("output is not in input order")
+	}
+	if stats.Records != 10000 || stats.Enriched != 10000 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if n := reader.Lookups(); n > 10000 {
+		t.Errorf("lookups = %d, want at most one per address", n)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  Options
+		input string
+	}{
+		{name: "Unknown format", opts: Options{Format: "xml"}},
+		{name: "Unknown field", opts: Options{Format: Combined, Fields: []string{"postcode"}}},
+		{name: "Missing CSV column", opts: Options{Format: CSV}, input: "a,b\n1,2\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Run(context.Background(), testReader(), strings.NewReader(tt.input), &bytes.Buffer{}, tt.opts)
+			if err == nil {
+				t.Error("Run() error = nil, want error")
+			}
+		})
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, fmt.Errorf("disk full")
+}
+
+func TestRunWriteError(t *testing.T) {
+	input := strings.Repeat(`{"ip":"8.8.8.8"}`+"\n", 100000)
+	_, err := Run(context.Background(), testReader(), strings.NewReader(input), failingWriter{}, Options{Format: JSONL, BatchSize: 16})
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Run() error = %v, want write error", err)
+	}
+}
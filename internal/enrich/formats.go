@@ -0,0 +1,217 @@
+package enrich
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// %h %l %u %t "%r" %>s %b
+	commonLog = regexp.MustCompile(`^(\S+) \S+ \S+ \[[^\]]+\] "(?:[^"\\]|\\.)*" \d{3} (?:\d+|-)`)
+	// common followed by "%{Referer}i" "%{User-agent}i"
+	combinedLog = regexp.MustCompile(`^(\S+) \S+ \S+ \[[^\]]+\] "(?:[^"\\]|\\.)*" \d{3} (?:\d+|-) "(?:[^"\\]|\\.)*" "(?:[^"\\]|\\.)*"`)
+)
+
+// lineTransform enriches a line without its terminating newline
+type lineTransform func(ctx context.Context, line []byte) ([]byte, bool)
+
+// runLines runs the pipeline over newline-terminated records. Line endings
+// are preserved, including a missing final newline.
+func runLines(ctx context.Context, in io.Reader, out io.Writer, opts Options, transform lineTransform) (Stats, error) {
+	br := bufio.NewReaderSize(in, 64*1024)
+	bw := bufio.NewWriterSize(out, 64*1024)
+
+	read := func() ([]byte, error) {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			return line, nil
+		}
+		return nil, err
+	}
+	enrich := func(ctx context.Context, line []byte) ([]byte, bool) {
+		body, eol := splitEOL(line)
+		enriched, ok := transform(ctx, body)
+		if !ok {
+			return line, false
+		}
+		return append(enriched, eol...), true
+	}
+
+	stats, err := pipeline(ctx, opts, read, enrich, func(line []byte) error {
+		_, err := bw.Write(line)
+		return err
+	})
+	if err != nil {
+		return stats, err
+	}
+	return stats, bw.Flush()
+}
+
+// splitEOL splits a line into its body and its line ending
+func splitEOL(line []byte) ([]byte, []byte) {
+	body := bytes.TrimRight(line, "\r\n")
+	return body, line[len(body):]
+}
+
+// logLine enriches access log lines by appending geo_field="value" pairs
+func logLine(format string, e *enricher) lineTransform {
+	pattern := combinedLog
+	if format == Common {
+		pattern = commonLog
+	}
+
+	return func(ctx context.Context, line []byte) ([]byte, bool) {
+		m := pattern.FindSubmatch(line)
+		if m == nil {
+			return nil, false
+		}
+		values, ok := e.lookup(ctx, string(m[1]))
+		if !ok {
+			return nil, false
+		}
+
+		out := make([]byte, 0, len(line)+len(values)*24)
+		out = append(out, line...)
+		for i, v := range values {
+			out = append(out, ' ')
+			out = append(out, FieldPrefix+e.fields[i]...)
+			out = append(out, '=')
+			out = strconv.AppendQuote(out, formatValue(v))
+		}
+		return out, true
+	}
+}
+
+// jsonLine enriches JSON objects by adding a "geo" object. The original
+// bytes are kept and the object is extended in place, so key order and
+// number formatting are preserved. Objects that already have a "geo" key
+// are passed through, so re-enriching a file never yields duplicate keys.
+func jsonLine(ipField string, e *enricher) lineTransform {
+	path := strings.Split(ipField, ".")
+
+	return func(ctx context.Context, line []byte) ([]byte, bool) {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(line, &obj); err != nil {
+			return nil, false
+		}
+		if _, ok := obj[JSONKey]; ok {
+			return nil, false
+		}
+		ip, ok := lookupPath(obj, path)
+		if !ok {
+			return nil, false
+		}
+		values, ok := e.lookup(ctx, ip)
+		if !ok {
+			return nil, false
+		}
+
+		geoObj := make(map[string]any, len(values))
+		for i, v := range values {
+			geoObj[e.fields[i]] = v
+		}
+		data, err := json.Marshal(geoObj)
+		if err != nil {
+			return nil, false
+		}
+
+		trimmed := bytes.TrimRight(line, " \t")
+		end := len(trimmed) - 1 // the closing brace
+		out := make([]byte, 0, len(line)+len(data)+8)
+		out = append(out, trimmed[:end]...)
+		if len(obj) > 0 {
+			out = append(out, ',')
+		}
+		out = append(out, `"`+JSONKey+`":`...)
+		out = append(out, data...)
+		out = append(out, '}')
+		return out, true
+	}
+}
+
+// lookupPath returns the string at a dotted path in a JSON object
+func lookupPath(obj map[string]json.RawMessage, path []string) (string, bool) {
+	raw, ok := obj[path[0]]
+	if !ok {
+		return "", false
+	}
+	if len(path) > 1 {
+		var nested map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &nested); err != nil {
+			return "", false
+		}
+		return lookupPath(nested, path[1:])
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return "", false
+	}
+	return s, true
+}
+
+// runCSV enriches CSV records by appending a column per field. The first
+// record is the header and must contain the IP column.
+func runCSV(ctx context.Context, in io.Reader, out io.Writer, opts Options, e *enricher) (Stats, error) {
+	cr := csv.NewReader(bufio.NewReaderSize(in, 64*1024))
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = false
+	bw := bufio.NewWriterSize(out, 64*1024)
+	cw := csv.NewWriter(bw)
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return Stats{}, nil
+	}
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	col := -1
+	for i, name := range header {
+		if strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")) == opts.IPField {
+			col = i
+			break
+		}
+	}
+	if col < 0 {
+		return Stats{}, fmt.Errorf("CSV header has no %q column", opts.IPField)
+	}
+
+	for _, f := range e.fields {
+		header = append(header, FieldPrefix+f)
+	}
+	if err := cw.Write(header); err != nil {
+		return Stats{}, err
+	}
+
+	enrich := func(ctx context.Context, rec []string) ([]string, bool) {
+		if col >= len(rec) {
+			return rec, false
+		}
+		values, ok := e.lookup(ctx, strings.TrimSpace(rec[col]))
+		if !ok {
+			return append(rec, make([]string, len(e.fields))...), false
+		}
+		for _, v := range values {
+			rec = append(rec, formatValue(v))
+		}
+		return rec, true
+	}
+
+	stats, err := pipeline(ctx, opts, cr.Read, enrich, cw.Write)
+	if err != nil {
+		return stats, err
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return stats, err
+	}
+	return stats, bw.Flush()
+}
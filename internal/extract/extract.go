@@ -0,0 +1,335 @@
+// Package extract finds IP addresses in free text such as log snippets,
+// firewall dumps or incident notes.
+//
+// Besides plain IPv4 and IPv6 literals it recognises addresses with ports
+// (192.0.2.1:443, [2001:db8::1]:443) and common defanged forms used in
+// threat intelligence reports, e.g. 192[.]0[.]2[.]1, 192(.)0(.)2(.)1,
+// 192[dot]0[dot]2[dot]1 and 2001[:]db8[:][:]1.
+package extract
+
+import (
+	"context"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+	"github.com/jcjc-dev/ipwhere/internal/netclass"
+)
+
+// Match is one occurrence of an address in the text. Offset and Length are
+// in bytes and cover the address as written, including brackets and port.
+type Match struct {
+	Addr     netip.Addr `json:"-"`
+	Offset   int        `json:"offset"`
+	Length   int        `json:"length"`
+	Text     string     `json:"text"`
+	Port     int        `json:"port,omitempty"`
+	Defanged bool       `json:"defanged,omitempty"`
+}
+
+// defangs maps defanged separators to their plain form
+var defangs = []struct {
+	token string
+	plain byte
+}{
+	{"[.]", '.'}, {"(.)", '.'}, {"{.}", '.'},
+	{"[dot]", '.'}, {"(dot)", '.'}, {"{dot}", '.'},
+	{"[:]", ':'},
+}
+
+// candidate matches runs of characters that may form an address
+var candidate = regexp.MustCompile(`[0-9A-Fa-f:.]*[0-9A-Fa-f:][0-9A-Fa-f:.]*`)
+
+// ipv4Literal matches dotted quads inside runs that did not parse as a whole
+var ipv4Literal = regexp.MustCompile(`[0-9]{1,3}(?:\.[0-9]{1,3}){3}`)
+
+// Find returns every address occurrence in text, in order
+func Find(text string) []Match {
+	return find(text, true)
+}
+
+// FindAll is like Find, but errs on the side of finding too much: addresses
+// glued to words (ip_192.0.2.1, v1.2.3.4) are included and IPv4 addresses are
+// also picked out of longer runs such as 1.2.3.4.5. It is meant for
+// redaction, where a missed address is worse than a false positive.
+func FindAll(text string) []Match {
+	return find(text, false)
+}
+
+// find returns the address occurrences in text. In strict mode, runs glued
+// to words are skipped and runs must parse as a whole.
+func find(text string, strict bool) []Match {
+	norm, orig := refang(text)
+
+	var found []Match
+	for _, loc := range candidate.FindAllStringIndex(norm, -1) {
+		start, end := loc[0], loc[1]
+		// Addresses must not be glued to words, e.g. std::map or v1.2.3.4
+		if strict && end < len(norm) && isWordByte(norm[end]) {
+			continue
+		}
+		m, ok := Match{}, false
+		for {
+			glued := start > 0 && isWordByte(norm[start-1])
+			if !glued || !strict {
+				if m, ok = parseCandidate(norm, start, end); ok {
+					break
+				}
+			}
+			// A key and a single colon may precede the address, e.g.
+			// client:192.0.2.1 or deadbeef:192.0.2.1. Keys that could be
+			// an IPv6 group, e.g. in 12:30:45, only count if they continue
+			// a word or the rest starts with an IPv4 address.
+			key, rest, found := strings.Cut(norm[start:end], ":")
+			next := start + len(key) + 1
+			if !found || next >= end || norm[next] == ':' {
+				break
+			}
+			group, _, _ := strings.Cut(rest, ":")
+			if !glued && key != "" && len(key) <= 4 && !strings.Contains(key, ".") && !strings.Contains(group, ".") {
+				break
+			}
+			start = next
+		}
+		if ok {
+			found = append(found, m)
+			continue
+		}
+		if !strict {
+			for _, l := range ipv4Literal.FindAllStringIndex(norm[loc[0]:loc[1]], -1) {
+				if addr, err := netip.ParseAddr(norm[loc[0]+l[0] : loc[0]+l[1]]); err == nil {
+					found = append(found, Match{Addr: addr, Offset: loc[0] + l[0], Length: l[1] - l[0]})
+				}
+			}
+		}
+	}
+
+	// Map offsets back to the original text
+	matches := make([]Match, 0, len(found))
+	for _, m := range found {
+		normLength := m.Length
+		m.Offset, m.Length = orig[m.Offset], orig[m.Offset+m.Length]-orig[m.Offset]
+		m.Text = text[m.Offset : m.Offset+m.Length]
+		m.Defanged = m.Length != normLength
+		matches = append(matches, m)
+	}
+	return matches
+}
+
+// parseCandidate parses norm[start:end] as an address, optionally followed by
+// a port. On success Offset and Length are set in normalized coordinates.
+func parseCandidate(norm string, start, end int) (Match, bool) {
+	s := norm[start:end]
+
+	// Bracketed IPv6 with optional port: [2001:db8::1]:443
+	if start > 0 && norm[start-1] == '[' && end < len(norm) && norm[end] == ']' {
+		addr, err := netip.ParseAddr(s)
+		if err != nil || !addr.Is6() {
+			return Match{}, false
+		}
+		m := Match{Addr: addr, Offset: start - 1, Length: end - start + 2}
+		if port, n := parsePort(norm[end+1:]); n > 0 {
+			m.Port, m.Length = port, m.Length+n
+		}
+		return m, true
+	}
+
+	// Trailing punctuation, e.g. the full stop ending a sentence
+	s = strings.TrimRight(s, ".")
+	if strings.HasSuffix(s, ":") && !strings.HasSuffix(s, "::") {
+		s = s[:len(s)-1]
+	}
+	if s == "" || s == "::" {
+		return Match{}, false
+	}
+
+	// IPv4 with port: 192.0.2.1:8080
+	if host, portStr, ok := strings.Cut(s, ":"); ok && strings.Count(s, ":") == 1 && strings.Contains(host, ".") {
+		addr, err := netip.ParseAddr(host)
+		port, perr := strconv.Atoi(portStr)
+		if err != nil || !addr.Is4() || perr != nil || port > 65535 || len(portStr) > 5 {
+			return Match{}, false
+		}
+		return Match{Addr: addr, Offset: start, Length: len(s), Port: port}, true
+	}
+
+	// The whole run must parse, so version numbers and OIDs such as
+	// 1.2.3.4.5 are not mistaken for addresses
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return Match{}, false
+	}
+	return Match{Addr: addr, Offset: start, Length: len(s)}, true
+}
+
+// parsePort parses ":digits" at the start of s, returning the port and the
+// number of bytes consumed
+func parsePort(s string) (int, int) {
+	if len(s) < 2 || s[0] != ':' {
+		return 0, 0
+	}
+	n := 1
+	for n < len(s) && n <= 5 && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	if n == 1 || n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		return 0, 0
+	}
+	port, err := strconv.Atoi(s[1:n])
+	if err != nil || port > 65535 {
+		return 0, 0
+	}
+	return port, n
+}
+
+// refang replaces defanged separators with plain ones. It returns the
+// normalized text and, for each normalized byte offset (plus the end), the
+// corresponding offset in the original text.
+func refang(text string) (string, []int) {
+	var b strings.Builder
+	b.Grow(len(text))
+	orig := make([]int, 0, len(text)+1)
+
+	for i := 0; i < len(text); {
+		replaced := false
+		if c := text[i]; c == '[' || c == '(' || c == '{' {
+			for _, d := range defangs {
+				if len(text)-i >= len(d.token) && strings.EqualFold(text[i:i+len(d.token)], d.token) {
+					b.WriteByte(d.plain)
+					orig = append(orig, i)
+					i += len(d.token)
+					replaced = true
+					break
+				}
+			}
+		}
+		if !replaced {
+			b.WriteByte(text[i])
+			orig = append(orig, i)
+			i++
+		}
+	}
+	orig = append(orig, len(text))
+	return b.String(), orig
+}
+
+// isWordByte reports whether c continues a word an address must not be part of
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
+
+// Address is a distinct address found in the text with all its occurrences.
+// Private is set for private and reserved addresses, which are not
+// geolocated.
+type Address struct {
+	IP          string      `json:"ip"`
+	Private     bool        `json:"private,omitempty"`
+	Occurrences []Match     `json:"occurrences"`
+	Info        *geo.IPInfo `json:"info,omitempty"`
+}
+
+// CountryCount is the number of distinct addresses located in a country
+type CountryCount struct {
+	ISOCode string `json:"iso_code"`
+	Country string `json:"country,omitempty"`
+	Count   int    `json:"count"`
+}
+
+// ASNCount is the number of distinct addresses announced by an AS
+type ASNCount struct {
+	ASN          uint   `json:"asn"`
+	Organization string `json:"organization,omitempty"`
+	Count        int    `json:"count"`
+}
+
+// Result is the outcome of Analyze. Addresses are in order of first
+// occurrence; the summaries count distinct geolocated addresses, largest
+// first.
+type Result struct {
+	Addresses   []Address      `json:"addresses"`
+	Occurrences int            `json:"occurrences"`
+	Countries   []CountryCount `json:"countries"`
+	ASNs        []ASNCount     `json:"asns"`
+	Truncated   bool           `json:"truncated,omitempty"`
+	Attribution string         `json:"attribution"`
+}
+
+// Analyze finds the addresses in text, deduplicates them and geolocates the
+// public ones. At most limit distinct addresses are returned (0 = no limit);
+// Truncated is set if more were found.
+func Analyze(ctx context.Context, reader geo.ReaderInterface, text string, limit int) (*Result, error) {
+	result := &Result{
+		Addresses:   []Address{},
+		Countries:   []CountryCount{},
+		ASNs:        []ASNCount{},
+		Attribution: geo.Attribution,
+	}
+
+	index := make(map[netip.Addr]int)
+	for _, m := range Find(text) {
+		addr := m.Addr.Unmap()
+		i, ok := index[addr]
+		if !ok {
+			if limit > 0 && len(result.Addresses) == limit {
+				result.Truncated = true
+				continue
+			}
+			i = len(result.Addresses)
+			index[addr] = i
+			result.Addresses = append(result.Addresses, Address{IP: addr.String(), Private: !netclass.IsPublic(addr)})
+		}
+		result.Addresses[i].Occurrences = append(result.Addresses[i].Occurrences, m)
+		result.Occurrences++
+	}
+
+	countries := make(map[string]*CountryCount)
+	asns := make(map[uint]*ASNCount)
+	for i := range result.Addresses {
+		a := &result.Addresses[i]
+		if a.Private {
+			continue
+		}
+		info, err := reader.Lookup(ctx, netip.MustParseAddr(a.IP).AsSlice())
+		if err != nil {
+			return nil, err
+		}
+		a.Info = info
+
+		if info.ISOCode != "" {
+			c, ok := countries[info.ISOCode]
+			if !ok {
+				c = &CountryCount{ISOCode: info.ISOCode, Country: info.Country}
+				countries[info.ISOCode] = c
+			}
+			c.Count++
+		}
+		if info.ASN != nil {
+			c, ok := asns[*info.ASN]
+			if !ok {
+				c = &ASNCount{ASN: *info.ASN, Organization: info.Organization}
+				asns[*info.ASN] = c
+			}
+			c.Count++
+		}
+	}
+
+	for _, c := range countries {
+		result.Countries = append(result.Countries, *c)
+	}
+	sort.Slice(result.Countries, func(i, j int) bool {
+		a, b := result.Countries[i], result.Countries[j]
+		return a.Count > b.Count || a.Count == b.Count && a.ISOCode < b.ISOCode
+	})
+	for _, c := range asns {
+		result.ASNs = append(result.ASNs, *c)
+	}
+	sort.Slice(result.ASNs, func(i, j int) bool {
+		a, b := result.ASNs[i], result.ASNs[j]
+		return a.Count > b.Count || a.Count == b.Count && a.ASN < b.ASN
+	})
+
+	return result, nil
+}
@@ -0,0 +1,161 @@
+package extract
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+	"github.com/jcjc-dev/ipwhere/internal/geo/geotest"
+)
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected []Match
+	}{
+		{
+			name:     "IPv4",
+			text:     "Failed login from 81.2.69.142.",
+			expected: []Match{{Offset: 18, Length: 11, Text: "81.2.69.142"}},
+		},
+		{
+			name:     "IPv4 with port",
+			text:     "DROP IN=eth0 SRC=8.8.8.8:53 DST=10.0.0.1",
+			expected: []Match{{Offset: 17, Length: 10, Text: "8.8.8.8:53", Port: 53}, {Offset: 32, Length: 8, Text: "10.0.0.1"}},
+		},
+		{
+			name:     "IPv6",
+			text:     "client 2a00:1450:4009:81f::200e connected",
+			expected: []Match{{Offset: 7, Length: 24, Text: "2a00:1450:4009:81f::200e"}},
+		},
+		{
+			name:     "Bracketed IPv6 with port",
+			text:     "GET from [2001:db8::1]:8443 and [::1]",
+			expected: []Match{{Offset: 9, Length: 18, Text: "[2001:db8::1]:8443", Port: 8443}, {Offset: 32, Length: 5, Text: "[::1]"}},
+		},
+		{
+			name: "Defanged",
+			text: "C2 at 45[.]33(.)32{dot}156 and 2001[:]db8[:][:]2",
+			expected: []Match{
+				{Offset: 6, Length: 20, Text: "45[.]33(.)32{dot}156", Defanged: true},
+				{Offset: 31, Length: 17, Text: "2001[:]db8[:][:]2", Defanged: true},
+			},
+		},
+		{
+			name: "Key-value pairs",
+			text: "client:1.2.3.4 IP:1.2.3.4 ip:2a00:1450:4009:81f::200e deadbeef:1.2.3.4 src:8.8.8.8:53 (:9.9.9.9)",
+			expected: []Match{
+				{Offset: 7, Length: 7, Text: "1.2.3.4"},
+				{Offset: 18, Length: 7, Text: "1.2.3.4"},
+				{Offset: 29, Length: 24, Text: "2a00:1450:4009:81f::200e"},
+				{Offset: 63, Length: 7, Text: "1.2.3.4"},
+				{Offset: 75, Length: 10, Text: "8.8.8.8:53", Port: 53},
+				{Offset: 88, Length: 7, Text: "9.9.9.9"},
+			},
+		},
+		{
+			name: "Short hex keys",
+			text: "add:192.0.2.1 cafe:192.0.2.1 dead:10.0.0.1:53",
+			expected: []Match{
+				{Offset: 4, Length: 9, Text: "192.0.2.1"},
+				{Offset: 19, Length: 9, Text: "192.0.2.1"},
+				{Offset: 34, Length: 11, Text: "10.0.0.1:53", Port: 53},
+			},
+		},
+		{
+			name: "Not addresses",
+			text: "version 1.2.3.4.5, std::map, at 12:30:45, mac 00:1a:2b:3c:4d:5e, 999.1.1.1, v1.2.3.4, example[.]com, 1:2:3:4:5:6:7:8:9",
+		},
+		{
+			name:     "Multibyte prefix",
+			text:     "→ 8.8.4.4",
+			expected: []Match{{Offset: 4, Length: 7, Text: "8.8.4.4"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Find(tt.text)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected %d matches, got %+v", len(tt.expected), got)
+			}
+			for i, want := range tt.expected {
+				m := got[i]
+				m.Addr = want.Addr
+				if m != want {
+					t.Errorf("match %d: expected %+v, got %+v", i, want, m)
+				}
+				if tt.text[m.Offset:m.Offset+m.Length] != want.Text {
+					t.Errorf("match %d: offset does not point at %q", i, want.Text)
+				}
+			}
+		})
+	}
+}
+
+func TestFindAll(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{text: "ip_192.0.2.1 v1.2.3.4 192.0.2.2_x", expected: []string{"192.0.2.1", "1.2.3.4", "192.0.2.2"}},
+		{text: "abc192.0.2.3 192.0.2.4cafe", expected: []string{"192.0.2.3", "192.0.2.4"}},
+		{text: "x2001:db8::1 version 1.2", expected: []string{"2001:db8::1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			var got []string
+			for _, m := range FindAll(tt.text) {
+				got = append(got, m.Text)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("FindAll(%q) = %v, want %v", tt.text, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	text := "8.8.8.8 8.8.4.4 81.2.69.142 10.0.0.1 8.8.8.8:53 ::ffff:8.8.8.8"
+	reader := &geotest.Reader{
+		Networks: map[string]geo.IPInfo{
+			"8.8.0.0/16":  {ISOCode: "US", Country: "United States", ASN: geotest.ASN(15169), Organization: "Google LLC"},
+			"81.2.0.0/16": {ISOCode: "GB", Country: "United Kingdom", ASN: geotest.ASN(20712), Organization: "Andrews & Arnold Ltd"},
+		},
+	}
+	result, err := Analyze(context.Background(), reader, text, 0)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	if len(result.Addresses) != 4 || result.Occurrences != 6 {
+		t.Fatalf("expected 4 addresses in 6 occurrences, got %d in %d", len(result.Addresses), result.Occurrences)
+	}
+	if first := result.Addresses[0]; first.IP != "8.8.8.8" || len(first.Occurrences) != 3 || first.Info == nil {
+		t.Errorf("unexpected first address: %+v", first)
+	}
+	if private := result.Addresses[3]; !private.Private || private.Info != nil {
+		t.Errorf("expected 10.0.0.1 to be private and not geolocated: %+v", private)
+	}
+	if reader.Lookups() != 3 {
+		t.Errorf("expected 3 lookups, got %d", reader.Lookups())
+	}
+
+	if len(result.Countries) != 2 || result.Countries[0] != (CountryCount{ISOCode: "US", Country: "United States", Count: 2}) {
+		t.Errorf("unexpected country summary: %+v", result.Countries)
+	}
+	if len(result.ASNs) != 2 || result.ASNs[0].ASN != 15169 || result.ASNs[0].Count != 2 || result.ASNs[1].ASN != 20712 {
+		t.Errorf("unexpected ASN summary: %+v", result.ASNs)
+	}
+
+	limited, err := Analyze(context.Background(), reader, text, 2)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if len(limited.Addresses) != 2 || !limited.Truncated {
+		t.Errorf("expected 2 addresses and truncation, got %d (truncated %v)", len(limited.Addresses), limited.Truncated)
+	}
+}
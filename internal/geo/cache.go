@@ -0,0 +1,229 @@
+package geo
+
+import (
+	"container/list"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheConfig configures the lookup cache. Database results are cached per
+// matched network for TTL; reverse DNS results are cached per address for
+// HostnameTTL. Each cache holds up to Size entries.
+type CacheConfig struct {
+	Size        int
+	TTL         time.Duration
+	HostnameTTL time.Duration
+}
+
+// CacheStats reports the state of a cache
+type CacheStats struct {
+	Entries    int   `json:"entries"`
+	Capacity   int   `json:"capacity"`
+	TTLSeconds int64 `json:"ttl_seconds"`
+	Hits       int64 `json:"hits"`
+	Misses     int64 `json:"misses"`
+}
+
+// CacheInfo reports the state of the lookup caches
+type CacheInfo struct {
+	Networks  CacheStats  `json:"networks"`
+	Hostnames *CacheStats `json:"hostnames,omitempty"`
+}
+
+// WithCache caches lookup results. Caches are cleared when the databases
+// are reloaded.
+func WithCache(cfg CacheConfig) Option {
+	return func(r *Reader) {
+		if cfg.Size <= 0 || cfg.TTL <= 0 {
+			return
+		}
+		r.networks = newNetworkCache(cfg.Size, cfg.TTL)
+		if cfg.HostnameTTL > 0 {
+			r.hostnames = newLRU[netip.Addr, rdnsResult](cfg.Size, cfg.HostnameTTL)
+		}
+	}
+}
+
+// lru is a bounded cache with a fixed time to live
+type lru[K comparable, V any] struct {
+	size    int
+	ttl     time.Duration
+	onAdd   func(K)
+	onEvict func(K)
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[K]*list.Element
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type lruEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+func newLRU[K comparable, V any](size int, ttl time.Duration) *lru[K, V] {
+	return &lru[K, V]{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[K]*list.Element),
+	}
+}
+
+// get returns the value for key without counting a hit or miss
+func (c *lru[K, V]) get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	e := el.Value.(*lruEntry[K, V])
+	if time.Now().After(e.expires) {
+		c.remove(el)
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return e.value, true
+}
+
+// lookup is get that counts a hit or miss
+func (c *lru[K, V]) lookup(key K) (V, bool) {
+	v, ok := c.get(key)
+	c.record(ok)
+	return v, ok
+}
+
+func (c *lru[K, V]) record(hit bool) {
+	if hit {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
+// put stores a value, evicting the least recently used entry if full
+func (c *lru[K, V]) put(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := time.Now().Add(c.ttl)
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*lruEntry[K, V])
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value, expires: expires})
+	if c.onAdd != nil {
+		c.onAdd(key)
+	}
+	if c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove drops an entry. Callers must hold c.mu.
+func (c *lru[K, V]) remove(el *list.Element) {
+	key := el.Value.(*lruEntry[K, V]).key
+	c.order.Remove(el)
+	delete(c.entries, key)
+	if c.onEvict != nil {
+		c.onEvict(key)
+	}
+}
+
+// purge drops all entries, keeping the statistics
+func (c *lru[K, V]) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.order.Len() > 0 {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *lru[K, V]) stats() CacheStats {
+	c.mu.Lock()
+	entries := c.order.Len()
+	c.mu.Unlock()
+	return CacheStats{
+		Entries:    entries,
+		Capacity:   c.size,
+		TTLSeconds: int64(c.ttl.Seconds()),
+		Hits:       c.hits.Load(),
+		Misses:     c.misses.Load(),
+	}
+}
+
+// networkCache caches database results by the network they were found in, so
+// that every address of a network shares one entry. Cached networks never
+// overlap because they all come from the same databases.
+type networkCache struct {
+	*lru[netip.Prefix, IPInfo]
+
+	// lengths counts cached networks per family and prefix length, so lookups
+	// only probe lengths that are present. It is updated while the LRU lock
+	// is held.
+	lengthsMu sync.Mutex
+	lengths   [2][129]int
+}
+
+func newNetworkCache(size int, ttl time.Duration) *networkCache {
+	c := &networkCache{lru: newLRU[netip.Prefix, IPInfo](size, ttl)}
+	c.onAdd = c.track
+	c.onEvict = c.untrack
+	return c
+}
+
+func family(addr netip.Addr) int {
+	if addr.Is4() {
+		return 0
+	}
+	return 1
+}
+
+func (c *networkCache) track(p netip.Prefix) {
+	c.lengthsMu.Lock()
+	c.lengths[family(p.Addr())][p.Bits()]++
+	c.lengthsMu.Unlock()
+}
+
+func (c *networkCache) untrack(p netip.Prefix) {
+	c.lengthsMu.Lock()
+	c.lengths[family(p.Addr())][p.Bits()]--
+	c.lengthsMu.Unlock()
+}
+
+// find returns the cached result for the network containing addr
+func (c *networkCache) find(addr netip.Addr) (IPInfo, bool) {
+	f := family(addr)
+	maxBits := addr.BitLen()
+
+	c.lengthsMu.Lock()
+	var candidates []int
+	for bits := maxBits; bits >= 0; bits-- {
+		if c.lengths[f][bits] > 0 {
+			candidates = append(candidates, bits)
+		}
+	}
+	c.lengthsMu.Unlock()
+
+	for _, bits := range candidates {
+		p, _ := addr.Prefix(bits)
+		if info, ok := c.get(p); ok {
+			c.record(true)
+			return info, true
+		}
+	}
+	c.record(false)
+	return IPInfo{}, false
+}
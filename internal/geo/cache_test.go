@@ -0,0 +1,102 @@
+package geo
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestLRUEviction(t *testing.T) {
+	c := newLRU[string, int](2, time.Minute)
+	c.put("a", 1)
+	c.put("b", 2)
+	c.lookup("a")
+	c.put("c", 3)
+
+	if _, ok := c.lookup("b"); ok {
+		t.Error("expected the least recently used entry to be evicted")
+	}
+	if v, ok := c.lookup("a"); !ok || v != 1 {
+		t.Error("expected a recently used entry to be kept")
+	}
+
+	stats := c.stats()
+	if stats.Entries != 2 || stats.Capacity != 2 || stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	c.purge()
+	if c.stats().Entries != 0 {
+		t.Error("expected purge to drop all entries")
+	}
+}
+
+func TestLRUExpiry(t *testing.T) {
+	c := newLRU[string, int](2, time.Millisecond)
+	c.put("a", 1)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.lookup("a"); ok {
+		t.Error("expected an expired entry to be dropped")
+	}
+}
+
+func TestNetworkCache(t *testing.T) {
+	c := newNetworkCache(2, time.Minute)
+	c.put(netip.MustParsePrefix("8.8.8.0/24"), IPInfo{ISOCode: "US"})
+	c.put(netip.MustParsePrefix("2001:db8::/32"), IPInfo{ISOCode: "DE"})
+
+	tests := []struct {
+		addr     string
+		expected string
+		hit      bool
+	}{
+		{addr: "8.8.8.8", expected: "US", hit: true},
+		{addr: "8.8.8.200", expected: "US", hit: true},
+		{addr: "8.8.9.1"},
+		{addr: "2001:db8:1::1", expected: "DE", hit: true},
+		{addr: "2001:db9::1"},
+	}
+	for _, tt := range tests {
+		info, hit := c.find(netip.MustParseAddr(tt.addr))
+		if hit != tt.hit || info.ISOCode != tt.expected {
+			t.Errorf("find(%s): expected %q (hit %v), got %q (hit %v)", tt.addr, tt.expected, tt.hit, info.ISOCode, hit)
+		}
+	}
+
+	// Evicted networks are no longer probed
+	c.put(netip.MustParsePrefix("1.1.1.0/24"), IPInfo{ISOCode: "AU"})
+	if _, hit := c.find(netip.MustParseAddr("8.8.8.8")); hit {
+		t.Error("expected the evicted network to miss")
+	}
+	if c.lengths[0][24] != 1 || c.lengths[1][32] != 1 {
+		t.Errorf("unexpected prefix length counts: v4/24=%d v6/32=%d", c.lengths[0][24], c.lengths[1][32])
+	}
+
+	c.purge()
+	if c.lengths[0][24] != 0 || c.lengths[1][32] != 0 {
+		t.Error("expected purge to reset prefix length counts")
+	}
+}
+
+func TestNarrower(t *testing.T) {
+	_, v4, _ := net.ParseCIDR("8.8.8.0/24")
+	v4.IP = v4.IP.To4()
+	_, v4Wide, _ := net.ParseCIDR("8.8.0.0/16")
+	v4Wide.IP = v4Wide.IP.To4()
+	mapped := &net.IPNet{IP: net.ParseIP("::ffff:8.8.8.0"), Mask: net.CIDRMask(120, 128)}
+
+	p := narrower(netip.Prefix{}, v4Wide)
+	if p.String() != "8.8.0.0/16" {
+		t.Errorf("expected 8.8.0.0/16, got %s", p)
+	}
+	if p = narrower(p, v4); p.String() != "8.8.8.0/24" {
+		t.Errorf("expected 8.8.8.0/24, got %s", p)
+	}
+	if p = narrower(p, v4Wide); p.String() != "8.8.8.0/24" {
+		t.Errorf("expected the narrower network to be kept, got %s", p)
+	}
+	if p = narrower(netip.Prefix{}, mapped); p.String() != "8.8.8.0/24" {
+		t.Errorf("expected IPv4-mapped network to be unmapped, got %s", p)
+	}
+}
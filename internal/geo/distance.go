@@ -0,0 +1,174 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// earthRadiusKm is the mean Earth radius used for great-circle distances
+	earthRadiusKm = 6371.0088
+	// kmPerMile converts between kilometers and statute miles
+	kmPerMile = 1.609344
+)
+
+var (
+	// ErrInvalidPoint is returned when a location is neither an IP address
+	// nor a "lat,lon" pair
+	ErrInvalidPoint = errors.New("invalid location, expected IP address or lat,lon")
+	// ErrNoCoordinates is returned when an IP address has no known location
+	ErrNoCoordinates = errors.New("no coordinates available")
+)
+
+// Point is a geographic location, optionally derived from a geolocated IP
+type Point struct {
+	IP             string  `json:"ip,omitempty"`
+	Latitude       float64 `json:"latitude"`
+	Longitude      float64 `json:"longitude"`
+	AccuracyRadius uint16  `json:"accuracy_radius,omitempty"`
+	Timezone       string  `json:"timezone,omitempty"`
+}
+
+// Distance describes the great-circle distance and initial bearing between
+// two points. The min/max range widens the distance by the accuracy radius
+// of both points.
+type Distance struct {
+	From                    Point    `json:"from"`
+	To                      Point    `json:"to"`
+	Kilometers              float64  `json:"distance_km"`
+	Miles                   float64  `json:"distance_mi"`
+	MinKilometers           float64  `json:"min_distance_km"`
+	MaxKilometers           float64  `json:"max_distance_km"`
+	MinMiles                float64  `json:"min_distance_mi"`
+	MaxMiles                float64  `json:"max_distance_mi"`
+	Bearing                 float64  `json:"bearing"`
+	TimezoneOffsetDiffHours *float64 `json:"timezone_offset_diff_hours,omitempty"`
+	Attribution             string   `json:"attribution"`
+}
+
+// PointFromInfo converts a lookup result into a Point
+func PointFromInfo(info *IPInfo) (Point, error) {
+	if info.Latitude == nil || info.Longitude == nil {
+		return Point{}, fmt.Errorf("%w for %s", ErrNoCoordinates, info.IP)
+	}
+	return Point{
+		IP:             info.IP,
+		Latitude:       *info.Latitude,
+		Longitude:      *info.Longitude,
+		AccuracyRadius: info.AccuracyRadius,
+		Timezone:       info.Timezone,
+	}, nil
+}
+
+// ParsePoint parses a "lat,lon" pair
+func ParsePoint(s string) (Point, error) {
+	latStr, lonStr, ok := strings.Cut(s, ",")
+	if !ok {
+		return Point{}, ErrInvalidPoint
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latStr), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return Point{}, ErrInvalidPoint
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(lonStr), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return Point{}, ErrInvalidPoint
+	}
+	return Point{Latitude: lat, Longitude: lon}, nil
+}
+
+// ResolvePoint parses s as an IP address or a "lat,lon" pair.
+// IP addresses are geolocated with the given reader.
+func ResolvePoint(ctx context.Context, r ReaderInterface, s string) (Point, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		info, err := r.Lookup(ctx, ip)
+		if err != nil {
+			return Point{}, err
+		}
+		return PointFromInfo(info)
+	}
+	return ParsePoint(s)
+}
+
+// Measure computes the distance and bearing between two points.
+// The timezone offset difference is evaluated at the given instant and only
+// reported when both points have a known timezone.
+func Measure(from, to Point, at time.Time) *Distance {
+	km := greatCircleKm(from.Latitude, from.Longitude, to.Latitude, to.Longitude)
+	radius := float64(from.AccuracyRadius) + float64(to.AccuracyRadius)
+	minKm := math.Max(0, km-radius)
+	maxKm := km + radius
+
+	return &Distance{
+		From:                    from,
+		To:                      to,
+		Kilometers:              round2(km),
+		Miles:                   round2(km / kmPerMile),
+		MinKilometers:           round2(minKm),
+		MaxKilometers:           round2(maxKm),
+		MinMiles:                round2(minKm / kmPerMile),
+		MaxMiles:                round2(maxKm / kmPerMile),
+		Bearing:                 round2(initialBearing(from.Latitude, from.Longitude, to.Latitude, to.Longitude)),
+		TimezoneOffsetDiffHours: timezoneOffsetDiff(from.Timezone, to.Timezone, at),
+		Attribution:             Attribution,
+	}
+}
+
+// greatCircleKm returns the haversine distance between two coordinates
+func greatCircleKm(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := radians(lat1), radians(lat2)
+	dPhi := radians(lat2 - lat1)
+	dLambda := radians(lon2 - lon1)
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// initialBearing returns the forward azimuth from the first coordinate to the
+// second in degrees clockwise from true north
+func initialBearing(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := radians(lat1), radians(lat2)
+	dLambda := radians(lon2 - lon1)
+
+	y := math.Sin(dLambda) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dLambda)
+	return math.Mod(degrees(math.Atan2(y, x))+360, 360)
+}
+
+// timezoneOffsetDiff returns the UTC offset of tzTo minus that of tzFrom in hours
+func timezoneOffsetDiff(tzFrom, tzTo string, at time.Time) *float64 {
+	if tzFrom == "" || tzTo == "" {
+		return nil
+	}
+	locFrom, ok := loadLocation(tzFrom)
+	if !ok {
+		return nil
+	}
+	locTo, ok := loadLocation(tzTo)
+	if !ok {
+		return nil
+	}
+
+	_, offFrom := at.In(locFrom).Zone()
+	_, offTo := at.In(locTo).Zone()
+	diff := float64(offTo-offFrom) / 3600
+	return &diff
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -0,0 +1,102 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMeasure(t *testing.T) {
+	london := Point{Latitude: 51.5074, Longitude: -0.1278, Timezone: "Europe/London"}
+	newYork := Point{Latitude: 40.7128, Longitude: -74.0060, Timezone: "America/New_York"}
+	at := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	d := Measure(london, newYork, at)
+
+	if math.Abs(d.Kilometers-5570) > 10 {
+		t.Errorf("expected London-New York distance of ~5570 km, got %.2f", d.Kilometers)
+	}
+	if math.Abs(d.Miles-d.Kilometers/kmPerMile) > 0.01 {
+		t.Errorf("miles %.2f do not match kilometers %.2f", d.Miles, d.Kilometers)
+	}
+	if math.Abs(d.Bearing-288.3) > 0.5 {
+		t.Errorf("expected initial bearing of ~288.3°, got %.2f", d.Bearing)
+	}
+	if d.MinKilometers != d.Kilometers || d.MaxKilometers != d.Kilometers {
+		t.Errorf("expected no distance range without accuracy radius, got %.2f-%.2f", d.MinKilometers, d.MaxKilometers)
+	}
+	if d.TimezoneOffsetDiffHours == nil || *d.TimezoneOffsetDiffHours != -5 {
+		t.Errorf("expected timezone offset difference of -5h, got %v", d.TimezoneOffsetDiffHours)
+	}
+}
+
+func TestMeasureAccuracyRadius(t *testing.T) {
+	a := Point{Latitude: 0, Longitude: 0, AccuracyRadius: 100}
+	b := Point{Latitude: 0, Longitude: 5, AccuracyRadius: 50}
+
+	d := Measure(a, b, time.Now())
+
+	if math.Abs(d.MinKilometers-(d.Kilometers-150)) > 0.02 {
+		t.Errorf("expected min distance %.2f, got %.2f", d.Kilometers-150, d.MinKilometers)
+	}
+	if math.Abs(d.MaxKilometers-(d.Kilometers+150)) > 0.02 {
+		t.Errorf("expected max distance %.2f, got %.2f", d.Kilometers+150, d.MaxKilometers)
+	}
+	if d.TimezoneOffsetDiffHours != nil {
+		t.Error("expected no timezone offset difference without timezones")
+	}
+
+	// Overlapping accuracy circles never yield a negative distance
+	near := Measure(a, Point{Latitude: 0, Longitude: 0.1, AccuracyRadius: 50}, time.Now())
+	if near.MinKilometers != 0 {
+		t.Errorf("expected min distance of 0, got %.2f", near.MinKilometers)
+	}
+}
+
+func TestParsePoint(t *testing.T) {
+	tests := []struct {
+		input string
+		valid bool
+	}{
+		{input: "51.5074,-0.1278", valid: true},
+		{input: " 40.7 , -74.0 ", valid: true},
+		{input: "91,0", valid: false},
+		{input: "0,181", valid: false},
+		{input: "51.5074", valid: false},
+		{input: "north,south", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := ParsePoint(tt.input)
+			if tt.valid && err != nil {
+				t.Errorf("expected %q to parse, got %v", tt.input, err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidPoint) {
+				t.Errorf("expected ErrInvalidPoint for %q, got %v", tt.input, err)
+			}
+		})
+	}
+}
+
+func TestResolvePoint(t *testing.T) {
+	reader := &MockReader{}
+
+	p, err := ResolvePoint(context.Background(), reader, "8.8.8.8")
+	if err != nil {
+		t.Fatalf("ResolvePoint failed: %v", err)
+	}
+	if p.IP != "8.8.8.8" || p.Timezone != "America/Los_Angeles" {
+		t.Errorf("unexpected point for IP: %+v", p)
+	}
+
+	noLocation := &MockReader{MockLookup: func(ip net.IP) (*IPInfo, error) {
+		return &IPInfo{IP: ip.String(), Attribution: Attribution}, nil
+	}}
+	if _, err := ResolvePoint(context.Background(), noLocation, "10.0.0.1"); !errors.Is(err, ErrNoCoordinates) {
+		t.Errorf("expected ErrNoCoordinates, got %v", err)
+	}
+}
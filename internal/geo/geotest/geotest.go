@@ -0,0 +1,57 @@
+// Package geotest provides a fake geo.ReaderInterface for tests.
+package geotest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync/atomic"
+
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+)
+
+// Reader answers Lookup from fixed data. Networks maps addresses and CIDR
+// prefixes to lookup results, the most specific match wins; other addresses
+// get Default. The IP field is always set to the address looked up. Methods
+// other than Lookup are not implemented and panic.
+type Reader struct {
+	geo.ReaderInterface
+	Networks map[string]geo.IPInfo
+	Default  geo.IPInfo
+	lookups  atomic.Int64
+}
+
+// Lookup returns a copy of the configured result for ip
+func (r *Reader) Lookup(ctx context.Context, ip net.IP) (*geo.IPInfo, error) {
+	r.lookups.Add(1)
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return nil, fmt.Errorf("invalid IP address %v", ip)
+	}
+	addr = addr.Unmap()
+
+	info, bits := r.Default, -1
+	for key, v := range r.Networks {
+		p, err := netip.ParsePrefix(key)
+		if err != nil {
+			a := netip.MustParseAddr(key)
+			p = netip.PrefixFrom(a, a.BitLen())
+		}
+		if p.Contains(addr) && p.Bits() > bits {
+			info, bits = v, p.Bits()
+		}
+	}
+	info.IP = ip.String()
+	return &info, nil
+}
+
+// Lookups returns the number of Lookup calls so far
+func (r *Reader) Lookups() int {
+	return int(r.lookups.Load())
+}
+
+// ASN returns a pointer to n, for IPInfo literals
+func ASN(n uint) *uint {
+	return &n
+}
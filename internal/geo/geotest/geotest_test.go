@@ -0,0 +1,46 @@
+package geotest
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+)
+
+func TestReader(t *testing.T) {
+	r := &Reader{
+		Networks: map[string]geo.IPInfo{
+			"8.8.0.0/16":     {ISOCode: "US"},
+			"8.8.8.8":        {ISOCode: "CA"},
+			"2001:4860::/32": {ISOCode: "US"},
+		},
+		Default: geo.IPInfo{ISOCode: "GB"},
+	}
+
+	tests := []struct {
+		ip       string
+		expected string
+	}{
+		{ip: "8.8.8.8", expected: "CA"},
+		{ip: "8.8.4.4", expected: "US"},
+		{ip: "::ffff:8.8.4.4", expected: "US"},
+		{ip: "2001:4860::8888", expected: "US"},
+		{ip: "81.2.69.142", expected: "GB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			info, err := r.Lookup(context.Background(), net.ParseIP(tt.ip))
+			if err != nil {
+				t.Fatalf("Lookup() error = %v", err)
+			}
+			if info.ISOCode != tt.expected || info.IP != net.ParseIP(tt.ip).String() {
+				t.Errorf("Lookup(%s) = %s, %s; want %s", tt.ip, info.IP, info.ISOCode, tt.expected)
+			}
+		})
+	}
+	if r.Lookups() != len(tests) {
+		t.Errorf("Lookups() = %d, want %d", r.Lookups(), len(tests))
+	}
+}
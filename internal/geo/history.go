@@ -0,0 +1,285 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// ErrNoDatabaseForDate is returned when no database generation was built on
+// or before the requested date
+var ErrNoDatabaseForDate = errors.New("no database available for the requested date")
+
+// HistoryConfig configures retention of dated database generations.
+//
+// Each generation lives in its own subdirectory of Dir named after its build
+// date (YYYY-MM-DD) and holds copies of the city and ASN databases under the
+// same file names as the primary databases.
+type HistoryConfig struct {
+	Dir    string        // Directory holding the generations
+	Keep   int           // Maximum number of generations to retain (0 = unlimited)
+	MaxAge time.Duration // Maximum age of a retained generation (0 = unlimited)
+}
+
+// WithHistory enables historical lookups backed by the given directory
+func WithHistory(cfg HistoryConfig) Option {
+	return func(r *Reader) {
+		r.history = cfg
+	}
+}
+
+// Generation is a dated pair of city and ASN databases
+type Generation struct {
+	Build  time.Time
+	Dir    string
+	cityDB *maxminddb.Reader
+	asnDB  *maxminddb.Reader
+}
+
+func (g *Generation) close() error {
+	var errs []error
+	if g.cityDB != nil {
+		if err := g.cityDB.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if g.asnDB != nil {
+		if err := g.asnDB.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// buildTime returns the build time recorded in the database metadata
+func buildTime(db *maxminddb.Reader) time.Time {
+	return time.Unix(int64(db.Metadata.BuildEpoch), 0).UTC()
+}
+
+// buildDate truncates a build time to its UTC calendar day
+func buildDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// LookupAsOf retrieves IP information from the newest database generation
+// built on or before the given date. The primary databases take part in the
+// selection like any archived generation.
+func (r *Reader) LookupAsOf(ctx context.Context, ip net.IP, asOf time.Time) (*IPInfo, error) {
+	info, err := r.lookupAsOf(ip, asOf)
+	if err != nil {
+		return nil, err
+	}
+	statsFrom(ctx).recordLookup(ip, false)
+	if r.enableOnlineFeatures {
+		r.setHostnames(ctx, info, ip)
+	}
+	return info, nil
+}
+
+// lookupAsOf queries the generation selected for asOf
+func (r *Reader) lookupAsOf(ip net.IP, asOf time.Time) (*IPInfo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	current := &Generation{
+		Build:  buildTime(r.cityDB),
+		cityDB: r.cityDB,
+		asnDB:  r.asnDB,
+	}
+	candidates := append([]*Generation{current}, r.generations...)
+
+	g := selectGeneration(candidates, asOf)
+	if g == nil {
+		return nil, ErrNoDatabaseForDate
+	}
+
+	info, _ := lookupDatabases(g.cityDB, g.asnDB, ip)
+	r.enrich(info, ip)
+	info.DatabaseBuild = g.Build.Format(time.DateOnly)
+	return info, nil
+}
+
+// selectGeneration returns the newest generation whose build date is on or
+// before asOf, or nil if there is none
+func selectGeneration(gens []*Generation, asOf time.Time) *Generation {
+	asOf = buildDate(asOf)
+
+	var best *Generation
+	for _, g := range gens {
+		if buildDate(g.Build).After(asOf) {
+			continue
+		}
+		if best == nil || g.Build.After(best.Build) {
+			best = g
+		}
+	}
+	return best
+}
+
+// loadHistory archives the primary databases into the history directory,
+// applies the retention policy and opens the remaining generations
+func (r *Reader) loadHistory() error {
+	if err := os.MkdirAll(r.history.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	currentBuild := buildTime(r.cityDB)
+	if err := r.archiveCurrent(currentBuild); err != nil {
+		return err
+	}
+
+	builds, err := listGenerations(r.history.Dir)
+	if err != nil {
+		return err
+	}
+
+	keep, drop := applyRetention(builds, r.history.Keep, r.history.MaxAge, time.Now())
+	for _, b := range drop {
+		dir := filepath.Join(r.history.Dir, b.Format(time.DateOnly))
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("failed to remove expired database generation %s: %w", dir, err)
+		}
+	}
+
+	for _, b := range keep {
+		// The primary databases already serve their own build date
+		if b.Equal(buildDate(currentBuild)) {
+			continue
+		}
+		g, err := r.openGeneration(filepath.Join(r.history.Dir, b.Format(time.DateOnly)), b)
+		if err != nil {
+			return err
+		}
+		r.generations = append(r.generations, g)
+	}
+
+	return nil
+}
+
+// archiveCurrent copies the primary databases into a generation directory
+// named after their build date, unless that generation already exists
+func (r *Reader) archiveCurrent(build time.Time) error {
+	dir := filepath.Join(r.history.Dir, build.Format(time.DateOnly))
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+
+	// Copy into a temporary directory first so a partially written
+	// generation is never picked up
+	tmp, err := os.MkdirTemp(r.history.Dir, ".archive-")
+	if err != nil {
+		return fmt.Errorf("failed to archive databases: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	for _, src := range []string{r.cityDBPath, r.asnDBPath} {
+		if err := copyFile(src, filepath.Join(tmp, filepath.Base(src))); err != nil {
+			return fmt.Errorf("failed to archive databases: %w", err)
+		}
+	}
+
+	if err := os.Rename(tmp, dir); err != nil {
+		return fmt.Errorf("failed to archive databases: %w", err)
+	}
+	return nil
+}
+
+// openGeneration opens the city and ASN databases stored in dir
+func (r *Reader) openGeneration(dir string, date time.Time) (*Generation, error) {
+	cityDB, err := maxminddb.Open(filepath.Join(dir, filepath.Base(r.cityDBPath)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open city database in %s: %w", dir, err)
+	}
+
+	asnDB, err := maxminddb.Open(filepath.Join(dir, filepath.Base(r.asnDBPath)))
+	if err != nil {
+		cityDB.Close()
+		return nil, fmt.Errorf("failed to open ASN database in %s: %w", dir, err)
+	}
+
+	build := buildTime(cityDB)
+	if build.Unix() == 0 {
+		build = date
+	}
+
+	return &Generation{
+		Build:  build,
+		Dir:    dir,
+		cityDB: cityDB,
+		asnDB:  asnDB,
+	}, nil
+}
+
+// listGenerations returns the build dates of all generation directories in dir.
+// Entries that are not named YYYY-MM-DD are ignored.
+func listGenerations(dir string) ([]time.Time, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history directory: %w", err)
+	}
+
+	var builds []time.Time
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		b, err := time.Parse(time.DateOnly, e.Name())
+		if err != nil {
+			continue
+		}
+		builds = append(builds, b)
+	}
+	return builds, nil
+}
+
+// applyRetention splits build dates into those to keep and those to drop.
+// The newest generations are kept, up to keep entries and no older than maxAge.
+// Kept dates are returned newest first.
+func applyRetention(builds []time.Time, keep int, maxAge time.Duration, now time.Time) (kept, dropped []time.Time) {
+	sorted := make([]time.Time, len(builds))
+	copy(sorted, builds)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].After(sorted[j])
+	})
+
+	for i, b := range sorted {
+		if keep > 0 && i >= keep {
+			dropped = append(dropped, b)
+			continue
+		}
+		if maxAge > 0 && now.Sub(b) > maxAge {
+			dropped = append(dropped, b)
+			continue
+		}
+		kept = append(kept, b)
+	}
+	return kept, dropped
+}
+
+// copyFile copies the contents of src to a new file at dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,129 @@
+package geo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestSelectGeneration(t *testing.T) {
+	gens := []*Generation{
+		{Build: date("2024-03-01").Add(6 * time.Hour)},
+		{Build: date("2024-01-01")},
+		{Build: date("2024-02-01")},
+	}
+
+	tests := []struct {
+		name     string
+		asOf     string
+		expected string
+	}{
+		{name: "exact build date", asOf: "2024-02-01", expected: "2024-02-01"},
+		{name: "between builds", asOf: "2024-02-15", expected: "2024-02-01"},
+		{name: "build later on the same day", asOf: "2024-03-01", expected: "2024-03-01"},
+		{name: "after newest", asOf: "2025-01-01", expected: "2024-03-01"},
+		{name: "before oldest", asOf: "2023-12-31", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := selectGeneration(gens, date(tt.asOf))
+			if tt.expected == "" {
+				if g != nil {
+					t.Errorf("expected no generation, got %s", g.Build)
+				}
+				return
+			}
+			if g == nil {
+				t.Fatalf("expected generation %s, got nil", tt.expected)
+			}
+			if got := g.Build.Format(time.DateOnly); got != tt.expected {
+				t.Errorf("expected generation %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	now := date("2024-04-01")
+	builds := []time.Time{
+		date("2024-01-01"),
+		date("2024-03-31"),
+		date("2024-03-01"),
+		date("2024-02-01"),
+	}
+
+	tests := []struct {
+		name     string
+		keep     int
+		maxAge   time.Duration
+		expected []string
+	}{
+		{
+			name:     "unlimited",
+			expected: []string{"2024-03-31", "2024-03-01", "2024-02-01", "2024-01-01"},
+		},
+		{
+			name:     "by count",
+			keep:     2,
+			expected: []string{"2024-03-31", "2024-03-01"},
+		},
+		{
+			name:     "by age",
+			maxAge:   45 * 24 * time.Hour,
+			expected: []string{"2024-03-31", "2024-03-01"},
+		},
+		{
+			name:     "count and age",
+			keep:     1,
+			maxAge:   45 * 24 * time.Hour,
+			expected: []string{"2024-03-31"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, dropped := applyRetention(builds, tt.keep, tt.maxAge, now)
+			if len(kept)+len(dropped) != len(builds) {
+				t.Errorf("expected %d generations in total, got %d", len(builds), len(kept)+len(dropped))
+			}
+			if len(kept) != len(tt.expected) {
+				t.Fatalf("expected %d kept generations, got %d", len(tt.expected), len(kept))
+			}
+			for i, b := range kept {
+				if got := b.Format(time.DateOnly); got != tt.expected[i] {
+					t.Errorf("kept[%d]: expected %s, got %s", i, tt.expected[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestListGenerations(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"2024-01-01", "2024-02-01", ".archive-123", "latest"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "2024-03-01"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	builds, err := listGenerations(dir)
+	if err != nil {
+		t.Fatalf("listGenerations failed: %v", err)
+	}
+	if len(builds) != 2 {
+		t.Errorf("expected 2 generations, got %d: %v", len(builds), builds)
+	}
+}
@@ -0,0 +1,200 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+var (
+	// ErrInvalidHost is returned for names that are not valid DNS hostnames
+	ErrInvalidHost = errors.New("invalid hostname")
+	// ErrHostNotFound is returned when a hostname has no addresses
+	ErrHostNotFound = errors.New("hostname not found")
+	// ErrOnlineFeaturesDisabled is returned by lookups that need DNS when
+	// online features are disabled
+	ErrOnlineFeaturesDisabled = errors.New("online features are disabled")
+)
+
+// maxMXHosts caps the number of MX targets resolved for a host lookup
+const maxMXHosts = 5
+
+// HostOptions selects the optional parts of a host lookup
+type HostOptions struct {
+	CNAME bool // Report the canonical name the host is an alias for
+	MX    bool // Also resolve and geolocate the host's mail exchangers
+}
+
+// DNSRecord is one step of a host resolution
+type DNSRecord struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"` // CNAME, A, AAAA or MX
+	Value      string `json:"value"`
+	Preference uint16 `json:"preference,omitempty"` // MX only
+}
+
+// HostAddress is a geolocated address of a host or one of its mail
+// exchangers. Name is the hostname the address was resolved from.
+type HostAddress struct {
+	Name string `json:"name"`
+	*IPInfo
+}
+
+// HostInfo is the result of resolving and geolocating a hostname. Chain
+// lists the records in resolution order. Intermediate aliases are collapsed
+// by the resolver, so a CNAME record points at the final canonical name.
+type HostInfo struct {
+	Host        string        `json:"host"`
+	Chain       []DNSRecord   `json:"chain"`
+	Addresses   []HostAddress `json:"addresses"`
+	Attribution string        `json:"attribution"`
+}
+
+// LookupHost resolves host (A and AAAA, optionally CNAME and MX) with the
+// configured resolver and geolocates every address. The DNS part is bounded
+// by the reverse DNS timeout and worker limit.
+func (r *Reader) LookupHost(ctx context.Context, host string, opts HostOptions) (*HostInfo, error) {
+	if !r.enableOnlineFeatures {
+		return nil, ErrOnlineFeaturesDisabled
+	}
+	host, ok := normalizeHost(host)
+	if !ok {
+		return nil, ErrInvalidHost
+	}
+
+	dnsCtx := ctx
+	if r.rdns.Timeout > 0 {
+		var cancel context.CancelFunc
+		dnsCtx, cancel = context.WithTimeout(ctx, r.rdns.Timeout)
+		defer cancel()
+	}
+	if r.rdnsSlots != nil {
+		select {
+		case r.rdnsSlots <- struct{}{}:
+		case <-dnsCtx.Done():
+			return nil, dnsCtx.Err()
+		}
+	}
+	result, err := r.resolveHost(dnsCtx, host, opts)
+	if r.rdnsSlots != nil {
+		<-r.rdnsSlots
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Geolocate outside the worker slot, as each lookup may need one for
+	// reverse DNS
+	for i, a := range result.Addresses {
+		info, err := r.Lookup(ctx, net.ParseIP(a.IP))
+		if err != nil {
+			return nil, err
+		}
+		result.Addresses[i].IPInfo = info
+	}
+	return result, nil
+}
+
+// resolveHost collects the DNS records of host. Addresses are returned with
+// only their IP set.
+func (r *Reader) resolveHost(ctx context.Context, host string, opts HostOptions) (*HostInfo, error) {
+	result := &HostInfo{
+		Host:        host,
+		Chain:       []DNSRecord{},
+		Addresses:   []HostAddress{},
+		Attribution: Attribution,
+	}
+
+	name := host
+	if opts.CNAME {
+		cname, err := r.resolver.LookupCNAME(ctx, host)
+		if err != nil && !isNotFound(err) {
+			return nil, fmt.Errorf("failed to resolve %s: %w", host, err)
+		}
+		if cname = strings.TrimSuffix(strings.ToLower(cname), "."); cname != "" && cname != host {
+			result.Chain = append(result.Chain, DNSRecord{Name: host, Type: "CNAME", Value: cname})
+			name = cname
+		}
+	}
+
+	// A host without addresses may still have mail exchangers
+	seen := make(map[string]bool)
+	if err := r.resolveAddresses(ctx, result, host, name, seen); err != nil && !errors.Is(err, ErrHostNotFound) {
+		return nil, err
+	}
+
+	if opts.MX {
+		mxs, err := r.resolver.LookupMX(ctx, host)
+		if err != nil && !isNotFound(err) {
+			return nil, fmt.Errorf("failed to resolve MX for %s: %w", host, err)
+		}
+		for i, mx := range mxs {
+			target := strings.TrimSuffix(strings.ToLower(mx.Host), ".")
+			if target == "" {
+				// A null MX (RFC 7505) means the host accepts no mail
+				continue
+			}
+			result.Chain = append(result.Chain, DNSRecord{Name: host, Type: "MX", Value: target, Preference: mx.Pref})
+			if i >= maxMXHosts {
+				continue
+			}
+			if err := r.resolveAddresses(ctx, result, target, target, seen); err != nil && !errors.Is(err, ErrHostNotFound) {
+				return nil, err
+			}
+		}
+	}
+
+	if len(result.Addresses) == 0 {
+		return nil, ErrHostNotFound
+	}
+	return result, nil
+}
+
+// resolveAddresses appends the A and AAAA records of host to result. name is
+// the owner name recorded in the chain, i.e. the canonical name if known.
+func (r *Reader) resolveAddresses(ctx context.Context, result *HostInfo, host, name string, seen map[string]bool) error {
+	addrs, err := r.resolver.LookupIPAddr(ctx, host)
+	if isNotFound(err) {
+		return ErrHostNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+
+	for _, a := range addrs {
+		ip := a.IP.String()
+		typ := "AAAA"
+		if a.IP.To4() != nil {
+			typ = "A"
+		}
+		result.Chain = append(result.Chain, DNSRecord{Name: name, Type: typ, Value: ip})
+		if seen[ip] {
+			continue
+		}
+		seen[ip] = true
+		result.Addresses = append(result.Addresses, HostAddress{Name: host, IPInfo: &IPInfo{IP: ip}})
+	}
+	return nil
+}
+
+// normalizeHost lowercases host, strips a trailing dot and reports whether
+// the result is a valid DNS hostname. IP literals are rejected.
+func normalizeHost(host string) (string, bool) {
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	if host == "" || len(host) > 253 || net.ParseIP(host) != nil {
+		return "", false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return "", false
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' && c != '_' {
+				return "", false
+			}
+		}
+	}
+	return host, true
+}
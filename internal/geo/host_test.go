@@ -0,0 +1,135 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var hostZone = testZone{
+	"example.com. A":         {"192.0.2.10"},
+	"example.com. AAAA":      {"2001:db8::10"},
+	"example.com. MX":        {"10 mail.example.com.", "20 backup.example.net."},
+	"mail.example.com. A":    {"192.0.2.20"},
+	"backup.example.net. A":  {"192.0.2.10"},
+	"www.example.com. CNAME": {"edge.cdn.example."},
+	"edge.cdn.example. A":    {"198.51.100.1"},
+	"mailonly.example. MX":   {"10 mail.example.com."},
+}
+
+func TestResolveHost(t *testing.T) {
+	server := startDNSServer(t, hostZone)
+	res, err := NewResolver(ResolverConfig{Servers: []string{server.udpAddr}})
+	if err != nil {
+		t.Fatalf("NewResolver failed: %v", err)
+	}
+	r := &Reader{enableOnlineFeatures: true, resolver: res}
+
+	tests := []struct {
+		name      string
+		host      string
+		opts      HostOptions
+		chain     []DNSRecord
+		addresses []string
+	}{
+		{
+			name: "A and AAAA",
+			host: "Example.COM.",
+			chain: []DNSRecord{
+				{Name: "example.com", Type: "A", Value: "192.0.2.10"},
+				{Name: "example.com", Type: "AAAA", Value: "2001:db8::10"},
+			},
+			addresses: []string{"example.com 192.0.2.10", "example.com 2001:db8::10"},
+		},
+		{
+			name: "CNAME",
+			host: "www.example.com",
+			opts: HostOptions{CNAME: true},
+			chain: []DNSRecord{
+				{Name: "www.example.com", Type: "CNAME", Value: "edge.cdn.example"},
+				{Name: "edge.cdn.example", Type: "A", Value: "198.51.100.1"},
+			},
+			addresses: []string{"www.example.com 198.51.100.1"},
+		},
+		{
+			name: "MX",
+			host: "example.com",
+			opts: HostOptions{MX: true},
+			chain: []DNSRecord{
+				{Name: "example.com", Type: "A", Value: "192.0.2.10"},
+				{Name: "example.com", Type: "AAAA", Value: "2001:db8::10"},
+				{Name: "example.com", Type: "MX", Value: "mail.example.com", Preference: 10},
+				{Name: "mail.example.com", Type: "A", Value: "192.0.2.20"},
+				{Name: "example.com", Type: "MX", Value: "backup.example.net", Preference: 20},
+				{Name: "backup.example.net", Type: "A", Value: "192.0.2.10"},
+			},
+			// The backup MX shares an address with the host and is not repeated
+			addresses: []string{"example.com 192.0.2.10", "example.com 2001:db8::10", "mail.example.com 192.0.2.20"},
+		},
+		{
+			name: "MX only",
+			host: "mailonly.example",
+			opts: HostOptions{MX: true},
+			chain: []DNSRecord{
+				{Name: "mailonly.example", Type: "MX", Value: "mail.example.com", Preference: 10},
+				{Name: "mail.example.com", Type: "A", Value: "192.0.2.20"},
+			},
+			addresses: []string{"mail.example.com 192.0.2.20"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			host, _ := normalizeHost(tt.host)
+			result, err := r.resolveHost(ctx, host, tt.opts)
+			if err != nil {
+				t.Fatalf("resolveHost failed: %v", err)
+			}
+
+			if len(result.Chain) != len(tt.chain) {
+				t.Fatalf("expected chain %+v, got %+v", tt.chain, result.Chain)
+			}
+			for i := range tt.chain {
+				if result.Chain[i] != tt.chain[i] {
+					t.Errorf("chain[%d]: expected %+v, got %+v", i, tt.chain[i], result.Chain[i])
+				}
+			}
+
+			var addresses []string
+			for _, a := range result.Addresses {
+				addresses = append(addresses, a.Name+" "+a.IP)
+			}
+			if len(addresses) != len(tt.addresses) {
+				t.Fatalf("expected addresses %v, got %v", tt.addresses, addresses)
+			}
+			for i := range addresses {
+				if addresses[i] != tt.addresses[i] {
+					t.Errorf("address %d: expected %s, got %s", i, tt.addresses[i], addresses[i])
+				}
+			}
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := r.resolveHost(ctx, "missing.example", HostOptions{MX: true}); !errors.Is(err, ErrHostNotFound) {
+		t.Errorf("expected ErrHostNotFound, got %v", err)
+	}
+}
+
+func TestLookupHostErrors(t *testing.T) {
+	offline := &Reader{}
+	if _, err := offline.LookupHost(context.Background(), "example.com", HostOptions{}); !errors.Is(err, ErrOnlineFeaturesDisabled) {
+		t.Errorf("expected ErrOnlineFeaturesDisabled, got %v", err)
+	}
+
+	online := &Reader{enableOnlineFeatures: true}
+	for _, host := range []string{"", "8.8.8.8", "::1", "exa mple.com", "-bad.example", "a..b", "http://example.com"} {
+		if _, err := online.LookupHost(context.Background(), host, HostOptions{}); !errors.Is(err, ErrInvalidHost) {
+			t.Errorf("LookupHost(%q): expected ErrInvalidHost, got %v", host, err)
+		}
+	}
+}
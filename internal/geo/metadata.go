@@ -0,0 +1,69 @@
+package geo
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/jcjc-dev/ipwhere/internal/cloud"
+	"github.com/jcjc-dev/ipwhere/internal/lists"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Metadata describes the data sources backing a Reader
+type Metadata struct {
+	Databases   []DatabaseInfo     `json:"databases"`
+	History     []string           `json:"history,omitempty"`
+	CloudRanges []cloud.SourceInfo `json:"cloud_ranges,omitempty"`
+	Lists       []lists.Info       `json:"lists,omitempty"`
+	Cache       *CacheInfo         `json:"cache,omitempty"`
+}
+
+// DatabaseInfo describes an open MMDB database
+type DatabaseInfo struct {
+	Type       string    `json:"type"`
+	File       string    `json:"file"`
+	Build      time.Time `json:"build"`
+	AgeSeconds int64     `json:"age_seconds"`
+}
+
+// Metadata returns information about the loaded databases, range files and caches.
+// History lists the build dates of retained database generations.
+func (r *Reader) Metadata() *Metadata {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	m := &Metadata{
+		Databases: []DatabaseInfo{
+			databaseInfo(r.cityDB, r.cityDBPath, now),
+			databaseInfo(r.asnDB, r.asnDBPath, now),
+		},
+	}
+	for _, g := range r.generations {
+		m.History = append(m.History, g.Build.Format(time.DateOnly))
+	}
+	if r.cloud != nil {
+		m.CloudRanges = r.cloud.Sources()
+	}
+	if r.lists != nil {
+		m.Lists = r.lists.Lists()
+	}
+	if r.networks != nil {
+		m.Cache = &CacheInfo{Networks: r.networks.stats()}
+		if r.hostnames != nil {
+			stats := r.hostnames.stats()
+			m.Cache.Hostnames = &stats
+		}
+	}
+	return m
+}
+
+func databaseInfo(db *maxminddb.Reader, path string, now time.Time) DatabaseInfo {
+	build := buildTime(db)
+	return DatabaseInfo{
+		Type:       db.Metadata.DatabaseType,
+		File:       filepath.Base(path),
+		Build:      build,
+		AgeSeconds: int64(now.Sub(build).Seconds()),
+	}
+}
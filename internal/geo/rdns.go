@@ -0,0 +1,164 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/jcjc-dev/ipwhere/internal/netindex"
+)
+
+const (
+	// DefaultRDNSTimeout bounds a reverse DNS lookup including verification
+	DefaultRDNSTimeout = 2 * time.Second
+	// DefaultRDNSWorkers is the default number of concurrent reverse DNS lookups
+	DefaultRDNSWorkers = 32
+
+	// maxVerifiedNames caps the forward lookups made to confirm PTR names
+	maxVerifiedNames = 5
+)
+
+// RDNSConfig configures reverse DNS lookups made when online features are
+// enabled. Lookups that cannot get a worker before Timeout elapses return
+// no hostname.
+type RDNSConfig struct {
+	Timeout time.Duration // Deadline for the PTR and confirming lookups (0 = none)
+	Workers int           // Maximum concurrent lookups (0 = unlimited)
+}
+
+// WithRDNS configures the reverse DNS timeout and worker limit
+func WithRDNS(cfg RDNSConfig) Option {
+	return func(r *Reader) {
+		r.rdns = cfg
+	}
+}
+
+// resolver is the subset of net.Resolver used for online features
+type resolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	LookupCNAME(ctx context.Context, host string) (string, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+}
+
+// rdnsResult is the outcome of a reverse DNS lookup. verified is the first
+// name that resolves back to the address, if any.
+type rdnsResult struct {
+	names    []string
+	verified string
+}
+
+// setHostnames fills the hostname fields of info. It must be called without
+// holding r.mu, as DNS lookups may block until the timeout.
+func (r *Reader) setHostnames(ctx context.Context, info *IPInfo, ip net.IP) {
+	start := time.Now()
+	res := r.reverseDNS(ctx, ip)
+	statsFrom(ctx).recordRDNS(time.Since(start))
+	if len(res.names) == 0 {
+		return
+	}
+
+	verified := res.verified != ""
+	info.Hostnames = res.names
+	info.Hostname = res.names[0]
+	if verified {
+		info.Hostname = res.verified
+	}
+	info.HostnameVerified = &verified
+}
+
+// reverseDNS resolves the PTR names of ip and confirms them with forward
+// lookups (FCrDNS), using the hostname cache if configured. Only definitive
+// answers are cached.
+func (r *Reader) reverseDNS(ctx context.Context, ip net.IP) rdnsResult {
+	addr, ok := netindex.AddrFromIP(ip)
+	if !ok {
+		return rdnsResult{}
+	}
+	if r.hostnames != nil {
+		if res, hit := r.hostnames.lookup(addr); hit {
+			return res
+		}
+	}
+
+	if r.rdns.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.rdns.Timeout)
+		defer cancel()
+	}
+
+	if r.rdnsSlots != nil {
+		select {
+		case r.rdnsSlots <- struct{}{}:
+			defer func() { <-r.rdnsSlots }()
+		case <-ctx.Done():
+			return rdnsResult{}
+		}
+	}
+
+	res, definitive := r.resolve(ctx, addr)
+	if definitive && r.hostnames != nil {
+		r.hostnames.put(addr, res)
+	}
+	return res
+}
+
+// resolve performs the PTR and confirming forward lookups. It reports
+// whether the result is definitive, i.e. not cut short by a timeout or a
+// temporary resolver failure.
+func (r *Reader) resolve(ctx context.Context, addr netip.Addr) (rdnsResult, bool) {
+	ptrs, err := r.resolver.LookupAddr(ctx, addr.String())
+	if err != nil {
+		return rdnsResult{}, isNotFound(err)
+	}
+
+	var res rdnsResult
+	seen := make(map[string]bool, len(ptrs))
+	for _, ptr := range ptrs {
+		// Remove trailing dot from FQDN hostname
+		name := strings.TrimSuffix(ptr, ".")
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		res.names = append(res.names, name)
+	}
+
+	definitive := true
+	for i, name := range res.names {
+		if i == maxVerifiedNames {
+			break
+		}
+		addrs, err := r.resolver.LookupIPAddr(ctx, name)
+		if err != nil {
+			if !isNotFound(err) {
+				definitive = false
+			}
+			continue
+		}
+		if resolvesTo(addrs, addr) {
+			res.verified = name
+			return res, true
+		}
+	}
+	return res, definitive
+}
+
+// resolvesTo reports whether addrs contains addr
+func resolvesTo(addrs []net.IPAddr, addr netip.Addr) bool {
+	for _, a := range addrs {
+		if got, ok := netindex.AddrFromIP(a.IP); ok && got == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// isNotFound reports whether err is a definitive "no such record" answer
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
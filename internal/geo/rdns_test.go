@@ -0,0 +1,167 @@
+package geo
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// fakeResolver answers from static PTR and address tables
+type fakeResolver struct {
+	ptr   map[string][]string
+	addrs map[string][]string
+	delay time.Duration
+	calls int
+}
+
+func (f *fakeResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	f.calls++
+	if f.delay > 0 {
+		select {
+		case <-time.After(f.delay):
+		case <-ctx.Done():
+			return nil, &net.DNSError{Err: "i/o timeout", Name: addr, IsTimeout: true}
+		}
+	}
+	names, ok := f.ptr[addr]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+	}
+	return names, nil
+}
+
+func (f *fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	addrs, ok := f.addrs[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	var result []net.IPAddr
+	for _, a := range addrs {
+		result = append(result, net.IPAddr{IP: net.ParseIP(a)})
+	}
+	return result, nil
+}
+
+func (f *fakeResolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	return host + ".", nil
+}
+
+func (f *fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func newRDNSReader(res resolver, cfg RDNSConfig) *Reader {
+	r := &Reader{enableOnlineFeatures: true, resolver: res, rdns: cfg}
+	if cfg.Workers > 0 {
+		r.rdnsSlots = make(chan struct{}, cfg.Workers)
+	}
+	return r
+}
+
+func TestSetHostnames(t *testing.T) {
+	res := &fakeResolver{
+		ptr: map[string][]string{
+			"192.0.2.1":   {"spoofed.example.", "mail.example.com.", "mail.example.com."},
+			"192.0.2.2":   {"unverified.example."},
+			"2001:db8::1": {"v6.example.net."},
+		},
+		addrs: map[string][]string{
+			"spoofed.example":  {"198.51.100.7"},
+			"mail.example.com": {"192.0.2.1"},
+			"v6.example.net":   {"2001:db8::1"},
+		},
+	}
+	r := newRDNSReader(res, RDNSConfig{Timeout: time.Second, Workers: 2})
+
+	tests := []struct {
+		ip        string
+		hostname  string
+		hostnames int
+		verified  *bool
+	}{
+		{ip: "192.0.2.1", hostname: "mail.example.com", hostnames: 2, verified: boolPtr(true)},
+		{ip: "192.0.2.2", hostname: "unverified.example", hostnames: 1, verified: boolPtr(false)},
+		{ip: "2001:db8::1", hostname: "v6.example.net", hostnames: 1, verified: boolPtr(true)},
+		{ip: "192.0.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			info := &IPInfo{}
+			r.setHostnames(context.Background(), info, net.ParseIP(tt.ip))
+			if info.Hostname != tt.hostname {
+				t.Errorf("expected hostname %q, got %q", tt.hostname, info.Hostname)
+			}
+			if len(info.Hostnames) != tt.hostnames {
+				t.Errorf("expected %d hostnames, got %v", tt.hostnames, info.Hostnames)
+			}
+			switch {
+			case tt.verified == nil && info.HostnameVerified != nil:
+				t.Errorf("expected no hostname_verified, got %v", *info.HostnameVerified)
+			case tt.verified != nil && (info.HostnameVerified == nil || *info.HostnameVerified != *tt.verified):
+				t.Errorf("expected hostname_verified %v, got %v", *tt.verified, info.HostnameVerified)
+			}
+		})
+	}
+}
+
+func TestReverseDNSTimeout(t *testing.T) {
+	res := &fakeResolver{
+		ptr:   map[string][]string{"192.0.2.1": {"slow.example."}},
+		delay: time.Second,
+	}
+	r := newRDNSReader(res, RDNSConfig{Timeout: 20 * time.Millisecond})
+	r.hostnames = newLRU[netip.Addr, rdnsResult](8, time.Minute)
+
+	start := time.Now()
+	info := &IPInfo{}
+	r.setHostnames(context.Background(), info, net.ParseIP("192.0.2.1"))
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected lookup to stop at the timeout, took %v", elapsed)
+	}
+	if info.Hostname != "" {
+		t.Errorf("expected no hostname after timeout, got %q", info.Hostname)
+	}
+	if r.hostnames.stats().Entries != 0 {
+		t.Error("expected timed out lookups not to be cached")
+	}
+}
+
+func TestReverseDNSWorkers(t *testing.T) {
+	res := &fakeResolver{ptr: map[string][]string{"192.0.2.1": {"host.example."}}}
+	r := newRDNSReader(res, RDNSConfig{Timeout: 20 * time.Millisecond, Workers: 1})
+
+	// Occupy the only worker; the lookup gives up once its deadline passes
+	r.rdnsSlots <- struct{}{}
+	info := &IPInfo{}
+	r.setHostnames(context.Background(), info, net.ParseIP("192.0.2.1"))
+	if info.Hostname != "" || res.calls != 0 {
+		t.Errorf("expected no lookup without a free worker, got %q after %d calls", info.Hostname, res.calls)
+	}
+
+	<-r.rdnsSlots
+	r.setHostnames(context.Background(), info, net.ParseIP("192.0.2.1"))
+	if info.Hostname != "host.example" {
+		t.Errorf("expected hostname once a worker is free, got %q", info.Hostname)
+	}
+}
+
+func TestReverseDNSCache(t *testing.T) {
+	res := &fakeResolver{ptr: map[string][]string{"192.0.2.1": {"host.example."}}}
+	r := newRDNSReader(res, RDNSConfig{Timeout: time.Second})
+	r.hostnames = newLRU[netip.Addr, rdnsResult](8, time.Minute)
+
+	for range 3 {
+		r.setHostnames(context.Background(), &IPInfo{}, net.ParseIP("192.0.2.1"))
+		r.setHostnames(context.Background(), &IPInfo{}, net.ParseIP("192.0.2.9"))
+	}
+	if res.calls != 2 {
+		t.Errorf("expected found and not-found answers to be cached, got %d PTR lookups", res.calls)
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -1,82 +1,290 @@
 package geo
 
 import (
+	"context"
 	"fmt"
 	"net"
-	"strings"
+	"net/netip"
+	"os"
 	"sync"
+	"time"
 
+	"github.com/jcjc-dev/ipwhere/internal/cloud"
+	"github.com/jcjc-dev/ipwhere/internal/country"
+	"github.com/jcjc-dev/ipwhere/internal/lists"
+	"github.com/jcjc-dev/ipwhere/internal/netclass"
+	"github.com/jcjc-dev/ipwhere/internal/netindex"
 	"github.com/oschwald/geoip2-golang"
+	"github.com/oschwald/maxminddb-golang"
 )
 
-// IPInfo represents the complete IP geolocation information
+// IPInfo represents the complete IP geolocation information.
+// AccuracyRadius is expressed in kilometers. The local time fields are derived
+// from Timezone (see SetLocalTime). DatabaseBuild is only set by LookupAsOf and
+// names the database generation that answered the lookup. NetworkType is one
+// of the netclass types; IsHosting is also set for addresses in cloud ranges.
+// Hostnames holds every PTR name; Hostname is the first forward-confirmed one
+// (or the first name if none is) and HostnameVerified reports which case applies.
 type IPInfo struct {
-	IP           string   `json:"ip"`
-	Hostname     string   `json:"hostname,omitempty"`
-	Country      string   `json:"country,omitempty"`
-	ISOCode      string   `json:"iso_code,omitempty"`
-	InEU         bool     `json:"in_eu,omitempty"`
-	City         string   `json:"city,omitempty"`
-	Region       string   `json:"region,omitempty"`
-	Latitude     *float64 `json:"latitude,omitempty"`
-	Longitude    *float64 `json:"longitude,omitempty"`
-	Timezone     string   `json:"timezone,omitempty"`
-	ASN          *uint    `json:"asn,omitempty"`
-	Organization string   `json:"organization,omitempty"`
-	Attribution  string   `json:"attribution"`
+	IP               string        `json:"ip"`
+	Hostname         string        `json:"hostname,omitempty"`
+	Hostnames        []string      `json:"hostnames,omitempty"`
+	HostnameVerified *bool         `json:"hostname_verified,omitempty"`
+	Country          string        `json:"country,omitempty"`
+	ISOCode          string        `json:"iso_code,omitempty"`
+	InEU             bool          `json:"in_eu,omitempty"`
+	City             string        `json:"city,omitempty"`
+	Region           string        `json:"region,omitempty"`
+	Latitude         *float64      `json:"latitude,omitempty"`
+	Longitude        *float64      `json:"longitude,omitempty"`
+	AccuracyRadius   uint16        `json:"accuracy_radius,omitempty"`
+	Timezone         string        `json:"timezone,omitempty"`
+	LocalTime        string        `json:"local_time,omitempty"`
+	UTCOffset        string        `json:"utc_offset,omitempty"`
+	IsDST            *bool         `json:"is_dst,omitempty"`
+	TZAbbreviation   string        `json:"tz_abbreviation,omitempty"`
+	ASN              *uint         `json:"asn,omitempty"`
+	Organization     string        `json:"organization,omitempty"`
+	NetworkType      string        `json:"network_type,omitempty"`
+	IsHosting        bool          `json:"is_hosting,omitempty"`
+	CloudProvider    string        `json:"cloud_provider,omitempty"`
+	CloudRegion      string        `json:"cloud_region,omitempty"`
+	CloudService     string        `json:"cloud_service,omitempty"`
+	Lists            []lists.Match `json:"lists,omitempty"`
+	DatabaseBuild    string        `json:"database_build,omitempty"`
+	Attribution      string        `json:"attribution"`
 }
 
 // Attribution is the required attribution for DB-IP
 const Attribution = "IP Geolocation by DB-IP (https://db-ip.com)"
 
-// Reader wraps the geoip2 database readers
+// Reader wraps the MMDB database readers
 type Reader struct {
-	cityDB               *geoip2.Reader
-	asnDB                *geoip2.Reader
+	cityDB               *maxminddb.Reader
+	asnDB                *maxminddb.Reader
+	cityDBPath           string
+	asnDBPath            string
+	cityModTime          time.Time
+	asnModTime           time.Time
 	enableOnlineFeatures bool
+	history              HistoryConfig
+	generations          []*Generation
+	cloud                *cloud.Ranges
+	lists                *lists.Set
+	classifier           *netclass.Classifier
+	networks             *networkCache
+	hostnames            *lru[netip.Addr, rdnsResult]
+	resolver             resolver
+	rdns                 RDNSConfig
+	rdnsSlots            chan struct{}
 	mu                   sync.RWMutex
 }
 
 // ReaderInterface defines the interface for geo lookups (useful for testing)
 type ReaderInterface interface {
-	Lookup(ip net.IP) (*IPInfo, error)
+	Lookup(ctx context.Context, ip net.IP) (*IPInfo, error)
+	LookupAsOf(ctx context.Context, ip net.IP, asOf time.Time) (*IPInfo, error)
+	LookupHost(ctx context.Context, host string, opts HostOptions) (*HostInfo, error)
+	Metadata() *Metadata
 	Close() error
 	OnlineFeaturesEnabled() bool
 }
 
+// Option configures optional Reader behaviour
+type Option func(*Reader)
+
+// WithCloudRanges tags lookups with the cloud provider range they fall into
+func WithCloudRanges(ranges *cloud.Ranges) Option {
+	return func(r *Reader) {
+		r.cloud = ranges
+	}
+}
+
+// WithLists tags lookups with the threat lists containing the address
+func WithLists(set *lists.Set) Option {
+	return func(r *Reader) {
+		r.lists = set
+	}
+}
+
+// WithClassifier replaces the bundled network type classifier
+func WithClassifier(c *netclass.Classifier) Option {
+	return func(r *Reader) {
+		r.classifier = c
+	}
+}
+
 // NewReader creates a new geo reader from the given database paths
-func NewReader(cityDBPath, asnDBPath string, enableOnlineFeatures bool) (*Reader, error) {
-	cityDB, err := geoip2.Open(cityDBPath)
+func NewReader(cityDBPath, asnDBPath string, enableOnlineFeatures bool, opts ...Option) (*Reader, error) {
+	r := &Reader{
+		cityDBPath:           cityDBPath,
+		asnDBPath:            asnDBPath,
+		enableOnlineFeatures: enableOnlineFeatures,
+		classifier:           netclass.Default(),
+		resolver:             net.DefaultResolver,
+		rdns: RDNSConfig{
+			Timeout: DefaultRDNSTimeout,
+			Workers: DefaultRDNSWorkers,
+		},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.rdns.Workers > 0 {
+		r.rdnsSlots = make(chan struct{}, r.rdns.Workers)
+	}
+
+	if err := r.openDatabases(); err != nil {
+		return nil, err
+	}
+
+	if r.history.Dir != "" {
+		if err := r.loadHistory(); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// openDatabases opens the city and ASN databases and records their
+// modification times. Callers must hold r.mu for writing, or have exclusive
+// access to r.
+func (r *Reader) openDatabases() error {
+	cityModTime, err := modTime(r.cityDBPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open city database: %w", err)
+		return fmt.Errorf("failed to open city database: %w", err)
+	}
+	asnModTime, err := modTime(r.asnDBPath)
+	if err != nil {
+		return fmt.Errorf("failed to open ASN database: %w", err)
+	}
+
+	cityDB, err := maxminddb.Open(r.cityDBPath)
+	if err != nil {
+		return fmt.Errorf("failed to open city database: %w", err)
 	}
 
-	asnDB, err := geoip2.Open(asnDBPath)
+	asnDB, err := maxminddb.Open(r.asnDBPath)
 	if err != nil {
 		cityDB.Close()
-		return nil, fmt.Errorf("failed to open ASN database: %w", err)
+		return fmt.Errorf("failed to open ASN database: %w", err)
 	}
 
-	return &Reader{
-		cityDB:               cityDB,
-		asnDB:                asnDB,
-		enableOnlineFeatures: enableOnlineFeatures,
-	}, nil
+	r.cityDB, r.asnDB = cityDB, asnDB
+	r.cityModTime, r.asnModTime = cityModTime, asnModTime
+	return nil
 }
 
-// Lookup retrieves IP information for the given IP address
-func (r *Reader) Lookup(ip net.IP) (*IPInfo, error) {
+func modTime(path string) (time.Time, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return st.ModTime(), nil
+}
+
+// Reload reopens the databases if their files changed on disk, clearing the
+// lookup caches and re-applying the history retention. Files must be replaced
+// atomically (e.g. by rename). On failure the current databases stay in use.
+func (r *Reader) Reload() error {
+	cityModTime, err := modTime(r.cityDBPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat city database: %w", err)
+	}
+	asnModTime, err := modTime(r.asnDBPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat ASN database: %w", err)
+	}
+
+	r.mu.RLock()
+	unchanged := cityModTime.Equal(r.cityModTime) && asnModTime.Equal(r.asnModTime)
+	r.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	oldCityDB, oldASNDB := r.cityDB, r.asnDB
+	if err := r.openDatabases(); err != nil {
+		return err
+	}
+	oldCityDB.Close()
+	oldASNDB.Close()
+
+	if r.networks != nil {
+		r.networks.purge()
+	}
+	if r.hostnames != nil {
+		r.hostnames.purge()
+	}
+
+	if r.history.Dir != "" {
+		for _, g := range r.generations {
+			g.close()
+		}
+		r.generations = nil
+		return r.loadHistory()
+	}
+	return nil
+}
+
+// Lookup retrieves IP information for the given IP address. The context
+// bounds the reverse DNS lookup when online features are enabled.
+func (r *Reader) Lookup(ctx context.Context, ip net.IP) (*IPInfo, error) {
+	info, hit := r.lookup(ip)
+	statsFrom(ctx).recordLookup(ip, hit)
+	if r.enableOnlineFeatures {
+		r.setHostnames(ctx, info, ip)
+	}
+	return info, nil
+}
+
+// lookup queries the primary databases through the network cache and
+// reports whether the cache answered
+func (r *Reader) lookup(ip net.IP) (*IPInfo, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
+	addr, ok := netindex.AddrFromIP(ip)
+	if !ok || r.networks == nil {
+		info, _ := lookupDatabases(r.cityDB, r.asnDB, ip)
+		r.enrich(info, ip)
+		return info, false
+	}
+
+	info, hit := r.networks.find(addr)
+	if hit {
+		info.IP = ip.String()
+	} else {
+		result, network := lookupDatabases(r.cityDB, r.asnDB, ip)
+		if network.IsValid() && network.Contains(addr) {
+			r.networks.put(network, *result)
+		}
+		info = *result
+	}
+	r.enrich(&info, ip)
+	return &info, hit
+}
+
+// lookupDatabases queries a database pair, returning the result and the
+// network it applies to: the narrower of the matched city and ASN networks.
+func lookupDatabases(cityDB, asnDB *maxminddb.Reader, ip net.IP) (*IPInfo, netip.Prefix) {
 	info := &IPInfo{
 		IP:          ip.String(),
 		Attribution: Attribution,
 	}
+	var network netip.Prefix
 
 	// City/Country lookup
-	city, err := r.cityDB.City(ip)
+	var city geoip2.City
+	cityNet, _, err := cityDB.LookupNetwork(ip, &city)
 	if err == nil {
+		network = narrower(network, cityNet)
+
 		info.Country = city.Country.Names["en"]
 		info.ISOCode = city.Country.IsoCode
 		info.InEU = city.Country.IsInEuropeanUnion
@@ -91,29 +299,77 @@ func (r *Reader) Lookup(ip net.IP) (*IPInfo, error) {
 			lon := city.Location.Longitude
 			info.Latitude = &lat
 			info.Longitude = &lon
+			info.AccuracyRadius = city.Location.AccuracyRadius
 		}
 
 		info.Timezone = city.Location.TimeZone
 	}
 
 	// ASN lookup
-	asn, err := r.asnDB.ASN(ip)
+	var asn geoip2.ASN
+	asnNet, found, err := asnDB.LookupNetwork(ip, &asn)
 	if err == nil {
-		asnNum := asn.AutonomousSystemNumber
-		info.ASN = &asnNum
-		info.Organization = asn.AutonomousSystemOrganization
+		network = narrower(network, asnNet)
+		if found {
+			asnNum := asn.AutonomousSystemNumber
+			info.ASN = &asnNum
+			info.Organization = asn.AutonomousSystemOrganization
+		}
 	}
 
-	// Reverse DNS lookup for hostname (only if online features are enabled)
-	if r.enableOnlineFeatures {
-		names, err := net.LookupAddr(ip.String())
-		if err == nil && len(names) > 0 {
-			// Remove trailing dot from FQDN hostname
-			info.Hostname = strings.TrimSuffix(names[0], ".")
+	return info, network
+}
+
+// narrower returns the longer of p and n. Both contain the looked up
+// address, so the longer prefix is their intersection.
+func narrower(p netip.Prefix, n *net.IPNet) netip.Prefix {
+	if n == nil {
+		return p
+	}
+	addr, ok := netip.AddrFromSlice(n.IP)
+	if !ok {
+		return p
+	}
+	ones, _ := n.Mask.Size()
+	if addr.Is4In6() && ones >= 96 {
+		addr, ones = addr.Unmap(), ones-96
+	}
+	q, err := addr.Prefix(ones)
+	if err != nil {
+		return p
+	}
+	if !p.IsValid() || q.Bits() > p.Bits() {
+		return q
+	}
+	return p
+}
+
+// enrich adds the fields that do not come from the databases: local time,
+// cloud ranges, network type and threat lists. Callers must hold r.mu.
+func (r *Reader) enrich(info *IPInfo, ip net.IP) {
+	info.SetLocalTime(time.Now())
+
+	// Cloud provider ranges
+	if r.cloud != nil {
+		if rng, ok := r.cloud.Lookup(ip); ok {
+			info.CloudProvider = rng.Provider
+			info.CloudRegion = rng.Region
+			info.CloudService = rng.Service
 		}
 	}
 
-	return info, nil
+	// Network type from ASN, prefix and organization
+	var asnNum uint
+	if info.ASN != nil {
+		asnNum = *info.ASN
+	}
+	info.NetworkType = r.classifier.Classify(ip, asnNum, info.Organization)
+	info.IsHosting = netclass.IsHosting(info.NetworkType) || info.CloudProvider != ""
+
+	// Threat lists
+	if r.lists != nil {
+		info.Lists = r.lists.Match(ip)
+	}
 }
 
 // Close closes both database readers
@@ -132,6 +388,12 @@ func (r *Reader) Close() error {
 			errs = append(errs, err)
 		}
 	}
+	for _, g := range r.generations {
+		if err := g.close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	r.generations = nil
 
 	if len(errs) > 0 {
 		return fmt.Errorf("errors closing databases: %v", errs)
@@ -146,16 +408,30 @@ func (r *Reader) OnlineFeaturesEnabled() bool {
 
 // FilterFields returns a new IPInfo with only the requested fields.
 // Uses a switch statement for better performance by avoiding map allocation.
+// Country reference fields (currency, capital, ...) are only available through
+// FilterFields so that the default payload stays small.
 func (info *IPInfo) FilterFields(fields []string) map[string]interface{} {
+	ref, ok := country.Lookup(info.ISOCode)
+	if !ok {
+		ref = &country.Info{}
+	}
+
 	// Pre-allocate with expected capacity: ip + attribution + requested fields
 	result := make(map[string]interface{}, len(fields)+2)
 	result["ip"] = info.IP
 	result["attribution"] = info.Attribution
+	if info.DatabaseBuild != "" {
+		result["database_build"] = info.DatabaseBuild
+	}
 
 	for _, field := range fields {
 		switch field {
 		case "hostname":
 			result["hostname"] = info.Hostname
+		case "hostnames":
+			result["hostnames"] = info.Hostnames
+		case "hostname_verified":
+			result["hostname_verified"] = info.HostnameVerified
 		case "country":
 			result["country"] = info.Country
 		case "iso_code":
@@ -170,12 +446,54 @@ func (info *IPInfo) FilterFields(fields []string) map[string]interface{} {
 			result["latitude"] = info.Latitude
 		case "longitude":
 			result["longitude"] = info.Longitude
+		case "accuracy_radius":
+			result["accuracy_radius"] = info.AccuracyRadius
 		case "timezone":
 			result["timezone"] = info.Timezone
+		case "local_time":
+			result["local_time"] = info.LocalTime
+		case "utc_offset":
+			result["utc_offset"] = info.UTCOffset
+		case "is_dst":
+			result["is_dst"] = info.IsDST
+		case "tz_abbreviation":
+			result["tz_abbreviation"] = info.TZAbbreviation
 		case "asn":
 			result["asn"] = info.ASN
 		case "organization":
 			result["organization"] = info.Organization
+		case "network_type":
+			result["network_type"] = info.NetworkType
+		case "is_hosting":
+			result["is_hosting"] = info.IsHosting
+		case "cloud_provider":
+			result["cloud_provider"] = info.CloudProvider
+		case "cloud_region":
+			result["cloud_region"] = info.CloudRegion
+		case "cloud_service":
+			result["cloud_service"] = info.CloudService
+		case "lists":
+			result["lists"] = info.Lists
+		case "currency":
+			result["currency"] = ref.Currency
+		case "calling_code":
+			result["calling_code"] = ref.CallingCode
+		case "languages":
+			result["languages"] = ref.Languages
+		case "capital":
+			result["capital"] = ref.Capital
+		case "continent":
+			result["continent"] = ref.Continent
+		case "flag_emoji":
+			result["flag_emoji"] = ref.FlagEmoji
+		case "tld":
+			result["tld"] = ref.TLD
+		case "in_eea":
+			result["in_eea"] = ref.InEEA
+		case "in_schengen":
+			result["in_schengen"] = ref.InSchengen
+		case "gdpr_adequate":
+			result["gdpr_adequate"] = ref.GDPRAdequate
 		}
 	}
 
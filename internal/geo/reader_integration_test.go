@@ -3,6 +3,7 @@
 package geo
 
 import (
+	"context"
 	"net"
 	"os"
 	"path/filepath"
@@ -136,7 +137,7 @@ func TestKnownPublicIPs(t *testing.T) {
 				t.Fatalf("Invalid test IP: %s", tt.ip)
 			}
 
-			info, err := reader.Lookup(ip)
+			info, err := reader.Lookup(context.Background(), ip)
 			if err != nil {
 				t.Fatalf("Lookup failed: %v", err)
 			}
@@ -181,12 +182,12 @@ func TestIPv4AndIPv6Parity(t *testing.T) {
 	ipv4 := net.ParseIP("8.8.8.8")
 	ipv6 := net.ParseIP("2001:4860:4860::8888")
 
-	info4, err := reader.Lookup(ipv4)
+	info4, err := reader.Lookup(context.Background(), ipv4)
 	if err != nil {
 		t.Fatalf("IPv4 lookup failed: %v", err)
 	}
 
-	info6, err := reader.Lookup(ipv6)
+	info6, err := reader.Lookup(context.Background(), ipv6)
 	if err != nil {
 		t.Fatalf("IPv6 lookup failed: %v", err)
 	}
@@ -218,7 +219,7 @@ func TestPrivateIPRanges(t *testing.T) {
 	for _, ipStr := range privateIPs {
 		t.Run(ipStr, func(t *testing.T) {
 			ip := net.ParseIP(ipStr)
-			info, err := reader.Lookup(ip)
+			info, err := reader.Lookup(context.Background(), ip)
 			if err != nil {
 				t.Fatalf("Lookup failed for private IP %s: %v", ipStr, err)
 			}
@@ -245,7 +246,7 @@ func TestDatabaseConsistency(t *testing.T) {
 	// Run multiple lookups and verify consistency
 	var firstResult *IPInfo
 	for i := 0; i < 10; i++ {
-		info, err := reader.Lookup(ip)
+		info, err := reader.Lookup(context.Background(), ip)
 		if err != nil {
 			t.Fatalf("Lookup %d failed: %v", i, err)
 		}
@@ -271,7 +272,7 @@ func TestAllFieldsPopulated(t *testing.T) {
 
 	// Use Google DNS as it should have complete data
 	ip := net.ParseIP("8.8.8.8")
-	info, err := reader.Lookup(ip)
+	info, err := reader.Lookup(context.Background(), ip)
 	if err != nil {
 		t.Fatalf("Lookup failed: %v", err)
 	}
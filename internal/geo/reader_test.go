@@ -1,8 +1,10 @@
 package geo
 
 import (
+	"context"
 	"net"
 	"testing"
+	"time"
 )
 
 // MockReader is a mock implementation of ReaderInterface for testing
@@ -10,7 +12,7 @@ type MockReader struct {
 	MockLookup func(ip net.IP) (*IPInfo, error)
 }
 
-func (m *MockReader) Lookup(ip net.IP) (*IPInfo, error) {
+func (m *MockReader) Lookup(ctx context.Context, ip net.IP) (*IPInfo, error) {
 	if m.MockLookup != nil {
 		return m.MockLookup(ip)
 	}
@@ -30,6 +32,23 @@ func (m *MockReader) Lookup(ip net.IP) (*IPInfo, error) {
 	}, nil
 }
 
+func (m *MockReader) LookupAsOf(ctx context.Context, ip net.IP, asOf time.Time) (*IPInfo, error) {
+	info, err := m.Lookup(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+	info.DatabaseBuild = asOf.Format(time.DateOnly)
+	return info, nil
+}
+
+func (m *MockReader) LookupHost(ctx context.Context, host string, opts HostOptions) (*HostInfo, error) {
+	return nil, ErrHostNotFound
+}
+
+func (m *MockReader) Metadata() *Metadata {
+	return &Metadata{}
+}
+
 func (m *MockReader) Close() error {
 	return nil
 }
@@ -87,6 +106,16 @@ func TestIPInfoFilterFields(t *testing.T) {
 			fields:   []string{},
 			expected: []string{"ip", "attribution"},
 		},
+		{
+			name:     "country reference fields",
+			fields:   []string{"currency", "calling_code", "flag_emoji", "in_schengen"},
+			expected: []string{"ip", "attribution", "currency", "calling_code", "flag_emoji", "in_schengen"},
+		},
+		{
+			name:     "network type fields",
+			fields:   []string{"network_type", "is_hosting"},
+			expected: []string{"ip", "attribution", "network_type", "is_hosting"},
+		},
 		{
 			name:     "invalid field ignored",
 			fields:   []string{"invalid", "country"},
@@ -122,6 +151,31 @@ func TestIPInfoFilterFields(t *testing.T) {
 	}
 }
 
+func TestIPInfoFilterFieldsCountryReference(t *testing.T) {
+	info := &IPInfo{IP: "8.8.8.8", ISOCode: "US", Attribution: Attribution}
+
+	result := info.FilterFields([]string{"currency", "calling_code", "continent", "in_eea"})
+	if result["currency"] != "USD" {
+		t.Errorf("expected currency USD, got %v", result["currency"])
+	}
+	if result["calling_code"] != "+1" {
+		t.Errorf("expected calling code +1, got %v", result["calling_code"])
+	}
+	if result["continent"] != "NA" {
+		t.Errorf("expected continent NA, got %v", result["continent"])
+	}
+	if result["in_eea"] != false {
+		t.Errorf("expected in_eea false, got %v", result["in_eea"])
+	}
+
+	// Unknown countries yield empty values rather than missing keys
+	unknown := &IPInfo{IP: "10.0.0.1", Attribution: Attribution}
+	result = unknown.FilterFields([]string{"currency"})
+	if v, ok := result["currency"]; !ok || v != "" {
+		t.Errorf("expected empty currency for unknown country, got %v", v)
+	}
+}
+
 func TestAttribution(t *testing.T) {
 	if Attribution != "IP Geolocation by DB-IP (https://db-ip.com)" {
 		t.Errorf("Attribution constant is incorrect: %s", Attribution)
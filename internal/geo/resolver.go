@@ -0,0 +1,139 @@
+package geo
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+)
+
+// Resolver protocols
+const (
+	ProtocolUDP = "udp"
+	ProtocolTCP = "tcp"
+	ProtocolTLS = "tls" // DNS over TLS (RFC 7858)
+)
+
+// ResolverConfig selects the DNS servers used for online features. With no
+// servers the system configuration applies. Queries rotate across Servers,
+// so a failed attempt is retried on the next one.
+type ResolverConfig struct {
+	Servers  []string // host or host:port; the port defaults to 53, or 853 for DoT
+	Protocol string   // udp (default), tcp or tls
+	PreferGo bool     // use the pure-Go resolver even without explicit servers
+	// TLSConfig is used as a template for DoT connections. ServerName
+	// defaults to the server's host.
+	TLSConfig *tls.Config
+}
+
+// WithResolver sets the resolver used for reverse DNS lookups
+func WithResolver(res *net.Resolver) Option {
+	return func(r *Reader) {
+		r.resolver = res
+	}
+}
+
+// NewResolver builds a resolver from cfg. Explicit servers are only honoured
+// by the pure-Go resolver, which is therefore always used when they are set.
+func NewResolver(cfg ResolverConfig) (*net.Resolver, error) {
+	protocol := strings.ToLower(cfg.Protocol)
+	if protocol == "" {
+		protocol = ProtocolUDP
+	}
+
+	var port string
+	switch protocol {
+	case ProtocolUDP, ProtocolTCP:
+		port = "53"
+	case ProtocolTLS:
+		port = "853"
+	default:
+		return nil, fmt.Errorf("unknown DNS protocol %q (want udp, tcp or tls)", cfg.Protocol)
+	}
+
+	if len(cfg.Servers) == 0 {
+		if protocol != ProtocolUDP {
+			return nil, fmt.Errorf("DNS protocol %s requires explicit servers", protocol)
+		}
+		if !cfg.PreferGo {
+			return net.DefaultResolver, nil
+		}
+		return &net.Resolver{PreferGo: true}, nil
+	}
+
+	servers := make([]string, 0, len(cfg.Servers))
+	for _, s := range cfg.Servers {
+		addr, err := serverAddr(s, port)
+		if err != nil {
+			return nil, err
+		}
+		servers = append(servers, addr)
+	}
+
+	var next atomic.Uint32
+	var dialer net.Dialer
+	dial := func(ctx context.Context, network, _ string) (net.Conn, error) {
+		server := servers[int(next.Add(1)-1)%len(servers)]
+		switch protocol {
+		case ProtocolTCP:
+			return dialer.DialContext(ctx, "tcp", server)
+		case ProtocolTLS:
+			return dialTLS(ctx, &dialer, server, cfg.TLSConfig)
+		default:
+			// The resolver asks for TCP to retry truncated UDP responses
+			return dialer.DialContext(ctx, network, server)
+		}
+	}
+
+	return &net.Resolver{PreferGo: true, Dial: dial}, nil
+}
+
+// serverAddr validates a server address and adds the default port if missing
+func serverAddr(s, port string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", fmt.Errorf("empty DNS server address")
+	}
+	bare := s
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		bare = s[1 : len(s)-1]
+	}
+	if ip := net.ParseIP(bare); ip != nil {
+		return net.JoinHostPort(ip.String(), port), nil
+	}
+	host, p, err := net.SplitHostPort(s)
+	if err != nil {
+		// A bare hostname
+		if strings.ContainsAny(s, ":[]/") {
+			return "", fmt.Errorf("invalid DNS server address %q", s)
+		}
+		return net.JoinHostPort(s, port), nil
+	}
+	if host == "" || p == "" {
+		return "", fmt.Errorf("invalid DNS server address %q", s)
+	}
+	return s, nil
+}
+
+// dialTLS opens a DNS over TLS connection. The Go resolver uses TCP framing
+// for any connection that is not a net.PacketConn.
+func dialTLS(ctx context.Context, dialer *net.Dialer, server string, template *tls.Config) (net.Conn, error) {
+	var cfg *tls.Config
+	if template != nil {
+		cfg = template.Clone()
+	} else {
+		cfg = &tls.Config{}
+	}
+	if cfg.ServerName == "" {
+		host, _, _ := net.SplitHostPort(server)
+		cfg.ServerName = host
+	}
+	if cfg.MinVersion == 0 {
+		cfg.MinVersion = tls.VersionTLS12
+	}
+
+	d := tls.Dialer{NetDialer: dialer, Config: cfg}
+	return d.DialContext(ctx, "tcp", server)
+}
@@ -0,0 +1,420 @@
+package geo
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// testZone maps "name. TYPE" to record values, e.g.
+// "1.2.0.192.in-addr.arpa. PTR", "mail.example.com. A" or
+// "example.com. MX" with values like "10 mail.example.com."
+type testZone map[string][]string
+
+// testDNSServer is an in-process authoritative DNS server answering from a
+// testZone over UDP, TCP and TLS. Like a real server, it listens for UDP
+// and TCP on the same port.
+type testDNSServer struct {
+	zone     testZone
+	udpAddr  string
+	tcpAddr  string
+	tlsAddr  string
+	roots    *x509.CertPool
+	queries  map[string]*atomic.Int32 // by protocol
+	truncate atomic.Bool              // answer UDP queries with truncated, empty responses
+}
+
+func startDNSServer(t *testing.T, zone testZone) *testDNSServer {
+	t.Helper()
+	s := &testDNSServer{
+		zone: zone,
+		queries: map[string]*atomic.Int32{
+			ProtocolUDP: new(atomic.Int32),
+			ProtocolTCP: new(atomic.Int32),
+			ProtocolTLS: new(atomic.Int32),
+		},
+	}
+
+	var pc net.PacketConn
+	var ln net.Listener
+	for attempt := 0; ln == nil; attempt++ {
+		var err error
+		pc, err = net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to listen on UDP: %v", err)
+		}
+		ln, err = net.Listen("tcp", pc.LocalAddr().String())
+		if err != nil {
+			pc.Close()
+			if attempt == 10 {
+				t.Fatalf("failed to listen on TCP: %v", err)
+			}
+		}
+	}
+	t.Cleanup(func() { pc.Close() })
+	t.Cleanup(func() { ln.Close() })
+	s.udpAddr = pc.LocalAddr().String()
+	s.tcpAddr = ln.Addr().String()
+	go s.serveUDP(pc)
+	go s.serveStream(ln, ProtocolTCP)
+
+	cert, roots := testCertificate(t)
+	s.roots = roots
+	tlsLn, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("failed to listen on TLS: %v", err)
+	}
+	t.Cleanup(func() { tlsLn.Close() })
+	s.tlsAddr = tlsLn.Addr().String()
+	go s.serveStream(tlsLn, ProtocolTLS)
+
+	return s
+}
+
+func (s *testDNSServer) serveUDP(pc net.PacketConn) {
+	buf := make([]byte, 4096)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		s.queries[ProtocolUDP].Add(1)
+		if resp := s.answer(buf[:n], s.truncate.Load()); resp != nil {
+			pc.WriteTo(resp, addr)
+		}
+	}
+}
+
+func (s *testDNSServer) serveStream(ln net.Listener, protocol string) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			for {
+				var size uint16
+				if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+					return
+				}
+				query := make([]byte, size)
+				if _, err := io.ReadFull(conn, query); err != nil {
+					return
+				}
+				s.queries[protocol].Add(1)
+				resp := s.answer(query, false)
+				if resp == nil {
+					return
+				}
+				frame := binary.BigEndian.AppendUint16(nil, uint16(len(resp)))
+				if _, err := conn.Write(append(frame, resp...)); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// answer builds the response to a query, or returns nil if it is malformed.
+// A truncated response has the TC bit set and no records.
+func (s *testDNSServer) answer(query []byte, truncated bool) []byte {
+	var p dnsmessage.Parser
+	header, err := p.Start(query)
+	if err != nil {
+		return nil
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil
+	}
+
+	name := strings.ToLower(q.Name.String())
+	typ := strings.TrimPrefix(q.Type.String(), "Type")
+
+	// Follow CNAMEs for other record types, like a recursive resolver
+	type record struct {
+		owner string
+		typ   string
+		value string
+	}
+	var records []record
+	owner := name
+	for range 8 {
+		targets := s.zone[owner+" CNAME"]
+		if q.Type == dnsmessage.TypeCNAME || len(targets) == 0 {
+			break
+		}
+		records = append(records, record{owner: owner, typ: "CNAME", value: targets[0]})
+		owner = strings.ToLower(targets[0])
+	}
+	for _, v := range s.zone[owner+" "+typ] {
+		records = append(records, record{owner: owner, typ: typ, value: v})
+	}
+	if truncated {
+		records = nil
+	}
+
+	rcode := dnsmessage.RCodeSuccess
+	if len(records) == 0 && !s.hasName(owner) {
+		rcode = dnsmessage.RCodeNameError
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+		ID:                 header.ID,
+		Response:           true,
+		Authoritative:      true,
+		RecursionDesired:   header.RecursionDesired,
+		RecursionAvailable: true,
+		Truncated:          truncated,
+		RCode:              rcode,
+	})
+	b.EnableCompression()
+	b.StartQuestions()
+	b.Question(q)
+	b.StartAnswers()
+
+	for _, rec := range records {
+		rh := dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(rec.owner), Class: dnsmessage.ClassINET, TTL: 60}
+		switch rec.typ {
+		case "A":
+			b.AResource(rh, dnsmessage.AResource{A: netip.MustParseAddr(rec.value).As4()})
+		case "AAAA":
+			b.AAAAResource(rh, dnsmessage.AAAAResource{AAAA: netip.MustParseAddr(rec.value).As16()})
+		case "PTR":
+			b.PTRResource(rh, dnsmessage.PTRResource{PTR: dnsmessage.MustNewName(rec.value)})
+		case "CNAME":
+			b.CNAMEResource(rh, dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName(rec.value)})
+		case "MX":
+			pref, host, _ := strings.Cut(rec.value, " ")
+			n, _ := strconv.Atoi(pref)
+			b.MXResource(rh, dnsmessage.MXResource{Pref: uint16(n), MX: dnsmessage.MustNewName(host)})
+		}
+	}
+
+	resp, err := b.Finish()
+	if err != nil {
+		return nil
+	}
+	return resp
+}
+
+// hasName reports whether the zone holds any record for name
+func (s *testDNSServer) hasName(name string) bool {
+	for key := range s.zone {
+		if strings.HasPrefix(key, name+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// testCertificate returns a self-signed certificate for 127.0.0.1 and a pool
+// trusting it
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "dns.test"},
+		DNSNames:              []string{"dns.test"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, roots
+}
+
+var resolverZone = testZone{
+	"1.2.0.192.in-addr.arpa. PTR": {"mail.example.com."},
+	"mail.example.com. A":         {"192.0.2.1"},
+	"2.2.0.192.in-addr.arpa. PTR": {"internal.corp."},
+	"internal.corp. A":            {"10.0.0.2"},
+}
+
+func TestNewResolverProtocols(t *testing.T) {
+	server := startDNSServer(t, resolverZone)
+
+	tests := []struct {
+		protocol string
+		addr     string
+	}{
+		{protocol: ProtocolUDP, addr: server.udpAddr},
+		{protocol: ProtocolTCP, addr: server.tcpAddr},
+		{protocol: ProtocolTLS, addr: server.tlsAddr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.protocol, func(t *testing.T) {
+			res, err := NewResolver(ResolverConfig{
+				Servers:   []string{tt.addr},
+				Protocol:  tt.protocol,
+				TLSConfig: &tls.Config{RootCAs: server.roots},
+			})
+			if err != nil {
+				t.Fatalf("NewResolver failed: %v", err)
+			}
+
+			before := server.queries[tt.protocol].Load()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			names, err := res.LookupAddr(ctx, "192.0.2.1")
+			if err != nil {
+				t.Fatalf("LookupAddr failed: %v", err)
+			}
+			if len(names) != 1 || names[0] != "mail.example.com." {
+				t.Errorf("expected mail.example.com., got %v", names)
+			}
+			if server.queries[tt.protocol].Load() == before {
+				t.Errorf("expected the query to be sent over %s", tt.protocol)
+			}
+
+			if _, err := res.LookupAddr(ctx, "192.0.2.99"); !isNotFound(err) {
+				t.Errorf("expected not found for an unknown address, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewResolverTruncated(t *testing.T) {
+	server := startDNSServer(t, resolverZone)
+	server.truncate.Store(true)
+
+	res, err := NewResolver(ResolverConfig{Servers: []string{server.udpAddr}})
+	if err != nil {
+		t.Fatalf("NewResolver failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	names, err := res.LookupAddr(ctx, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("LookupAddr failed: %v", err)
+	}
+	if len(names) != 1 || names[0] != "mail.example.com." {
+		t.Errorf("expected mail.example.com., got %v", names)
+	}
+	if server.queries[ProtocolUDP].Load() == 0 || server.queries[ProtocolTCP].Load() == 0 {
+		t.Errorf("expected a UDP query retried over TCP, got %d UDP and %d TCP queries",
+			server.queries[ProtocolUDP].Load(), server.queries[ProtocolTCP].Load())
+	}
+}
+
+func TestReaderWithResolver(t *testing.T) {
+	server := startDNSServer(t, resolverZone)
+	res, err := NewResolver(ResolverConfig{Servers: []string{server.udpAddr}})
+	if err != nil {
+		t.Fatalf("NewResolver failed: %v", err)
+	}
+
+	r := &Reader{enableOnlineFeatures: true, rdns: RDNSConfig{Timeout: 5 * time.Second}}
+	WithResolver(res)(r)
+
+	info := &IPInfo{}
+	r.setHostnames(context.Background(), info, net.ParseIP("192.0.2.1"))
+	if info.Hostname != "mail.example.com" || info.HostnameVerified == nil || !*info.HostnameVerified {
+		t.Errorf("expected verified mail.example.com, got %q (verified %v)", info.Hostname, info.HostnameVerified)
+	}
+
+	// A name that resolves elsewhere is reported unverified
+	info = &IPInfo{}
+	r.setHostnames(context.Background(), info, net.ParseIP("192.0.2.2"))
+	if info.Hostname != "internal.corp" || info.HostnameVerified == nil || *info.HostnameVerified {
+		t.Errorf("expected unverified internal.corp, got %q (verified %v)", info.Hostname, info.HostnameVerified)
+	}
+}
+
+func TestNewResolverRotation(t *testing.T) {
+	server := startDNSServer(t, resolverZone)
+
+	// Nothing listens on the first server; queries fail over to the second
+	dead, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadAddr := dead.LocalAddr().String()
+	dead.Close()
+
+	res, err := NewResolver(ResolverConfig{Servers: []string{deadAddr, server.udpAddr}})
+	if err != nil {
+		t.Fatalf("NewResolver failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := res.LookupAddr(ctx, "192.0.2.1"); err != nil {
+		t.Errorf("expected failover to the working server, got %v", err)
+	}
+}
+
+func TestNewResolverConfig(t *testing.T) {
+	if res, err := NewResolver(ResolverConfig{}); err != nil || res != net.DefaultResolver {
+		t.Errorf("expected the default resolver without configuration, got %v, %v", res, err)
+	}
+	if res, err := NewResolver(ResolverConfig{PreferGo: true}); err != nil || !res.PreferGo {
+		t.Errorf("expected a pure-Go resolver, got %+v, %v", res, err)
+	}
+
+	invalid := []ResolverConfig{
+		{Servers: []string{"127.0.0.1"}, Protocol: "https"},
+		{Protocol: ProtocolTLS},
+		{Servers: []string{"[::1"}},
+		{Servers: []string{""}},
+	}
+	for _, cfg := range invalid {
+		if _, err := NewResolver(cfg); err == nil {
+			t.Errorf("expected NewResolver(%+v) to fail", cfg)
+		}
+	}
+
+	tests := []struct {
+		server   string
+		port     string
+		expected string
+	}{
+		{server: "9.9.9.9", port: "53", expected: "9.9.9.9:53"},
+		{server: "2620:fe::fe", port: "853", expected: "[2620:fe::fe]:853"},
+		{server: "[2620:fe::fe]", port: "53", expected: "[2620:fe::fe]:53"},
+		{server: "[2620:fe::fe]:5353", port: "53", expected: "[2620:fe::fe]:5353"},
+		{server: "dns.quad9.net", port: "853", expected: "dns.quad9.net:853"},
+		{server: "127.0.0.1:5353", port: "53", expected: "127.0.0.1:5353"},
+	}
+	for _, tt := range tests {
+		got, err := serverAddr(tt.server, tt.port)
+		if err != nil || got != tt.expected {
+			t.Errorf("serverAddr(%q): expected %s, got %s (%v)", tt.server, tt.expected, got, err)
+		}
+	}
+}
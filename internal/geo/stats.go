@@ -0,0 +1,85 @@
+package geo
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// LookupStats records the lookups made with a context, for request logging.
+// It is safe for concurrent use.
+type LookupStats struct {
+	mu        sync.Mutex
+	ip        string
+	lookups   int
+	cacheHits int
+	rdns      time.Duration
+}
+
+type statsKey struct{}
+
+// WithLookupStats returns a context whose lookups are recorded in the
+// returned LookupStats
+func WithLookupStats(ctx context.Context) (context.Context, *LookupStats) {
+	s := &LookupStats{}
+	return context.WithValue(ctx, statsKey{}, s), s
+}
+
+// statsFrom returns the LookupStats attached to ctx, or nil
+func statsFrom(ctx context.Context) *LookupStats {
+	s, _ := ctx.Value(statsKey{}).(*LookupStats)
+	return s
+}
+
+// IP returns the first address looked up
+func (s *LookupStats) IP() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ip
+}
+
+// Lookups returns the number of database lookups
+func (s *LookupStats) Lookups() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lookups
+}
+
+// CacheHits returns the number of lookups answered by the network cache
+func (s *LookupStats) CacheHits() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cacheHits
+}
+
+// RDNS returns the total time spent on reverse DNS
+func (s *LookupStats) RDNS() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rdns
+}
+
+func (s *LookupStats) recordLookup(ip net.IP, cacheHit bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ip == "" {
+		s.ip = ip.String()
+	}
+	s.lookups++
+	if cacheHit {
+		s.cacheHits++
+	}
+}
+
+func (s *LookupStats) recordRDNS(d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rdns += d
+}
@@ -0,0 +1,39 @@
+package geo
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLookupStats(t *testing.T) {
+	ctx, stats := WithLookupStats(context.Background())
+
+	statsFrom(ctx).recordLookup(net.ParseIP("192.0.2.1"), false)
+	statsFrom(ctx).recordLookup(net.ParseIP("192.0.2.2"), true)
+	statsFrom(ctx).recordLookup(net.ParseIP("192.0.2.1"), true)
+
+	if stats.IP() != "192.0.2.1" || stats.Lookups() != 3 || stats.CacheHits() != 2 {
+		t.Errorf("stats = ip %s, lookups %d, cache hits %d; want 192.0.2.1, 3, 2", stats.IP(), stats.Lookups(), stats.CacheHits())
+	}
+
+	// Contexts without stats are ignored
+	statsFrom(context.Background()).recordLookup(net.ParseIP("192.0.2.1"), false)
+	statsFrom(context.Background()).recordRDNS(time.Second)
+}
+
+func TestLookupStatsRDNS(t *testing.T) {
+	res := &fakeResolver{
+		ptr:   map[string][]string{"192.0.2.1": {"host.example."}},
+		delay: 20 * time.Millisecond,
+	}
+	r := newRDNSReader(res, RDNSConfig{Timeout: time.Second})
+
+	ctx, stats := WithLookupStats(context.Background())
+	r.setHostnames(ctx, &IPInfo{}, net.ParseIP("192.0.2.1"))
+
+	if d := stats.RDNS(); d < res.delay {
+		t.Errorf("RDNS() = %v, want at least %v", d, res.delay)
+	}
+}
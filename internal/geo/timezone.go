@@ -0,0 +1,62 @@
+package geo
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// locations caches loaded time zones by name; failed loads are cached as nil
+var locations sync.Map
+
+// loadLocation is time.LoadLocation with a process-wide cache, so lookups do
+// not read the zoneinfo database on every request
+func loadLocation(name string) (*time.Location, bool) {
+	if v, ok := locations.Load(name); ok {
+		loc := v.(*time.Location)
+		return loc, loc != nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loc = nil
+	}
+	locations.Store(name, loc)
+	return loc, loc != nil
+}
+
+// SetLocalTime fills in the local time, UTC offset, DST flag and timezone
+// abbreviation for the given instant. It is a no-op if the timezone is
+// unknown or cannot be loaded.
+func (info *IPInfo) SetLocalTime(at time.Time) {
+	info.LocalTime = ""
+	info.UTCOffset = ""
+	info.IsDST = nil
+	info.TZAbbreviation = ""
+
+	if info.Timezone == "" {
+		return
+	}
+	loc, ok := loadLocation(info.Timezone)
+	if !ok {
+		return
+	}
+
+	local := at.In(loc)
+	abbr, offset := local.Zone()
+	isDST := local.IsDST()
+
+	info.LocalTime = local.Format(time.RFC3339)
+	info.UTCOffset = formatUTCOffset(offset)
+	info.IsDST = &isDST
+	info.TZAbbreviation = abbr
+}
+
+// formatUTCOffset formats an offset in seconds as ±hh:mm
+func formatUTCOffset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign = '-'
+		seconds = -seconds
+	}
+	return fmt.Sprintf("%c%02d:%02d", sign, seconds/3600, seconds%3600/60)
+}
@@ -0,0 +1,97 @@
+package geo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetLocalTime(t *testing.T) {
+	tests := []struct {
+		name      string
+		timezone  string
+		at        time.Time
+		localTime string
+		offset    string
+		isDST     bool
+		abbr      string
+	}{
+		{
+			name:      "standard time",
+			timezone:  "America/Los_Angeles",
+			at:        time.Date(2024, 1, 15, 11, 0, 0, 0, time.UTC),
+			localTime: "2024-01-15T03:00:00-08:00",
+			offset:    "-08:00",
+			isDST:     false,
+			abbr:      "PST",
+		},
+		{
+			name:      "daylight saving time",
+			timezone:  "America/Los_Angeles",
+			at:        time.Date(2024, 7, 15, 10, 0, 0, 0, time.UTC),
+			localTime: "2024-07-15T03:00:00-07:00",
+			offset:    "-07:00",
+			isDST:     true,
+			abbr:      "PDT",
+		},
+		{
+			name:      "fractional offset",
+			timezone:  "Asia/Kolkata",
+			at:        time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+			localTime: "2024-01-15T05:30:00+05:30",
+			offset:    "+05:30",
+			isDST:     false,
+			abbr:      "IST",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &IPInfo{Timezone: tt.timezone}
+			info.SetLocalTime(tt.at)
+
+			if info.LocalTime != tt.localTime {
+				t.Errorf("expected local time %s, got %s", tt.localTime, info.LocalTime)
+			}
+			if info.UTCOffset != tt.offset {
+				t.Errorf("expected UTC offset %s, got %s", tt.offset, info.UTCOffset)
+			}
+			if info.IsDST == nil || *info.IsDST != tt.isDST {
+				t.Errorf("expected is_dst %v, got %v", tt.isDST, info.IsDST)
+			}
+			if info.TZAbbreviation != tt.abbr {
+				t.Errorf("expected abbreviation %s, got %s", tt.abbr, info.TZAbbreviation)
+			}
+		})
+	}
+}
+
+func TestSetLocalTimeUnknownTimezone(t *testing.T) {
+	for _, tz := range []string{"", "Mars/Olympus_Mons"} {
+		info := &IPInfo{Timezone: tz}
+		info.SetLocalTime(time.Now())
+
+		if info.LocalTime != "" || info.UTCOffset != "" || info.IsDST != nil || info.TZAbbreviation != "" {
+			t.Errorf("expected no local time fields for timezone %q, got %+v", tz, info)
+		}
+	}
+}
+
+func TestLoadLocationCached(t *testing.T) {
+	first, ok := loadLocation("Europe/Berlin")
+	if !ok {
+		t.Fatal("expected Europe/Berlin to load")
+	}
+	second, _ := loadLocation("Europe/Berlin")
+	if first != second {
+		t.Error("expected the cached location to be reused")
+	}
+
+	for range 2 {
+		if loc, ok := loadLocation("Mars/Olympus_Mons"); ok || loc != nil {
+			t.Errorf("expected unknown timezone to fail, got %v", loc)
+		}
+	}
+	if _, ok := locations.Load("Mars/Olympus_Mons"); !ok {
+		t.Error("expected failed load to be cached")
+	}
+}
@@ -0,0 +1,232 @@
+// Package lists matches addresses against local threat-intelligence lists
+// such as Tor exit node lists, Spamhaus DROP/EDROP and FireHOL netsets.
+package lists
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jcjc-dev/ipwhere/internal/netindex"
+)
+
+// DefaultCategory is used for lists configured without a category
+const DefaultCategory = "blocklist"
+
+// Spec configures a named list file
+type Spec struct {
+	Name     string
+	Category string
+	Path     string
+}
+
+// Match identifies a list containing an address
+type Match struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+}
+
+// Info reports the state of a loaded list
+type Info struct {
+	Name       string    `json:"name"`
+	Category   string    `json:"category"`
+	File       string    `json:"file"`
+	Entries    int       `json:"entries"`
+	Modified   time.Time `json:"modified"`
+	LoadedAt   time.Time `json:"loaded_at"`
+	AgeSeconds int64     `json:"age_seconds"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// ParseSpec parses a "name[:category]=path" specification
+func ParseSpec(s string) (Spec, error) {
+	key, path, ok := strings.Cut(s, "=")
+	path = strings.TrimSpace(path)
+	if !ok || path == "" {
+		return Spec{}, fmt.Errorf("invalid list %q, expected name[:category]=path", s)
+	}
+	name, category, _ := strings.Cut(key, ":")
+	name = strings.TrimSpace(name)
+	category = strings.TrimSpace(category)
+	if name == "" {
+		return Spec{}, fmt.Errorf("invalid list %q: missing name", s)
+	}
+	if category == "" {
+		category = DefaultCategory
+	}
+	return Spec{Name: name, Category: category, Path: path}, nil
+}
+
+// Set is a reloadable collection of lists sharing one prefix index
+type Set struct {
+	specs []Spec
+
+	mu      sync.RWMutex
+	index   *netindex.Index[int]
+	info    []Info
+	modTime map[string]time.Time
+}
+
+// Load reads and indexes the given lists. List names must be unique.
+func Load(specs []Spec) (*Set, error) {
+	seen := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		if seen[spec.Name] {
+			return nil, fmt.Errorf("duplicate list name %q", spec.Name)
+		}
+		seen[spec.Name] = true
+	}
+
+	s := &Set{specs: specs}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload re-reads the lists if any of their files changed on disk.
+// On failure the previously loaded lists stay in use.
+func (s *Set) Reload() error {
+	changed, err := s.changed()
+	if err != nil || !changed {
+		return err
+	}
+	return s.load()
+}
+
+// changed reports whether any list file was modified since it was loaded
+func (s *Set) changed() (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, spec := range s.specs {
+		st, err := os.Stat(spec.Path)
+		if err != nil {
+			return false, fmt.Errorf("failed to stat list %s: %w", spec.Name, err)
+		}
+		if !st.ModTime().Equal(s.modTime[spec.Path]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// load parses all lists into a new index and swaps it in
+func (s *Set) load() error {
+	index := netindex.New[int]()
+	info := make([]Info, 0, len(s.specs))
+	modTime := make(map[string]time.Time, len(s.specs))
+	now := time.Now()
+
+	var errs []error
+	for i, spec := range s.specs {
+		prefixes, mod, err := readList(spec)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, p := range prefixes {
+			index.Insert(p, i)
+		}
+		info = append(info, Info{
+			Name:     spec.Name,
+			Category: spec.Category,
+			File:     filepath.Base(spec.Path),
+			Entries:  len(prefixes),
+			Modified: mod,
+			LoadedAt: now,
+		})
+		modTime[spec.Path] = mod
+	}
+	if len(errs) > 0 {
+		s.recordError(errors.Join(errs...))
+		return errors.Join(errs...)
+	}
+
+	s.mu.Lock()
+	s.index = index
+	s.info = info
+	s.modTime = modTime
+	s.mu.Unlock()
+	return nil
+}
+
+// recordError attaches a reload error to the currently loaded lists
+func (s *Set) recordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.info {
+		s.info[i].Error = err.Error()
+	}
+}
+
+// readList parses a single list file
+func readList(spec Spec) ([]netip.Prefix, time.Time, error) {
+	f, err := os.Open(spec.Path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read list %s: %w", spec.Name, err)
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read list %s: %w", spec.Name, err)
+	}
+	prefixes, err := parse(f)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to parse list %s from %s: %w", spec.Name, spec.Path, err)
+	}
+	return prefixes, st.ModTime(), nil
+}
+
+// Match returns the lists containing ip, in configuration order
+func (s *Set) Match(ip net.IP) []Match {
+	addr, ok := netindex.AddrFromIP(ip)
+	if !ok {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.index == nil {
+		return nil
+	}
+	hits := s.index.Covering(addr)
+	if len(hits) == 0 {
+		return nil
+	}
+
+	matched := make([]bool, len(s.specs))
+	for _, i := range hits {
+		matched[i] = true
+	}
+	var result []Match
+	for i, m := range matched {
+		if m {
+			result = append(result, Match{Name: s.specs[i].Name, Category: s.specs[i].Category})
+		}
+	}
+	return result
+}
+
+// Lists returns the state of each loaded list
+func (s *Set) Lists() []Info {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	result := make([]Info, len(s.info))
+	for i, li := range s.info {
+		li.AgeSeconds = int64(now.Sub(li.Modified).Seconds())
+		result[i] = li
+	}
+	return result
+}
@@ -0,0 +1,182 @@
+package lists
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	torExits = `ExitNode 0011BD2485AD45D984EC4159C88FC066E5E3300E
+Published 2024-01-01 10:00:00
+LastStatus 2024-01-01 11:00:00
+ExitAddress 198.51.100.7 2024-01-01 11:12:13
+ExitNode 0040D2E9D8AE8A6A2D1C3E7C4A9DCB1B0F1A2B3C
+Published 2024-01-01 10:30:00
+LastStatus 2024-01-01 11:30:00
+ExitAddress 2001:db8::7 2024-01-01 11:45:00
+`
+	dropText = `; Spamhaus DROP List 2024/01/01 - (c) 2024 The Spamhaus Project
+; Last-Modified: Mon, 01 Jan 2024 00:00:00 GMT
+192.0.2.0/24 ; SBL123456
+203.0.113.0/25 ; SBL654321
+`
+	dropJSON = `{"cidr":"203.0.113.0/24","sblid":"SBL111111","rir":"apnic"}
+{"type":"metadata","timestamp":1704067200,"size":1,"records":1,"copyright":"(c) 2024 The Spamhaus Project SLU"}
+`
+	netset = `#
+# firehol_level1
+#
+198.51.100.0/24
+192.0.2.15	# single address
+`
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadAndMatch(t *testing.T) {
+	dir := t.TempDir()
+	set, err := Load([]Spec{
+		{Name: "tor", Category: "anonymizer", Path: writeFile(t, dir, "tor-exits.txt", torExits)},
+		{Name: "spamhaus-drop", Category: "spam", Path: writeFile(t, dir, "drop.txt", dropText)},
+		{Name: "spamhaus-drop-v4", Category: "spam", Path: writeFile(t, dir, "drop_v4.json", dropJSON)},
+		{Name: "firehol-level1", Category: DefaultCategory, Path: writeFile(t, dir, "firehol_level1.netset", netset)},
+	})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	tests := []struct {
+		ip       string
+		expected []string
+	}{
+		{ip: "198.51.100.7", expected: []string{"tor", "firehol-level1"}},
+		{ip: "2001:db8::7", expected: []string{"tor"}},
+		{ip: "192.0.2.15", expected: []string{"spamhaus-drop", "firehol-level1"}},
+		{ip: "192.0.2.16", expected: []string{"spamhaus-drop"}},
+		{ip: "203.0.113.1", expected: []string{"spamhaus-drop", "spamhaus-drop-v4"}},
+		{ip: "203.0.113.200", expected: []string{"spamhaus-drop-v4"}},
+		{ip: "::ffff:198.51.100.9", expected: []string{"firehol-level1"}},
+		{ip: "8.8.8.8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			var got []string
+			for _, m := range set.Match(net.ParseIP(tt.ip)) {
+				got = append(got, m.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	matches := set.Match(net.ParseIP("198.51.100.7"))
+	if matches[0].Category != "anonymizer" {
+		t.Errorf("expected category anonymizer, got %q", matches[0].Category)
+	}
+
+	info := set.Lists()
+	if len(info) != 4 {
+		t.Fatalf("expected 4 lists, got %d", len(info))
+	}
+	if info[0].Name != "tor" || info[0].Entries != 2 || info[0].File != "tor-exits.txt" {
+		t.Errorf("unexpected tor list info: %+v", info[0])
+	}
+	if info[1].Entries != 2 || info[2].Entries != 1 || info[3].Entries != 2 {
+		t.Errorf("unexpected entry counts: %+v", info)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "list.txt", "192.0.2.0/24\n")
+
+	if _, err := Load([]Spec{{Name: "a", Path: path}, {Name: "a", Path: path}}); err == nil {
+		t.Error("expected duplicate list names to fail")
+	}
+	if _, err := Load([]Spec{{Name: "missing", Path: filepath.Join(dir, "missing.txt")}}); err == nil {
+		t.Error("expected a missing file to fail")
+	}
+	bad := writeFile(t, dir, "bad.txt", "192.0.2.0/24\nnot-an-address\n")
+	_, err := Load([]Spec{{Name: "bad", Path: bad}})
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected a line-numbered parse error, got %v", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "blocklist.txt", "192.0.2.0/24\n")
+	set, err := Load([]Spec{{Name: "custom", Category: DefaultCategory, Path: path}})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	writeFile(t, dir, "blocklist.txt", "198.51.100.0/24\n")
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if len(set.Match(net.ParseIP("198.51.100.1"))) != 1 {
+		t.Error("expected reloaded entry to match")
+	}
+	if len(set.Match(net.ParseIP("192.0.2.1"))) != 0 {
+		t.Error("expected removed entry to no longer match")
+	}
+
+	// A broken file keeps the previous entries in place
+	writeFile(t, dir, "blocklist.txt", "garbage\n")
+	later := future.Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Reload(); err == nil {
+		t.Error("expected reload of an invalid file to fail")
+	}
+	if len(set.Match(net.ParseIP("198.51.100.1"))) != 1 {
+		t.Error("expected previous entries to remain after a failed reload")
+	}
+	if set.Lists()[0].Error == "" {
+		t.Error("expected the reload error to be reported")
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected Spec
+	}{
+		{spec: "tor:anonymizer=/data/tor-exits.txt", expected: Spec{Name: "tor", Category: "anonymizer", Path: "/data/tor-exits.txt"}},
+		{spec: "internal=/data/blocked.txt", expected: Spec{Name: "internal", Category: DefaultCategory, Path: "/data/blocked.txt"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseSpec(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseSpec(%q) failed: %v", tt.spec, err)
+		}
+		if got != tt.expected {
+			t.Errorf("ParseSpec(%q): expected %+v, got %+v", tt.spec, tt.expected, got)
+		}
+	}
+
+	for _, spec := range []string{"tor", "tor=", ":spam=/data/list.txt"} {
+		if _, err := ParseSpec(spec); err == nil {
+			t.Errorf("expected ParseSpec(%q) to fail", spec)
+		}
+	}
+}
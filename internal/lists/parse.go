@@ -0,0 +1,90 @@
+package lists
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+
+	"github.com/jcjc-dev/ipwhere/internal/netindex"
+)
+
+// maxLineBytes bounds the length of a single list line
+const maxLineBytes = 64 * 1024
+
+// parse reads the addresses and prefixes of a list file. The following
+// line formats are understood and may be mixed:
+//
+//   - plain IP addresses or CIDR prefixes (FireHOL netsets, custom lists)
+//   - Spamhaus DROP/EDROP text: "192.0.2.0/24 ; SBL123456"
+//   - Spamhaus DROP JSON lines: {"cidr":"192.0.2.0/24","sblid":"SBL123456"}
+//   - Tor exit lists: "ExitAddress 192.0.2.1 2024-01-01 00:00:00"
+//
+// Blank lines, lines starting with '#' or ';' and trailing comments are ignored.
+func parse(r io.Reader) ([]netip.Prefix, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineBytes)
+
+	var prefixes []netip.Prefix
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		entry, err := parseLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if entry == "" {
+			continue
+		}
+		p, err := netindex.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid address or prefix %q", lineNo, entry)
+		}
+		prefixes = append(prefixes, p)
+	}
+	return prefixes, scanner.Err()
+}
+
+// parseLine extracts the address or prefix from a single line, returning an
+// empty string for lines without one
+func parseLine(line string) (string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' || line[0] == ';' {
+		return "", nil
+	}
+
+	if line[0] == '{' {
+		var rec struct {
+			CIDR string `json:"cidr"`
+		}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return "", fmt.Errorf("invalid JSON: %w", err)
+		}
+		// Metadata records carry no prefix
+		return rec.CIDR, nil
+	}
+
+	if i := strings.IndexAny(line, "#;"); i >= 0 {
+		line = strings.TrimSpace(line[:i])
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", nil
+	}
+
+	// Tor exit-addresses format: only ExitAddress lines carry addresses
+	switch fields[0] {
+	case "ExitAddress":
+		if len(fields) < 2 {
+			return "", fmt.Errorf("missing address")
+		}
+		return fields[1], nil
+	case "ExitNode", "Published", "LastStatus":
+		return "", nil
+	}
+
+	return fields[0], nil
+}
@@ -0,0 +1,279 @@
+// Package mailtrace reconstructs the relay path of an email message from its
+// Received headers.
+//
+// Each mail server prepends a Received header, so the topmost header is the
+// last hop. Parse returns the hops in delivery order, oldest first, with the
+// relay IP taken from the "from" clause, e.g.
+//
+//	Received: from mail.example.com (mail.example.com [81.2.69.142])
+//	        by mx.example.net with ESMTPS id abc123; Tue, 2 Jan 2024 10:00:05 +0000
+//
+// Only the recipient's own servers are trustworthy: hops added before the
+// message reached them may be forged by the sender.
+package mailtrace
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/mail"
+	"net/netip"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+	"github.com/jcjc-dev/ipwhere/internal/netclass"
+)
+
+// maxHeaderBytes limits the size of the parsed header block
+const maxHeaderBytes = 1 << 20
+
+// ErrNoReceivedHeaders is returned when the input has no Received headers
+var ErrNoReceivedHeaders = errors.New("no Received headers found")
+
+// Hop is one relay of a message. IP is the address the receiving server (By)
+// saw the connection from; From is the name the sender claimed in its
+// greeting. Private is set when IP is private or reserved, in which case
+// it is not geolocated. DelaySeconds is the time since the previous hop with
+// a timestamp and may be negative when server clocks disagree.
+type Hop struct {
+	Index        int         `json:"index"`
+	From         string      `json:"from,omitempty"`
+	By           string      `json:"by,omitempty"`
+	With         string      `json:"with,omitempty"`
+	IP           string      `json:"ip,omitempty"`
+	Private      bool        `json:"private,omitempty"`
+	Timestamp    *time.Time  `json:"timestamp,omitempty"`
+	DelaySeconds *float64    `json:"delay_seconds,omitempty"`
+	Info         *geo.IPInfo `json:"info,omitempty"`
+	Received     string      `json:"received"`
+}
+
+// Trace is the relay path of a message. OriginIP is the likely originating
+// address: the public IP of an X-Originating-IP header if present, otherwise
+// that of the earliest hop with one.
+type Trace struct {
+	Hops              []Hop       `json:"hops"`
+	OriginIP          string      `json:"origin_ip,omitempty"`
+	Origin            *geo.IPInfo `json:"origin,omitempty"`
+	TotalDelaySeconds *float64    `json:"total_delay_seconds,omitempty"`
+	Attribution       string      `json:"attribution"`
+}
+
+var (
+	fromClause = regexp.MustCompile(`(?is)^\s*from\s+(.*?)(?:\s+by\s+|;|$)`)
+	byClause   = regexp.MustCompile(`(?i)\bby\s+([^\s;()]+)`)
+	withClause = regexp.MustCompile(`(?i)\bwith\s+([^\s;()]+)`)
+	// Addresses are usually bracketed, e.g. [192.0.2.1] or [IPv6:2001:db8::1]
+	bracketedIP = regexp.MustCompile(`\[(?:IPv6:)?([0-9A-Fa-f:.]+)\]`)
+	bareIP      = regexp.MustCompile(`[0-9A-Fa-f]*[:.][0-9A-Fa-f:.]*[0-9A-Fa-f]`)
+)
+
+// Parse extracts the hops from a raw header block, oldest first. Parsing
+// stops at the first empty line after a header, so a full message may be
+// passed. Hops are not geolocated.
+func Parse(r io.Reader) (*Trace, error) {
+	headers, err := readHeaders(io.LimitReader(r, maxHeaderBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	var received []string
+	var originating string
+	for _, h := range headers {
+		switch strings.ToLower(h.name) {
+		case "received":
+			received = append(received, h.value)
+		case "x-originating-ip":
+			if originating == "" {
+				originating = h.value
+			}
+		}
+	}
+	if len(received) == 0 {
+		return nil, ErrNoReceivedHeaders
+	}
+
+	trace := &Trace{Hops: make([]Hop, 0, len(received)), Attribution: geo.Attribution}
+	var first, prev *time.Time
+	for i := len(received) - 1; i >= 0; i-- {
+		hop := parseReceived(received[i])
+		hop.Index = len(trace.Hops) + 1
+		if hop.Timestamp != nil {
+			if prev != nil {
+				delay := hop.Timestamp.Sub(*prev).Seconds()
+				hop.DelaySeconds = &delay
+			}
+			if first == nil {
+				first = hop.Timestamp
+			}
+			prev = hop.Timestamp
+		}
+		trace.Hops = append(trace.Hops, hop)
+	}
+	if first != nil && prev != first {
+		total := prev.Sub(*first).Seconds()
+		trace.TotalDelaySeconds = &total
+	}
+
+	if addr, ok := findIP(originating); ok && netclass.IsPublic(addr) {
+		trace.OriginIP = addr.String()
+	} else {
+		for _, hop := range trace.Hops {
+			if hop.IP != "" && !hop.Private {
+				trace.OriginIP = hop.IP
+				break
+			}
+		}
+	}
+	return trace, nil
+}
+
+// Geolocate looks up the public hop addresses and the origin. Each distinct
+// address is looked up once.
+func (t *Trace) Geolocate(ctx context.Context, reader geo.ReaderInterface) error {
+	infos := make(map[string]*geo.IPInfo)
+	lookup := func(ip string) (*geo.IPInfo, error) {
+		if info, ok := infos[ip]; ok {
+			return info, nil
+		}
+		addr := netip.MustParseAddr(ip)
+		info, err := reader.Lookup(ctx, addr.AsSlice())
+		if err != nil {
+			return nil, err
+		}
+		infos[ip] = info
+		return info, nil
+	}
+
+	for i := range t.Hops {
+		hop := &t.Hops[i]
+		if hop.IP == "" || hop.Private {
+			continue
+		}
+		info, err := lookup(hop.IP)
+		if err != nil {
+			return err
+		}
+		hop.Info = info
+	}
+	if t.OriginIP != "" {
+		info, err := lookup(t.OriginIP)
+		if err != nil {
+			return err
+		}
+		t.Origin = info
+	}
+	return nil
+}
+
+// parseReceived parses a single Received header value
+func parseReceived(value string) Hop {
+	hop := Hop{Received: value}
+
+	clauses := value
+	if i := strings.LastIndex(value, ";"); i >= 0 {
+		clauses = value[:i]
+		if ts, ok := parseDate(value[i+1:]); ok {
+			hop.Timestamp = &ts
+		}
+	}
+
+	if m := byClause.FindStringSubmatch(clauses); m != nil {
+		hop.By = m[1]
+	}
+	if m := withClause.FindStringSubmatch(clauses); m != nil {
+		hop.With = m[1]
+	}
+	if m := fromClause.FindStringSubmatch(clauses); m != nil {
+		// The first word is the name the sender claimed (HELO); the
+		// receiving server records the address it saw in the comment after it
+		claimed, info := strings.TrimSpace(m[1]), ""
+		if !strings.HasPrefix(claimed, "(") {
+			if i := strings.IndexAny(claimed, " \t"); i >= 0 {
+				claimed, info = claimed[:i], claimed[i:]
+			}
+			hop.From = strings.Trim(claimed, "[]")
+		} else {
+			claimed, info = "", claimed
+		}
+
+		addr, ok := findIP(info)
+		if !ok {
+			addr, ok = findIP(claimed)
+		}
+		if ok {
+			hop.IP = addr.String()
+			hop.Private = !netclass.IsPublic(addr)
+		}
+	}
+	return hop
+}
+
+// findIP returns the first IP address in s, preferring bracketed literals
+func findIP(s string) (netip.Addr, bool) {
+	for _, m := range bracketedIP.FindAllStringSubmatch(s, -1) {
+		if addr, err := netip.ParseAddr(m[1]); err == nil {
+			return addr.Unmap(), true
+		}
+	}
+	for _, m := range bareIP.FindAllString(s, -1) {
+		if addr, err := netip.ParseAddr(m); err == nil {
+			return addr.Unmap(), true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// parseDate parses an RFC 5322 date, tolerating trailing comments such as
+// "(UTC)" and the extra whitespace some servers emit
+func parseDate(s string) (time.Time, bool) {
+	s = strings.Join(strings.Fields(s), " ")
+	if i := strings.Index(s, "("); i > 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	t, err := mail.ParseDate(s)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.UTC(), true
+}
+
+type header struct {
+	name  string
+	value string
+}
+
+// readHeaders reads an RFC 5322 header block, unfolding continuation lines.
+// Leading blank lines and lines that are not headers are skipped, as pasted
+// headers are often untidy.
+func readHeaders(r io.Reader) ([]header, error) {
+	var headers []header
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), maxHeaderBytes)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		switch {
+		case line == "" || strings.TrimSpace(line) == "":
+			if len(headers) > 0 {
+				return headers, nil
+			}
+		case line[0] == ' ' || line[0] == '\t':
+			if len(headers) > 0 {
+				headers[len(headers)-1].value += " " + strings.TrimSpace(line)
+			}
+		default:
+			name, value, ok := strings.Cut(line, ":")
+			if !ok || name == "" || strings.ContainsAny(name, " \t") {
+				continue
+			}
+			headers = append(headers, header{name: name, value: strings.TrimSpace(value)})
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
@@ -0,0 +1,160 @@
+package mailtrace
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+	"github.com/jcjc-dev/ipwhere/internal/geo/geotest"
+)
+
+const sampleHeaders = `
+Delivered-To: abuse@example.net
+Received: by 2002:a05:6402:1234:b0:56c:1234:5678 with SMTP id abc;
+        Tue, 2 Jan 2024 10:00:09 -0800 (PST)
+Received: from mx.example.net (mx.example.net [8.8.4.4])
+        by mail-relay.example.net with ESMTPS id xyz
+        for <abuse@example.net>; Tue, 02 Jan 2024 18:00:07 +0000
+Received: from sender.example.org ([81.2.69.142] helo=sender.example.org)
+	by mx.example.net with esmtp (Exim 4.96)
+	id 1rKabc-000123-AB; Tue, 02 Jan 2024 18:00:05 +0000 (UTC)
+Received: from [192.168.1.20] (unknown [10.0.0.5])
+	by sender.example.org (Postfix) with ESMTPSA id 4T0abc;
+	Tue,  2 Jan 2024 18:00:00 +0000 (UTC)
+Subject: Invoice
+From: "Billing" <billing@example.org>
+
+Body text with Received: from nowhere [1.1.1.1]
+`
+
+func TestParse(t *testing.T) {
+	trace, err := Parse(strings.NewReader(sampleHeaders))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	expected := []struct {
+		from    string
+		by      string
+		with    string
+		ip      string
+		private bool
+		delay   float64
+	}{
+		{from: "192.168.1.20", by: "sender.example.org", with: "ESMTPSA", ip: "10.0.0.5", private: true},
+		{from: "sender.example.org", by: "mx.example.net", with: "esmtp", ip: "81.2.69.142", delay: 5},
+		{from: "mx.example.net", by: "mail-relay.example.net", with: "ESMTPS", ip: "8.8.4.4", delay: 2},
+		{by: "2002:a05:6402:1234:b0:56c:1234:5678", with: "SMTP", delay: 2},
+	}
+	if len(trace.Hops) != len(expected) {
+		t.Fatalf("expected %d hops, got %d", len(expected), len(trace.Hops))
+	}
+	for i, want := range expected {
+		hop := trace.Hops[i]
+		if hop.Index != i+1 || hop.From != want.from || hop.By != want.by || hop.With != want.with || hop.IP != want.ip || hop.Private != want.private {
+			t.Errorf("hop %d: expected %+v, got %+v", i+1, want, hop)
+		}
+		if hop.Timestamp == nil {
+			t.Errorf("hop %d: expected a timestamp", i+1)
+			continue
+		}
+		if i == 0 {
+			if hop.DelaySeconds != nil {
+				t.Errorf("hop 1: expected no delay, got %v", *hop.DelaySeconds)
+			}
+		} else if hop.DelaySeconds == nil || *hop.DelaySeconds != want.delay {
+			t.Errorf("hop %d: expected delay %v, got %v", i+1, want.delay, hop.DelaySeconds)
+		}
+	}
+
+	if trace.OriginIP != "81.2.69.142" {
+		t.Errorf("expected origin 81.2.69.142, got %q", trace.OriginIP)
+	}
+	if trace.TotalDelaySeconds == nil || *trace.TotalDelaySeconds != 9 {
+		t.Errorf("expected total delay 9s, got %v", trace.TotalDelaySeconds)
+	}
+}
+
+func TestParseOriginatingIP(t *testing.T) {
+	headers := "X-Originating-IP: [81.2.69.160]\r\n" +
+		"Received: from webmail.example.com (webmail.example.com [8.8.8.8]) by mx.example.net; Tue, 2 Jan 2024 18:00:00 +0000\r\n"
+	trace, err := Parse(strings.NewReader(headers))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if trace.OriginIP != "81.2.69.160" {
+		t.Errorf("expected origin from X-Originating-IP, got %q", trace.OriginIP)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	if _, err := Parse(strings.NewReader("Subject: hello\n\n")); !errors.Is(err, ErrNoReceivedHeaders) {
+		t.Errorf("expected ErrNoReceivedHeaders, got %v", err)
+	}
+}
+
+func TestParseReceived(t *testing.T) {
+	tests := []struct {
+		received string
+		from     string
+		ip       string
+		private  bool
+	}{
+		{received: "from unknown (HELO relay) (81.2.69.142) by mx.example.net with SMTP; 2 Jan 2024 18:00:00 -0000", from: "unknown", ip: "81.2.69.142"},
+		{received: "from [IPv6:2a00:1450:4864:20::532] by mx.example.net", from: "IPv6:2a00:1450:4864:20::532", ip: "2a00:1450:4864:20::532"},
+		{received: "from relay.example.com (relay.example.com [192.0.2.1]) by mx.example.net", from: "relay.example.com", ip: "192.0.2.1", private: true},
+		{received: "from localhost (localhost [127.0.0.1]) by mx.example.net", from: "localhost", ip: "127.0.0.1", private: true},
+		{received: "from relay.example.com by mx.example.net", from: "relay.example.com"},
+		{received: "by mx.example.net (Postfix, from userid 1000) id 123; Tue, 2 Jan 2024 18:00:00 +0000"},
+	}
+
+	for _, tt := range tests {
+		hop := parseReceived(tt.received)
+		if hop.From != tt.from || hop.IP != tt.ip || hop.Private != tt.private {
+			t.Errorf("parseReceived(%q): expected from=%q ip=%q private=%v, got from=%q ip=%q private=%v",
+				tt.received, tt.from, tt.ip, tt.private, hop.From, hop.IP, hop.Private)
+		}
+	}
+}
+
+func TestGeolocate(t *testing.T) {
+	trace, err := Parse(strings.NewReader(sampleHeaders))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	reader := &geotest.Reader{Default: geo.IPInfo{ISOCode: "GB", Attribution: geo.Attribution}}
+	if err := trace.Geolocate(context.Background(), reader); err != nil {
+		t.Fatalf("Geolocate failed: %v", err)
+	}
+
+	if trace.Hops[0].Info != nil {
+		t.Error("expected private hop not to be geolocated")
+	}
+	if trace.Hops[1].Info == nil || trace.Hops[1].Info.ISOCode != "GB" {
+		t.Errorf("expected hop 2 to be geolocated, got %+v", trace.Hops[1].Info)
+	}
+	if trace.Origin == nil || trace.Origin.IP != "81.2.69.142" {
+		t.Errorf("expected origin to be geolocated, got %+v", trace.Origin)
+	}
+	// The origin shares its address with hop 2
+	if reader.Lookups() != 2 {
+		t.Errorf("expected 2 lookups, got %d", reader.Lookups())
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	want := time.Date(2024, 1, 2, 18, 0, 0, 0, time.UTC)
+	for _, s := range []string{
+		" Tue, 2 Jan 2024 10:00:00 -0800 (PST)",
+		"Tue,  2 Jan 2024 18:00:00 +0000",
+		"2 Jan 2024 18:00:00 -0000",
+	} {
+		got, ok := parseDate(s)
+		if !ok || !got.Equal(want) {
+			t.Errorf("parseDate(%q): expected %v, got %v (ok %v)", s, want, got, ok)
+		}
+	}
+}
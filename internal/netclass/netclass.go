@@ -0,0 +1,220 @@
+// Package netclass classifies networks by type (hosting, ISP, mobile, ...)
+// from their ASN, prefix and organization name.
+//
+// A mapping of well-known ASNs is embedded in the binary and can be extended
+// or overridden with additional mapping files in the same format.
+package netclass
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jcjc-dev/ipwhere/internal/netindex"
+)
+
+//go:embed networks.txt
+var bundled string
+
+// Network types
+const (
+	Hosting    = "hosting"
+	ISP        = "isp"
+	Mobile     = "mobile"
+	Education  = "education"
+	Government = "government"
+	CDN        = "cdn"
+	VPN        = "vpn"
+)
+
+var types = map[string]bool{
+	Hosting:    true,
+	ISP:        true,
+	Mobile:     true,
+	Education:  true,
+	Government: true,
+	CDN:        true,
+	VPN:        true,
+}
+
+// IsHosting reports whether a network type carries server rather than end
+// user traffic
+func IsHosting(networkType string) bool {
+	return networkType == Hosting || networkType == CDN || networkType == VPN
+}
+
+// Classifier maps networks to their type. Prefix entries take precedence over
+// ASN entries, which take precedence over organization name heuristics.
+type Classifier struct {
+	asns     map[uint]string
+	prefixes *netindex.Index[string]
+}
+
+// Load creates a classifier from the bundled mapping extended with the given
+// mapping files. Later files override earlier entries.
+func Load(paths ...string) (*Classifier, error) {
+	c := &Classifier{
+		asns:     make(map[uint]string),
+		prefixes: netindex.New[string](),
+	}
+	if err := c.parse(strings.NewReader(bundled)); err != nil {
+		return nil, fmt.Errorf("invalid bundled mapping: %w", err)
+	}
+	for _, path := range paths {
+		if err := c.parseFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Default returns a classifier using only the bundled mapping
+func Default() *Classifier {
+	c, err := Load()
+	if err != nil {
+		// The mapping is embedded, so this can only fail at build time
+		panic(fmt.Sprintf("netclass: %v", err))
+	}
+	return c
+}
+
+func (c *Classifier) parseFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read network types: %w", err)
+	}
+	defer f.Close()
+	if err := c.parse(f); err != nil {
+		return fmt.Errorf("failed to parse network types from %s: %w", path, err)
+	}
+	return nil
+}
+
+// parse reads "<ASN or prefix> <type>" lines
+func (c *Classifier) parse(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return fmt.Errorf("line %d: expected <asn|prefix> <type>", lineNo)
+		}
+		key, networkType := fields[0], strings.ToLower(fields[1])
+		if !types[networkType] {
+			return fmt.Errorf("line %d: unknown network type %q", lineNo, fields[1])
+		}
+
+		if asn, ok := parseASN(key); ok {
+			c.asns[asn] = networkType
+			continue
+		}
+		p, err := netindex.ParsePrefix(key)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid ASN or prefix %q", lineNo, key)
+		}
+		c.prefixes.Insert(p, networkType)
+	}
+	return scanner.Err()
+}
+
+// parseASN parses "AS123" or "123"
+func parseASN(s string) (uint, bool) {
+	if len(s) > 2 && strings.EqualFold(s[:2], "AS") {
+		s = s[2:]
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint(n), true
+}
+
+// Classify returns the network type for an address, or an empty string if it
+// cannot be determined. asn may be 0 and org empty when unknown.
+func (c *Classifier) Classify(ip net.IP, asn uint, org string) string {
+	if addr, ok := netindex.AddrFromIP(ip); ok {
+		if _, values, ok := c.prefixes.Lookup(addr); ok {
+			return values[len(values)-1]
+		}
+	}
+	if t, ok := c.asns[asn]; ok && asn != 0 {
+		return t
+	}
+	return classifyOrg(org)
+}
+
+// orgKeywords are matched against the words of lower-cased organization
+// names in order, so more specific types come first. A keyword of several
+// words matches consecutive words; a trailing * matches any word starting
+// with the stem, e.g. universit* for university and universität.
+var orgKeywords = []struct {
+	networkType string
+	keywords    []string
+}{
+	{VPN, []string{"vpn", "proxy"}},
+	{CDN, []string{"cdn", "content delivery", "akamai*", "fastly*", "cloudflare*"}},
+	{Education, []string{"universit*", "college", "school", "schools", "academ*", "institute of technology", "education"}},
+	{Government, []string{"government", "ministry", "ministerio", "department of", "municipal*", "county of", "city of"}},
+	{Mobile, []string{"mobile", "wireless", "cellular", "gsm"}},
+	{Hosting, []string{"hosting", "webhosting", "datacenter*", "data center*", "data centre*", "server", "servers", "cloud computing", "cloud services", "colocation", "vps", "dedicated server*", "digitalocean", "hetzner", "ovh", "linode", "vultr", "amazon", "leaseweb"}},
+	{ISP, []string{"telecom*", "telekom*", "communications", "broadband", "cable", "fiber", "fibre", "dsl", "internet service*", "telephone", "telefon*", "net access"}},
+}
+
+// classifyOrg guesses the network type from an organization name. Keywords
+// only match whole words, so e.g. ExxonMobil is not a mobile network.
+func classifyOrg(org string) string {
+	words := strings.FieldsFunc(strings.ToLower(org), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if len(words) == 0 {
+		return ""
+	}
+	for _, k := range orgKeywords {
+		for _, kw := range k.keywords {
+			if containsPhrase(words, strings.Fields(kw)) {
+				return k.networkType
+			}
+		}
+	}
+	return ""
+}
+
+// containsPhrase reports whether phrase occurs as consecutive words
+func containsPhrase(words, phrase []string) bool {
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		match := true
+		for j, p := range phrase {
+			if stem, ok := strings.CutSuffix(p, "*"); ok {
+				match = strings.HasPrefix(words[i+j], stem)
+			} else {
+				match = words[i+j] == p
+			}
+			if !match {
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of ASN and prefix entries
+func (c *Classifier) Len() int {
+	return len(c.asns) + c.prefixes.Len()
+}
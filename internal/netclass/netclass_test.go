@@ -0,0 +1,134 @@
+package netclass
+
+import (
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	c := Default()
+
+	tests := []struct {
+		name     string
+		ip       string
+		asn      uint
+		org      string
+		expected string
+	}{
+		{name: "bundled hosting ASN", ip: "52.94.1.1", asn: 16509, org: "AMAZON-02", expected: Hosting},
+		{name: "bundled CDN ASN", ip: "104.16.0.1", asn: 13335, org: "CLOUDFLARENET", expected: CDN},
+		{name: "bundled ISP ASN", ip: "73.1.1.1", asn: 7922, org: "Comcast Cable Communications, LLC", expected: ISP},
+		{name: "bundled mobile ASN", ip: "172.56.1.1", asn: 21928, org: "T-Mobile USA, Inc.", expected: Mobile},
+		{name: "education heuristic", ip: "192.0.2.1", asn: 64500, org: "Example State University", expected: Education},
+		{name: "government heuristic", ip: "192.0.2.1", asn: 64500, org: "Ministry of Finance", expected: Government},
+		{name: "VPN heuristic", ip: "192.0.2.1", asn: 64500, org: "Example VPN Ltd", expected: VPN},
+		{name: "hosting heuristic", ip: "192.0.2.1", asn: 64500, org: "Example Hosting GmbH", expected: Hosting},
+		{name: "mobile heuristic", ip: "192.0.2.1", asn: 64500, org: "Example Wireless Inc", expected: Mobile},
+		{name: "ISP heuristic", ip: "192.0.2.1", asn: 64500, org: "Example Broadband Ltd", expected: ISP},
+		{name: "word stem heuristic", ip: "192.0.2.1", asn: 64500, org: "Universität Example", expected: Education},
+		{name: "hyphenated heuristic", ip: "192.0.2.1", asn: 64500, org: "EXAMPLE-MOBILE-AS", expected: Mobile},
+		{name: "phrase heuristic", ip: "192.0.2.1", asn: 64500, org: "Example Data Center LLC", expected: Hosting},
+		{name: "ISP stem heuristic", ip: "192.0.2.1", asn: 64500, org: "Example Telecommunications", expected: ISP},
+		{name: "mobil inside a word", ip: "192.0.2.1", asn: 64500, org: "ExxonMobil Corporation", expected: ""},
+		{name: "host inside a word", ip: "192.0.2.1", asn: 64500, org: "Hostelworld Group", expected: ""},
+		{name: "host as a prefix", ip: "192.0.2.1", asn: 64500, org: "Ghost Inc", expected: ""},
+		{name: "federal", ip: "192.0.2.1", asn: 64500, org: "Federal Express Corporation", expected: ""},
+		{name: "state of", ip: "192.0.2.1", asn: 64500, org: "State of the Art Networks", expected: ""},
+		{name: "cloud", ip: "192.0.2.1", asn: 64500, org: "SoundCloud Ltd", expected: ""},
+		{name: "unknown", ip: "192.0.2.1", asn: 64500, org: "Example Corp", expected: ""},
+		{name: "no ASN data", ip: "10.0.0.1", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Classify(net.ParseIP(tt.ip), tt.asn, tt.org); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "networks.txt")
+	content := `# local overrides
+AS7922         hosting   # reclassify
+64500          vpn
+198.51.100.0/24 government
+2001:db8::/32  education
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	tests := []struct {
+		ip       string
+		asn      uint
+		expected string
+	}{
+		{ip: "73.1.1.1", asn: 7922, expected: Hosting},
+		{ip: "192.0.2.1", asn: 64500, expected: VPN},
+		// Prefix entries win over ASN entries
+		{ip: "198.51.100.7", asn: 64500, expected: Government},
+		{ip: "2001:db8::1", asn: 0, expected: Education},
+	}
+	for _, tt := range tests {
+		if got := c.Classify(net.ParseIP(tt.ip), tt.asn, ""); got != tt.expected {
+			t.Errorf("Classify(%s, AS%d): expected %q, got %q", tt.ip, tt.asn, tt.expected, got)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"type.txt":   "AS64500 residential\n",
+		"key.txt":    "not-an-asn hosting\n",
+		"fields.txt": "AS64500\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("expected %s to fail", name)
+		}
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Error("expected a missing file to fail")
+	}
+}
+
+func TestIsHosting(t *testing.T) {
+	for _, networkType := range []string{Hosting, CDN, VPN} {
+		if !IsHosting(networkType) {
+			t.Errorf("expected %s to count as hosting", networkType)
+		}
+	}
+	for _, networkType := range []string{ISP, Mobile, Education, Government, ""} {
+		if IsHosting(networkType) {
+			t.Errorf("expected %q not to count as hosting", networkType)
+		}
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	for _, s := range []string{"8.8.8.8", "81.2.69.142", "2a00:1450::1", "::ffff:8.8.8.8"} {
+		if !IsPublic(netip.MustParseAddr(s)) {
+			t.Errorf("expected %s to be public", s)
+		}
+	}
+	for _, s := range []string{"10.1.2.3", "192.168.0.1", "127.0.0.1", "100.64.0.1", "192.0.2.1", "169.254.1.1", "224.0.0.1", "0.0.0.0", "::1", "fe80::1", "fd00::1", "2001:db8::1"} {
+		if IsPublic(netip.MustParseAddr(s)) {
+			t.Errorf("expected %s not to be public", s)
+		}
+	}
+}
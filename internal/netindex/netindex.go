@@ -0,0 +1,146 @@
+// Package netindex provides a prefix index for longest-prefix-match lookups
+// of IPv4 and IPv6 addresses.
+package netindex
+
+import (
+	"net"
+	"net/netip"
+)
+
+// Index maps IP prefixes to values. Several values may be stored under the
+// same prefix. An Index is not safe for concurrent modification, but may be
+// read concurrently once fully built.
+type Index[T any] struct {
+	v4   *node[T]
+	v6   *node[T]
+	size int
+}
+
+type node[T any] struct {
+	child  [2]*node[T]
+	prefix netip.Prefix
+	values []T
+}
+
+// New creates an empty index
+func New[T any]() *Index[T] {
+	return &Index[T]{
+		v4: &node[T]{},
+		v6: &node[T]{},
+	}
+}
+
+// Len returns the number of distinct prefixes in the index
+func (ix *Index[T]) Len() int {
+	return ix.size
+}
+
+// Insert adds a value under the given prefix. IPv4-mapped IPv6 prefixes are
+// stored as IPv4.
+func (ix *Index[T]) Insert(p netip.Prefix, v T) {
+	p = normalize(p)
+	n := ix.root(p.Addr())
+	addr := p.Addr()
+	for i := 0; i < p.Bits(); i++ {
+		b := bit(addr, i)
+		if n.child[b] == nil {
+			n.child[b] = &node[T]{}
+		}
+		n = n.child[b]
+	}
+	if len(n.values) == 0 {
+		ix.size++
+		n.prefix = p
+	}
+	n.values = append(n.values, v)
+}
+
+// Lookup returns the values stored under the longest prefix containing addr
+func (ix *Index[T]) Lookup(addr netip.Addr) (netip.Prefix, []T, bool) {
+	var best *node[T]
+	ix.walk(addr, func(n *node[T]) {
+		best = n
+	})
+	if best == nil {
+		return netip.Prefix{}, nil, false
+	}
+	return best.prefix, best.values, true
+}
+
+// Covering returns the values of every prefix containing addr, from the
+// shortest prefix to the longest
+func (ix *Index[T]) Covering(addr netip.Addr) []T {
+	var result []T
+	ix.walk(addr, func(n *node[T]) {
+		result = append(result, n.values...)
+	})
+	return result
+}
+
+// walk calls fn for every node holding values on the path to addr
+func (ix *Index[T]) walk(addr netip.Addr, fn func(*node[T])) {
+	if !addr.IsValid() {
+		return
+	}
+	addr = addr.Unmap()
+	n := ix.root(addr)
+	for i := 0; n != nil; i++ {
+		if len(n.values) > 0 {
+			fn(n)
+		}
+		if i == addr.BitLen() {
+			return
+		}
+		n = n.child[bit(addr, i)]
+	}
+}
+
+func (ix *Index[T]) root(addr netip.Addr) *node[T] {
+	if addr.Is4() {
+		return ix.v4
+	}
+	return ix.v6
+}
+
+// bit returns the i-th most significant bit of addr
+func bit(addr netip.Addr, i int) int {
+	if addr.Is4() {
+		b := addr.As4()
+		return int(b[i/8]>>(7-i%8)) & 1
+	}
+	b := addr.As16()
+	return int(b[i/8]>>(7-i%8)) & 1
+}
+
+// normalize masks the prefix and unmaps IPv4-mapped IPv6 prefixes
+func normalize(p netip.Prefix) netip.Prefix {
+	addr := p.Addr()
+	if addr.Is4In6() && p.Bits() >= 96 {
+		p = netip.PrefixFrom(addr.Unmap(), p.Bits()-96)
+	}
+	return p.Masked()
+}
+
+// ParsePrefix parses a CIDR prefix or a bare IP address, which is treated as
+// a single-address prefix
+func ParsePrefix(s string) (netip.Prefix, error) {
+	if p, err := netip.ParsePrefix(s); err == nil {
+		return normalize(p), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// AddrFromIP converts a net.IP into a netip.Addr, unmapping IPv4-mapped
+// addresses
+func AddrFromIP(ip net.IP) (netip.Addr, bool) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
@@ -0,0 +1,105 @@
+package netindex
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestIndexLookup(t *testing.T) {
+	ix := New[string]()
+	ix.Insert(netip.MustParsePrefix("10.0.0.0/8"), "ten")
+	ix.Insert(netip.MustParsePrefix("10.1.0.0/16"), "ten-one")
+	ix.Insert(netip.MustParsePrefix("10.1.2.3/32"), "host")
+	ix.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc")
+	ix.Insert(netip.MustParsePrefix("0.0.0.0/0"), "default")
+
+	tests := []struct {
+		addr     string
+		prefix   string
+		expected string
+	}{
+		{addr: "10.2.3.4", prefix: "10.0.0.0/8", expected: "ten"},
+		{addr: "10.1.9.9", prefix: "10.1.0.0/16", expected: "ten-one"},
+		{addr: "10.1.2.3", prefix: "10.1.2.3/32", expected: "host"},
+		{addr: "::ffff:10.1.2.3", prefix: "10.1.2.3/32", expected: "host"},
+		{addr: "192.0.2.1", prefix: "0.0.0.0/0", expected: "default"},
+		{addr: "2001:db8::1", prefix: "2001:db8::/32", expected: "doc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			prefix, values, ok := ix.Lookup(netip.MustParseAddr(tt.addr))
+			if !ok {
+				t.Fatalf("expected a match for %s", tt.addr)
+			}
+			if prefix.String() != tt.prefix {
+				t.Errorf("expected prefix %s, got %s", tt.prefix, prefix)
+			}
+			if len(values) != 1 || values[0] != tt.expected {
+				t.Errorf("expected value %s, got %v", tt.expected, values)
+			}
+		})
+	}
+
+	if _, _, ok := ix.Lookup(netip.MustParseAddr("2001:db9::1")); ok {
+		t.Error("expected no match outside indexed IPv6 prefixes")
+	}
+	if ix.Len() != 5 {
+		t.Errorf("expected 5 prefixes, got %d", ix.Len())
+	}
+}
+
+func TestIndexCovering(t *testing.T) {
+	ix := New[string]()
+	ix.Insert(netip.MustParsePrefix("192.0.2.0/24"), "a")
+	ix.Insert(netip.MustParsePrefix("192.0.2.128/25"), "b")
+	ix.Insert(netip.MustParsePrefix("192.0.2.128/25"), "c")
+
+	got := ix.Covering(netip.MustParseAddr("192.0.2.200"))
+	if len(got) != 3 || got[0] != "a" || got[1] != "b" || got[2] != "c" {
+		t.Errorf("expected [a b c], got %v", got)
+	}
+	if got := ix.Covering(netip.MustParseAddr("192.0.2.1")); len(got) != 1 {
+		t.Errorf("expected [a], got %v", got)
+	}
+	if ix.Len() != 2 {
+		t.Errorf("expected 2 prefixes, got %d", ix.Len())
+	}
+}
+
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "192.0.2.7/24", expected: "192.0.2.0/24"},
+		{input: "192.0.2.7", expected: "192.0.2.7/32"},
+		{input: "2001:db8::1", expected: "2001:db8::1/128"},
+		{input: "::ffff:192.0.2.0/120", expected: "192.0.2.0/24"},
+	}
+	for _, tt := range tests {
+		p, err := ParsePrefix(tt.input)
+		if err != nil {
+			t.Errorf("ParsePrefix(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if p.String() != tt.expected {
+			t.Errorf("ParsePrefix(%q): expected %s, got %s", tt.input, tt.expected, p)
+		}
+	}
+
+	if _, err := ParsePrefix("not-an-ip"); err == nil {
+		t.Error("expected an error for an invalid prefix")
+	}
+}
+
+func TestAddrFromIP(t *testing.T) {
+	addr, ok := AddrFromIP(net.ParseIP("192.0.2.1"))
+	if !ok || !addr.Is4() {
+		t.Errorf("expected an unmapped IPv4 address, got %v", addr)
+	}
+	if _, ok := AddrFromIP(nil); ok {
+		t.Error("expected nil IP to be rejected")
+	}
+}
@@ -0,0 +1,328 @@
+// Package policy evaluates named allow/deny rules against geolocation data,
+// so that services can share one implementation of country blocking.
+//
+// Policies are loaded from a JSON file:
+//
+//	{
+//	  "policies": {
+//	    "signup": {
+//	      "default": "deny",
+//	      "rules": [
+//	        {"name": "office", "action": "allow", "cidrs": ["203.0.113.0/24"]},
+//	        {"name": "tor", "action": "deny", "lists": ["tor"]},
+//	        {"name": "europe", "action": "allow", "eu": true}
+//	      ]
+//	    }
+//	  }
+//	}
+//
+// Rules are evaluated in order and the first matching rule decides. Within a
+// rule every condition must match; within a condition any value may match.
+// A rule without conditions matches every address.
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jcjc-dev/ipwhere/internal/country"
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+	"github.com/jcjc-dev/ipwhere/internal/netindex"
+)
+
+// ErrUnknownPolicy is returned when evaluating a policy that is not configured
+var ErrUnknownPolicy = errors.New("unknown policy")
+
+// Action is the outcome of a rule
+type Action string
+
+// Actions
+const (
+	Allow Action = "allow"
+	Deny  Action = "deny"
+)
+
+// DefaultRule is reported when no rule matched and the policy default applied
+const DefaultRule = "default"
+
+var continents = map[string]bool{"AF": true, "AN": true, "AS": true, "EU": true, "NA": true, "OC": true, "SA": true}
+
+// Rule matches addresses by location, network or list membership.
+// Countries are ISO 3166-1 alpha-2 codes and continents two-letter continent
+// codes. Lists match either a list name or a list category.
+type Rule struct {
+	Name       string   `json:"name,omitempty"`
+	Action     Action   `json:"action"`
+	Countries  []string `json:"countries,omitempty"`
+	Continents []string `json:"continents,omitempty"`
+	EU         *bool    `json:"eu,omitempty"`
+	ASNs       []uint   `json:"asns,omitempty"`
+	CIDRs      []string `json:"cidrs,omitempty"`
+	Lists      []string `json:"lists,omitempty"`
+
+	prefixes []netip.Prefix
+}
+
+// Policy is an ordered list of rules with a default action
+type Policy struct {
+	Default Action `json:"default,omitempty"`
+	Rules   []Rule `json:"rules"`
+}
+
+// Decision is the result of evaluating a policy for an address
+type Decision struct {
+	Policy      string `json:"policy"`
+	IP          string `json:"ip"`
+	Decision    Action `json:"decision"`
+	Rule        string `json:"rule"`
+	ISOCode     string `json:"iso_code,omitempty"`
+	ASN         *uint  `json:"asn,omitempty"`
+	Attribution string `json:"attribution"`
+}
+
+// config is the policy file format
+type config struct {
+	Policies map[string]*Policy `json:"policies"`
+}
+
+// Parse reads and validates a policy file
+func Parse(data []byte) (map[string]*Policy, error) {
+	var cfg config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid policy file: %w", err)
+	}
+	for name, p := range cfg.Policies {
+		if p == nil {
+			return nil, fmt.Errorf("policy %s: empty definition", name)
+		}
+		if err := p.compile(); err != nil {
+			return nil, fmt.Errorf("policy %s: %w", name, err)
+		}
+	}
+	return cfg.Policies, nil
+}
+
+// compile validates the policy and normalizes its rules
+func (p *Policy) compile() error {
+	if p.Default == "" {
+		p.Default = Allow
+	}
+	if !p.Default.valid() {
+		return fmt.Errorf("invalid default action %q", p.Default)
+	}
+
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if !r.Action.valid() {
+			return fmt.Errorf("%s: invalid action %q", r.Name, r.Action)
+		}
+		for j, c := range r.Countries {
+			r.Countries[j] = strings.ToUpper(c)
+			if _, ok := country.Lookup(c); !ok {
+				return fmt.Errorf("%s: unknown country %q", r.Name, c)
+			}
+		}
+		for j, c := range r.Continents {
+			r.Continents[j] = strings.ToUpper(c)
+			if !continents[r.Continents[j]] {
+				return fmt.Errorf("%s: unknown continent %q", r.Name, c)
+			}
+		}
+		r.prefixes = r.prefixes[:0]
+		for _, s := range r.CIDRs {
+			prefix, err := netindex.ParsePrefix(s)
+			if err != nil {
+				return fmt.Errorf("%s: invalid CIDR %q", r.Name, s)
+			}
+			r.prefixes = append(r.prefixes, prefix)
+		}
+	}
+	return nil
+}
+
+func (a Action) valid() bool {
+	return a == Allow || a == Deny
+}
+
+// Evaluate applies the policy to a lookup result, returning the action and
+// the name of the deciding rule
+func (p *Policy) Evaluate(info *geo.IPInfo) (Action, string) {
+	for i := range p.Rules {
+		if p.Rules[i].matches(info) {
+			return p.Rules[i].Action, p.Rules[i].Name
+		}
+	}
+	return p.Default, DefaultRule
+}
+
+// matches reports whether every condition of the rule holds for info
+func (r *Rule) matches(info *geo.IPInfo) bool {
+	if len(r.Countries) > 0 && !contains(r.Countries, strings.ToUpper(info.ISOCode)) {
+		return false
+	}
+	if len(r.Continents) > 0 {
+		c, ok := country.Lookup(info.ISOCode)
+		if !ok || !contains(r.Continents, c.Continent) {
+			return false
+		}
+	}
+	if r.EU != nil && *r.EU != info.InEU {
+		return false
+	}
+	if len(r.ASNs) > 0 && (info.ASN == nil || !contains(r.ASNs, *info.ASN)) {
+		return false
+	}
+	if len(r.prefixes) > 0 && !r.matchesPrefix(info.IP) {
+		return false
+	}
+	if len(r.Lists) > 0 && !r.matchesList(info) {
+		return false
+	}
+	return true
+}
+
+func (r *Rule) matchesPrefix(ipStr string) bool {
+	addr, ok := netindex.AddrFromIP(net.ParseIP(ipStr))
+	if !ok {
+		return false
+	}
+	for _, p := range r.prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesList(info *geo.IPInfo) bool {
+	for _, m := range info.Lists {
+		if contains(r.Lists, m.Name) || contains(r.Lists, m.Category) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains[T comparable](values []T, v T) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Engine holds the policies loaded from a file
+type Engine struct {
+	path string
+
+	mu       sync.RWMutex
+	policies map[string]*Policy
+	modTime  time.Time
+}
+
+// Load reads the policy file at path
+func Load(path string) (*Engine, error) {
+	e := &Engine{path: path}
+	if err := e.load(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Reload re-reads the policy file if it changed on disk.
+// On failure the previously loaded policies stay in use.
+func (e *Engine) Reload() error {
+	st, err := os.Stat(e.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat policy file: %w", err)
+	}
+	e.mu.RLock()
+	unchanged := st.ModTime().Equal(e.modTime)
+	e.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	return e.load()
+}
+
+func (e *Engine) load() error {
+	st, err := os.Stat(e.path)
+	if err != nil {
+		return fmt.Errorf("failed to read policy file: %w", err)
+	}
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		return fmt.Errorf("failed to read policy file: %w", err)
+	}
+	policies, err := Parse(data)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.policies = policies
+	e.modTime = st.ModTime()
+	e.mu.Unlock()
+	return nil
+}
+
+// Names returns the configured policy names in sorted order
+func (e *Engine) Names() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	names := make([]string, 0, len(e.policies))
+	for name := range e.policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Policy returns the named policy
+func (e *Engine) Policy(name string) (*Policy, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	p, ok := e.policies[name]
+	return p, ok
+}
+
+// Evaluate looks up ip and applies the named policy
+func (e *Engine) Evaluate(ctx context.Context, reader geo.ReaderInterface, name string, ip net.IP) (*Decision, error) {
+	p, ok := e.Policy(name)
+	if !ok {
+		return nil, ErrUnknownPolicy
+	}
+
+	info, err := reader.Lookup(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+	action, rule := p.Evaluate(info)
+	return &Decision{
+		Policy:      name,
+		IP:          info.IP,
+		Decision:    action,
+		Rule:        rule,
+		ISOCode:     info.ISOCode,
+		ASN:         info.ASN,
+		Attribution: geo.Attribution,
+	}, nil
+}
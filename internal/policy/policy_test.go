@@ -0,0 +1,195 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+	"github.com/jcjc-dev/ipwhere/internal/geo/geotest"
+	"github.com/jcjc-dev/ipwhere/internal/lists"
+)
+
+const testPolicies = `{
+  "policies": {
+    "signup": {
+      "default": "deny",
+      "rules": [
+        {"name": "office", "action": "allow", "cidrs": ["203.0.113.0/24", "2001:db8::/32"]},
+        {"name": "anonymizers", "action": "deny", "lists": ["anonymizer"]},
+        {"name": "sanctioned", "action": "deny", "countries": ["kp", "IR"]},
+        {"name": "europe", "action": "allow", "eu": true},
+        {"name": "google-na", "action": "allow", "continents": ["NA"], "asns": [15169]}
+      ]
+    },
+    "open": {
+      "rules": [
+        {"action": "deny", "countries": ["KP"]}
+      ]
+    }
+  }
+}`
+
+func uintPtr(u uint) *uint {
+	return &u
+}
+
+func TestEvaluate(t *testing.T) {
+	policies, err := Parse([]byte(testPolicies))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		policy         string
+		info           geo.IPInfo
+		expectedAction Action
+		expectedRule   string
+	}{
+		{
+			name:           "CIDR allow wins over country deny",
+			policy:         "signup",
+			info:           geo.IPInfo{IP: "203.0.113.5", ISOCode: "KP"},
+			expectedAction: Allow,
+			expectedRule:   "office",
+		},
+		{
+			name:           "IPv6 CIDR",
+			policy:         "signup",
+			info:           geo.IPInfo{IP: "2001:db8::1", ISOCode: "US"},
+			expectedAction: Allow,
+			expectedRule:   "office",
+		},
+		{
+			name:           "list category",
+			policy:         "signup",
+			info:           geo.IPInfo{IP: "198.51.100.7", ISOCode: "DE", InEU: true, Lists: []lists.Match{{Name: "tor", Category: "anonymizer"}}},
+			expectedAction: Deny,
+			expectedRule:   "anonymizers",
+		},
+		{
+			name:           "country codes are case insensitive",
+			policy:         "signup",
+			info:           geo.IPInfo{IP: "192.0.2.1", ISOCode: "KP"},
+			expectedAction: Deny,
+			expectedRule:   "sanctioned",
+		},
+		{
+			name:           "EU membership",
+			policy:         "signup",
+			info:           geo.IPInfo{IP: "192.0.2.1", ISOCode: "FR", InEU: true},
+			expectedAction: Allow,
+			expectedRule:   "europe",
+		},
+		{
+			name:           "continent and ASN",
+			policy:         "signup",
+			info:           geo.IPInfo{IP: "8.8.8.8", ISOCode: "US", ASN: uintPtr(15169)},
+			expectedAction: Allow,
+			expectedRule:   "google-na",
+		},
+		{
+			name:           "all conditions must match",
+			policy:         "signup",
+			info:           geo.IPInfo{IP: "192.0.2.1", ISOCode: "US", ASN: uintPtr(7922)},
+			expectedAction: Deny,
+			expectedRule:   DefaultRule,
+		},
+		{
+			name:           "no location data",
+			policy:         "signup",
+			info:           geo.IPInfo{IP: "10.0.0.1"},
+			expectedAction: Deny,
+			expectedRule:   DefaultRule,
+		},
+		{
+			name:           "default allow and generated rule name",
+			policy:         "open",
+			info:           geo.IPInfo{IP: "192.0.2.1", ISOCode: "KP"},
+			expectedAction: Deny,
+			expectedRule:   "rule 1",
+		},
+		{
+			name:           "default allow",
+			policy:         "open",
+			info:           geo.IPInfo{IP: "192.0.2.1", ISOCode: "US"},
+			expectedAction: Allow,
+			expectedRule:   DefaultRule,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, rule := policies[tt.policy].Evaluate(&tt.info)
+			if action != tt.expectedAction || rule != tt.expectedRule {
+				t.Errorf("expected %s by %q, got %s by %q", tt.expectedAction, tt.expectedRule, action, rule)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"invalid JSON":       `{`,
+		"unknown field":      `{"policies": {"p": {"rules": [{"action": "allow", "country": ["US"]}]}}}`,
+		"invalid action":     `{"policies": {"p": {"rules": [{"action": "block"}]}}}`,
+		"invalid default":    `{"policies": {"p": {"default": "maybe", "rules": []}}}`,
+		"unknown country":    `{"policies": {"p": {"rules": [{"action": "deny", "countries": ["XX"]}]}}}`,
+		"unknown continent":  `{"policies": {"p": {"rules": [{"action": "deny", "continents": ["EA"]}]}}}`,
+		"invalid CIDR":       `{"policies": {"p": {"rules": [{"action": "deny", "cidrs": ["10.0.0.0/33"]}]}}}`,
+		"missing definition": `{"policies": {"p": null}}`,
+	}
+	for name, data := range tests {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: expected Parse to fail", name)
+		}
+	}
+}
+
+func TestEngine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.json")
+	if err := os.WriteFile(path, []byte(testPolicies), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if names := engine.Names(); len(names) != 2 || names[0] != "open" || names[1] != "signup" {
+		t.Errorf("unexpected policy names: %v", names)
+	}
+
+	reader := &geotest.Reader{Default: geo.IPInfo{ISOCode: "DE", InEU: true}}
+	d, err := engine.Evaluate(context.Background(), reader, "signup", net.ParseIP("192.0.2.1"))
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	if d.Decision != Allow || d.Rule != "europe" || d.IP != "192.0.2.1" || d.ISOCode != "DE" {
+		t.Errorf("unexpected decision: %+v", d)
+	}
+
+	if _, err := engine.Evaluate(context.Background(), reader, "missing", net.ParseIP("192.0.2.1")); !errors.Is(err, ErrUnknownPolicy) {
+		t.Errorf("expected ErrUnknownPolicy, got %v", err)
+	}
+
+	// A broken file keeps the previous policies in place
+	if err := os.WriteFile(path, []byte(`{"policies": {"signup": {"default": "nope"}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Reload(); err == nil {
+		t.Error("expected reload of an invalid file to fail")
+	}
+	if len(engine.Names()) != 2 {
+		t.Error("expected previous policies to remain after a failed reload")
+	}
+}
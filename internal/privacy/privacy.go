@@ -0,0 +1,221 @@
+// Package privacy anonymizes client addresses for logs and diagnostics.
+//
+// Addresses are truncated to a network prefix, which keeps them useful for
+// coarse analysis without identifying a subscriber. For counting unique
+// clients, addresses can also be hashed with a random salt that is replaced
+// at a fixed interval and never stored, so hashes cannot be linked across
+// intervals or reversed once the interval has ended.
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jcjc-dev/ipwhere/internal/extract"
+)
+
+// Default prefix lengths addresses are truncated to
+const (
+	DefaultIPv4Prefix = 24
+	DefaultIPv6Prefix = 48
+)
+
+// Handling of the debug endpoint in privacy mode
+const (
+	DebugDisabled = "disabled" // Answer 404
+	DebugRedacted = "redact"   // Truncate addresses and drop credentials
+)
+
+// Config configures an Anonymizer
+type Config struct {
+	IPv4Prefix   int           // Prefix length IPv4 addresses are truncated to (default 24)
+	IPv6Prefix   int           // Prefix length IPv6 addresses are truncated to (default 48)
+	HashRotation time.Duration // Salt lifetime for client hashes (0 = no hashing)
+	Debug        string        // DebugDisabled (default) or DebugRedacted
+}
+
+// Anonymizer truncates and hashes addresses. It is safe for concurrent use.
+type Anonymizer struct {
+	cfg Config
+	now func() time.Time
+
+	mu         sync.Mutex
+	salt       []byte
+	saltWindow time.Time
+}
+
+// New validates cfg and returns an Anonymizer
+func New(cfg Config) (*Anonymizer, error) {
+	if cfg.IPv4Prefix == 0 {
+		cfg.IPv4Prefix = DefaultIPv4Prefix
+	}
+	if cfg.IPv6Prefix == 0 {
+		cfg.IPv6Prefix = DefaultIPv6Prefix
+	}
+	if cfg.IPv4Prefix < 0 || cfg.IPv4Prefix > 32 {
+		return nil, fmt.Errorf("invalid IPv4 prefix length %d", cfg.IPv4Prefix)
+	}
+	if cfg.IPv6Prefix < 0 || cfg.IPv6Prefix > 128 {
+		return nil, fmt.Errorf("invalid IPv6 prefix length %d", cfg.IPv6Prefix)
+	}
+	if cfg.HashRotation < 0 {
+		return nil, fmt.Errorf("invalid hash rotation %s", cfg.HashRotation)
+	}
+	switch cfg.Debug {
+	case "":
+		cfg.Debug = DebugDisabled
+	case DebugDisabled, DebugRedacted:
+	default:
+		return nil, fmt.Errorf("invalid debug mode %q (want %s or %s)", cfg.Debug, DebugDisabled, DebugRedacted)
+	}
+	return &Anonymizer{cfg: cfg, now: time.Now}, nil
+}
+
+// Config returns the effective configuration
+func (a *Anonymizer) Config() Config {
+	return a.cfg
+}
+
+// Truncate zeroes the host bits of addr beyond the configured prefix length
+func (a *Anonymizer) Truncate(addr netip.Addr) netip.Addr {
+	addr = addr.Unmap().WithZone("")
+	bits := a.cfg.IPv6Prefix
+	if addr.Is4() {
+		bits = a.cfg.IPv4Prefix
+	}
+	p, err := addr.Prefix(bits)
+	if err != nil {
+		return addr
+	}
+	return p.Addr()
+}
+
+// TruncateString truncates an address given as text, with or without a
+// port. Anything that is not an address is returned unchanged.
+func (a *Anonymizer) TruncateString(s string) string {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return a.Truncate(addr).String()
+	}
+	if host, port, err := net.SplitHostPort(s); err == nil {
+		if addr, err := netip.ParseAddr(host); err == nil {
+			return net.JoinHostPort(a.Truncate(addr).String(), port)
+		}
+	}
+	return s
+}
+
+// Redact truncates every address found in free text such as a header value.
+// It fails closed: anything that may be an address is truncated, even if it
+// is glued to a word.
+func (a *Anonymizer) Redact(text string) string {
+	matches := extract.FindAll(text)
+	if len(matches) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(text[last:m.Offset])
+		addr := a.Truncate(m.Addr).String()
+		if strings.HasPrefix(m.Text, "[") {
+			addr = "[" + addr + "]"
+		}
+		b.WriteString(addr)
+		if m.Port > 0 {
+			fmt.Fprintf(&b, ":%d", m.Port)
+		}
+		last = m.Offset + m.Length
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// RedactURI redacts a request URI after decoding percent-encoded bytes, so
+// that e.g. ?ip=2001%3Adb8%3A%3A1 does not slip through. The result is
+// decoded.
+func (a *Anonymizer) RedactURI(uri string) string {
+	return a.Redact(unescape(uri))
+}
+
+// unescape decodes %XX sequences until none are left, keeping malformed ones
+// as-is, so partially or doubly encoded input is decoded as far as possible
+func unescape(s string) string {
+	for range 3 {
+		if !strings.Contains(s, "%") {
+			break
+		}
+		var b strings.Builder
+		b.Grow(len(s))
+		for i := 0; i < len(s); i++ {
+			if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+				b.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+				i += 2
+				continue
+			}
+			b.WriteByte(s[i])
+		}
+		if b.Len() == len(s) {
+			break
+		}
+		s = b.String()
+	}
+	return s
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	}
+	return c - '0'
+}
+
+// Hashing reports whether client hashes are enabled
+func (a *Anonymizer) Hashing() bool {
+	return a.cfg.HashRotation > 0
+}
+
+// Hash returns a keyed hash of addr that is stable within the current salt
+// interval, or "" if hashing is disabled. Intervals are aligned to multiples
+// of the rotation period, e.g. calendar days (UTC) for 24h.
+func (a *Anonymizer) Hash(addr netip.Addr) string {
+	if !a.Hashing() {
+		return ""
+	}
+	mac := hmac.New(sha256.New, a.currentSalt())
+	mac.Write(addr.Unmap().WithZone("").AsSlice())
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// currentSalt returns the salt for the current interval, replacing it when
+// a new interval has started
+func (a *Anonymizer) currentSalt() []byte {
+	window := a.now().Truncate(a.cfg.HashRotation)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.salt == nil || !window.Equal(a.saltWindow) {
+		salt := make([]byte, 32)
+		if _, err := rand.Read(salt); err != nil {
+			// crypto/rand never fails on supported platforms
+			panic(fmt.Sprintf("privacy: failed to generate salt: %v", err))
+		}
+		a.salt, a.saltWindow = salt, window
+	}
+	return a.salt
+}
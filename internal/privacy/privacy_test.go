@@ -0,0 +1,141 @@
+package privacy
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestTruncate(t *testing.T) {
+	a, err := New(Config{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	custom, err := New(Config{IPv4Prefix: 16, IPv6Prefix: 32})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		a        *Anonymizer
+		input    string
+		expected string
+	}{
+		{a: a, input: "81.2.69.142", expected: "81.2.69.0"},
+		{a: a, input: "::ffff:81.2.69.142", expected: "81.2.69.0"},
+		{a: a, input: "2a00:1450:4009:81f::200e", expected: "2a00:1450:4009::"},
+		{a: a, input: "fe80::1%eth0", expected: "fe80::"},
+		{a: a, input: "81.2.69.142:51514", expected: "81.2.69.0:51514"},
+		{a: a, input: "[2001:db8:1:2::1]:443", expected: "[2001:db8:1::]:443"},
+		{a: a, input: "not an address", expected: "not an address"},
+		{a: custom, input: "81.2.69.142", expected: "81.2.0.0"},
+		{a: custom, input: "2a00:1450:4009:81f::200e", expected: "2a00:1450::"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := tt.a.TruncateString(tt.input); got != tt.expected {
+				t.Errorf("TruncateString(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	a, _ := New(Config{})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "81.2.69.142, 10.1.2.3", expected: "81.2.69.0, 10.1.2.0"},
+		{input: `for=192.0.2.60;proto=http;by="[2001:db8:1:2::1]:8080"`, expected: `for=192.0.2.0;proto=http;by="[2001:db8:1::]:8080"`},
+		{input: "Mozilla/5.0 (X11; Linux x86_64)", expected: "Mozilla/5.0 (X11; Linux x86_64)"},
+		{input: "client:81.2.69.142", expected: "client:81.2.69.0"},
+		{input: "IP:81.2.69.142 src:81.2.69.142:443", expected: "IP:81.2.69.0 src:81.2.69.0:443"},
+		{input: "ip:2a00:1450:4009:81f::200e", expected: "ip:2a00:1450:4009::"},
+		{input: "deadbeef:81.2.69.142", expected: "deadbeef:81.2.69.0"},
+		{input: "ip_81.2.69.142 81.2.69.142_x v81.2.69.142", expected: "ip_81.2.69.0 81.2.69.0_x v81.2.69.0"},
+		{input: "id=abc81.2.69.142 81.2.69.142cafe", expected: "id=abc81.2.69.0 81.2.69.0cafe"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := a.Redact(tt.input); got != tt.expected {
+				t.Errorf("Redact(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRedactURI(t *testing.T) {
+	a, _ := New(Config{})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "/api/debug?ip=2001%3Adb8%3A1%3A2%3A%3A1", expected: "/api/debug?ip=2001:db8:1::"},
+		{input: "/api/debug?ip=81%2E2%2E69%2E142&x=%zz", expected: "/api/debug?ip=81.2.69.0&x=%zz"},
+		{input: "/api/debug?ip=2001%253Adb8%253A1%253A2%253A%253A1", expected: "/api/debug?ip=2001:db8:1::"},
+		{input: "/api/lookup/ip_81.2.69.142", expected: "/api/lookup/ip_81.2.69.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := a.RedactURI(tt.input); got != tt.expected {
+				t.Errorf("RedactURI(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHash(t *testing.T) {
+	a, _ := New(Config{HashRotation: 24 * time.Hour})
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	ip1 := netip.MustParseAddr("81.2.69.142")
+	ip2 := netip.MustParseAddr("81.2.69.143")
+
+	h1 := a.Hash(ip1)
+	if len(h1) != 32 {
+		t.Errorf("Hash() = %q, want 32 hex digits", h1)
+	}
+	if a.Hash(ip1) != h1 || a.Hash(netip.MustParseAddr("::ffff:81.2.69.142")) != h1 {
+		t.Error("Hash() is not stable within an interval")
+	}
+	if a.Hash(ip2) == h1 {
+		t.Error("Hash() is equal for different addresses")
+	}
+
+	now = now.Add(15 * time.Hour)
+	if a.Hash(ip1) == h1 {
+		t.Error("Hash() is unchanged after the salt rotated")
+	}
+
+	disabled, _ := New(Config{})
+	if disabled.Hashing() || disabled.Hash(ip1) != "" {
+		t.Error("Hash() is enabled without a rotation")
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{name: "IPv4 prefix", cfg: Config{IPv4Prefix: 33}},
+		{name: "IPv6 prefix", cfg: Config{IPv6Prefix: -1}},
+		{name: "Rotation", cfg: Config{HashRotation: -time.Hour}},
+		{name: "Debug mode", cfg: Config{Debug: "verbose"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.cfg); err == nil {
+				t.Error("New() error = nil, want error")
+			}
+		})
+	}
+}
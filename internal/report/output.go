@@ -0,0 +1,241 @@
+package report
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Output formats
+const (
+	Table = "table"
+	JSON  = "json"
+	CSV   = "csv"
+	HTML  = "html"
+)
+
+// Formats lists the supported output formats
+var Formats = []string{Table, JSON, CSV, HTML}
+
+var (
+	//go:embed report.html
+	htmlTemplate string
+	//go:embed tiles.csv
+	tilesCSV []byte
+
+	reportTemplate = template.Must(template.New("report").Parse(htmlTemplate))
+	tiles          = parseTiles(tilesCSV)
+)
+
+// mapShades is the number of colour steps on the HTML map
+const mapShades = 5
+
+// section is a titled list of groups
+type section struct {
+	Title  string
+	Key    string
+	Groups []Group
+}
+
+// Write renders r to w in the given format. Each grouping is limited to its
+// top entries (0 = all); the HTML map always shows every country.
+func Write(w io.Writer, r *Report, format string, top int) error {
+	switch format {
+	case Table:
+		return writeTable(w, r, top)
+	case JSON:
+		return writeJSON(w, r, top)
+	case CSV:
+		return writeCSV(w, r, top)
+	case HTML:
+		return writeHTML(w, r, top)
+	default:
+		return fmt.Errorf("unknown format %q (want table, json, csv or html)", format)
+	}
+}
+
+// sections returns the groupings of r in display order, limited to top
+func sections(r *Report, top int) []section {
+	limit := func(groups []Group) []Group {
+		if top > 0 && len(groups) > top {
+			return groups[:top]
+		}
+		return groups
+	}
+	return []section{
+		{Title: "Countries", Key: "country", Groups: limit(r.Countries)},
+		{Title: "Regions", Key: "region", Groups: limit(r.Regions)},
+		{Title: "Autonomous Systems", Key: "asn", Groups: limit(r.ASNs)},
+		{Title: "Networks", Key: "network", Groups: limit(r.Networks)},
+		{Title: "Network Types", Key: "network_type", Groups: limit(r.NetworkTypes)},
+	}
+}
+
+// share formats n as a percentage of total
+func share(n, total int) string {
+	if total == 0 {
+		return "0.0%"
+	}
+	return strconv.FormatFloat(100*float64(n)/float64(total), 'f', 1, 64) + "%"
+}
+
+func writeTable(w io.Writer, r *Report, top int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Lines\t%d\t\nRequests\t%d\t\nUnique IPs\t%d\t\nPrivate\t%d\t\n", r.Lines, r.Requests, r.UniqueIPs, r.Private)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	located := r.Requests - r.Private
+	for _, s := range sections(r, top) {
+		fmt.Fprintf(w, "\n%s\n", strings.ToUpper(s.Title))
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "KEY\tNAME\tREQUESTS\tUNIQUE IPS\tSHARE")
+		for _, g := range s.Groups {
+			key := g.Key
+			if key == "" {
+				key = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\n", key, g.Name, g.Requests, g.UniqueIPs, share(g.Requests, located))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%s\n", r.Attribution)
+	return err
+}
+
+func writeJSON(w io.Writer, r *Report, top int) error {
+	limited := *r
+	s := sections(r, top)
+	limited.Countries, limited.Regions, limited.ASNs, limited.Networks, limited.NetworkTypes = s[0].Groups, s[1].Groups, s[2].Groups, s[3].Groups, s[4].Groups
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&limited)
+}
+
+func writeCSV(w io.Writer, r *Report, top int) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"dimension", "key", "name", "requests", "unique_ips"})
+	for _, s := range sections(r, top) {
+		for _, g := range s.Groups {
+			cw.Write([]string{s.Key, g.Key, g.Name, strconv.Itoa(g.Requests), strconv.Itoa(g.UniqueIPs)})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// tile is a country on the HTML tile grid map
+type tile struct {
+	ISOCode string
+	Row     int
+	Col     int
+}
+
+// mapTile is a tile as rendered, with its corner and centre in pixels and
+// its shade (0 for no traffic, 1 to mapShades otherwise)
+type mapTile struct {
+	ISOCode  string
+	Name     string
+	X, Y     int
+	CX, CY   int
+	Requests int
+	Shade    int
+}
+
+// tileSize is the distance between map tiles in pixels, tileGap the space
+// between them
+const (
+	tileSize = 30
+	tileGap  = 2
+)
+
+// parseTiles parses the embedded tile grid layout
+func parseTiles(data []byte) []tile {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		// The layout is embedded, so this can only fail at build time
+		panic(fmt.Sprintf("report: invalid tile layout: %v", err))
+	}
+
+	result := make([]tile, 0, len(records))
+	for _, rec := range records[1:] {
+		row, errRow := strconv.Atoi(rec[1])
+		col, errCol := strconv.Atoi(rec[2])
+		if errRow != nil || errCol != nil {
+			panic(fmt.Sprintf("report: invalid tile position for %s", rec[0]))
+		}
+		result = append(result, tile{ISOCode: rec[0], Row: row, Col: col})
+	}
+	return result
+}
+
+// mapTiles lays out the tile grid with each country shaded by its requests
+// on a logarithmic scale
+func mapTiles(countries []Group) (result []mapTile, width, height int) {
+	byCode := make(map[string]Group, len(countries))
+	peak := 0
+	for _, g := range countries {
+		byCode[g.Key] = g
+		peak = max(peak, g.Requests)
+	}
+
+	for _, t := range tiles {
+		g := byCode[t.ISOCode]
+		mt := mapTile{
+			ISOCode:  t.ISOCode,
+			Name:     g.Name,
+			X:        t.Col * tileSize,
+			Y:        t.Row * tileSize,
+			CX:       t.Col*tileSize + (tileSize-tileGap)/2,
+			CY:       t.Row*tileSize + (tileSize-tileGap)/2,
+			Requests: g.Requests,
+		}
+		if mt.Name == "" {
+			mt.Name = t.ISOCode
+		}
+		if g.Requests > 0 {
+			mt.Shade = 1
+			if peak > 1 {
+				mt.Shade += int(math.Log(float64(g.Requests)) / math.Log(float64(peak)) * (mapShades - 1))
+			}
+		}
+		result = append(result, mt)
+		width = max(width, mt.X+tileSize)
+		height = max(height, mt.Y+tileSize)
+	}
+	return result, width, height
+}
+
+func writeHTML(w io.Writer, r *Report, top int) error {
+	shades := make([]int, mapShades)
+	for i := range shades {
+		shades[i] = i + 1
+	}
+
+	tiles, width, height := mapTiles(r.Countries)
+	located := r.Requests - r.Private
+	return reportTemplate.Execute(w, map[string]any{
+		"Report":    r,
+		"Generated": time.Now().UTC().Format(time.RFC1123),
+		"Sections":  sections(r, top),
+		"Tiles":     tiles,
+		"Width":     width,
+		"Height":    height,
+		"TileSize":  tileSize - tileGap,
+		"Shades":    shades,
+		"Share":     func(n int) string { return share(n, located) },
+	})
+}
@@ -0,0 +1,259 @@
+// Package report aggregates traffic by country, region, AS, network and
+// network type.
+//
+// Input is read line by line: a line is either a bare IP address or a log
+// line, in which case the first address on the line is counted.
+package report
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/jcjc-dev/ipwhere/internal/extract"
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+	"github.com/jcjc-dev/ipwhere/internal/netclass"
+)
+
+// Group is the traffic attributed to one country, region, AS, network or
+// network type. Key is empty for public addresses the databases have no data
+// for. Networks are the /24 (IPv4) or /48 (IPv6) an address belongs to.
+type Group struct {
+	Key       string `json:"key"`
+	Name      string `json:"name,omitempty"`
+	Requests  int    `json:"requests"`
+	UniqueIPs int    `json:"unique_ips"`
+}
+
+// Report summarises the traffic in a log. Requests counts the lines with an
+// address; Private counts those from private and reserved addresses, which
+// are not geolocated or grouped. Groups are sorted by requests, largest first.
+type Report struct {
+	Lines        int     `json:"lines"`
+	Requests     int     `json:"requests"`
+	UniqueIPs    int     `json:"unique_ips"`
+	Private      int     `json:"private"`
+	Countries    []Group `json:"countries"`
+	Regions      []Group `json:"regions"`
+	ASNs         []Group `json:"asns"`
+	Networks     []Group `json:"networks"`
+	NetworkTypes []Group `json:"network_types"`
+	Attribution  string  `json:"attribution"`
+}
+
+// dimension indexes the groupings of a Report
+type dimension int
+
+const (
+	byCountry dimension = iota
+	byRegion
+	byASN
+	byNetwork
+	byNetworkType
+	dimensions
+)
+
+// Aggregator builds a Report from individual addresses
+type Aggregator struct {
+	reader  geo.ReaderInterface
+	lines   int
+	total   int
+	private int
+	// seen maps each address to its groups, nil for private addresses
+	seen   map[netip.Addr]*[dimensions]*Group
+	groups [dimensions]map[string]*Group
+}
+
+// NewAggregator returns an empty Aggregator that geolocates with reader
+func NewAggregator(reader geo.ReaderInterface) *Aggregator {
+	a := &Aggregator{reader: reader, seen: make(map[netip.Addr]*[dimensions]*Group)}
+	for d := range a.groups {
+		a.groups[d] = make(map[string]*Group)
+	}
+	return a
+}
+
+// Build reads lines from in and aggregates the address found on each
+func Build(ctx context.Context, reader geo.ReaderInterface, in io.Reader) (*Report, error) {
+	a := NewAggregator(reader)
+	br := bufio.NewReaderSize(in, 64*1024)
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 {
+			if addErr := a.AddLine(ctx, line); addErr != nil {
+				return nil, addErr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a.Report(), nil
+}
+
+// AddLine counts the first address on line. Lines without one only count
+// towards Lines.
+func (a *Aggregator) AddLine(ctx context.Context, line string) error {
+	a.lines++
+	addr, ok := lineAddr(line)
+	if !ok {
+		return nil
+	}
+	return a.add(ctx, addr)
+}
+
+// Add counts one request from addr
+func (a *Aggregator) Add(ctx context.Context, addr netip.Addr) error {
+	a.lines++
+	return a.add(ctx, addr)
+}
+
+func (a *Aggregator) add(ctx context.Context, addr netip.Addr) error {
+	addr = addr.Unmap()
+	a.total++
+
+	groups, ok := a.seen[addr]
+	if !ok {
+		var err error
+		if groups, err = a.classify(ctx, addr); err != nil {
+			return err
+		}
+		a.seen[addr] = groups
+		if groups != nil {
+			for _, g := range groups {
+				g.UniqueIPs++
+			}
+		}
+	}
+	if groups == nil {
+		a.private++
+		return nil
+	}
+	for _, g := range groups {
+		g.Requests++
+	}
+	return nil
+}
+
+// classify geolocates addr and returns the groups it belongs to
+func (a *Aggregator) classify(ctx context.Context, addr netip.Addr) (*[dimensions]*Group, error) {
+	if !netclass.IsPublic(addr) {
+		return nil, nil
+	}
+	info, err := a.reader.Lookup(ctx, addr.AsSlice())
+	if err != nil {
+		return nil, fmt.Errorf("lookup %s: %w", addr, err)
+	}
+
+	var groups [dimensions]*Group
+	groups[byCountry] = a.group(byCountry, info.ISOCode, info.Country)
+	if info.Region != "" {
+		groups[byRegion] = a.group(byRegion, info.ISOCode+"/"+info.Region, info.Region+", "+info.Country)
+	} else {
+		groups[byRegion] = a.group(byRegion, "", "")
+	}
+	if info.ASN != nil {
+		groups[byASN] = a.group(byASN, fmt.Sprintf("AS%d", *info.ASN), info.Organization)
+	} else {
+		groups[byASN] = a.group(byASN, "", "")
+	}
+	groups[byNetwork] = a.group(byNetwork, networkOf(addr).String(), info.Organization)
+	groups[byNetworkType] = a.group(byNetworkType, info.NetworkType, "")
+	return &groups, nil
+}
+
+// Prefix lengths addresses are grouped by in the networks grouping
+const (
+	networkIPv4Bits = 24
+	networkIPv6Bits = 48
+)
+
+// networkOf returns the /24 or /48 network of addr
+func networkOf(addr netip.Addr) netip.Prefix {
+	bits := networkIPv6Bits
+	if addr.Is4() {
+		bits = networkIPv4Bits
+	}
+	p, _ := addr.Prefix(bits)
+	return p
+}
+
+// group returns the group for key, creating it if needed
+func (a *Aggregator) group(d dimension, key, name string) *Group {
+	g, ok := a.groups[d][key]
+	if !ok {
+		if key == "" {
+			name = "Unknown"
+		}
+		g = &Group{Key: key, Name: name}
+		a.groups[d][key] = g
+	}
+	return g
+}
+
+// Report returns the aggregated report
+func (a *Aggregator) Report() *Report {
+	r := &Report{
+		Lines:       a.lines,
+		Requests:    a.total,
+		UniqueIPs:   len(a.seen),
+		Private:     a.private,
+		Attribution: geo.Attribution,
+	}
+
+	r.Countries = sorted(a.groups[byCountry])
+	r.Regions = sorted(a.groups[byRegion])
+	r.ASNs = sorted(a.groups[byASN])
+	r.Networks = sorted(a.groups[byNetwork])
+	r.NetworkTypes = sorted(a.groups[byNetworkType])
+	return r
+}
+
+// sorted returns the groups by requests, then unique addresses, largest
+// first. Ties are broken by key, with the unknown group last.
+func sorted(groups map[string]*Group) []Group {
+	result := make([]Group, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		if a.UniqueIPs != b.UniqueIPs {
+			return a.UniqueIPs > b.UniqueIPs
+		}
+		if (a.Key == "") != (b.Key == "") {
+			return b.Key == ""
+		}
+		return a.Key < b.Key
+	})
+	return result
+}
+
+// lineAddr returns the address a line is about: the line itself if it is a
+// bare address, its first field for access logs, or else the first address
+// anywhere on the line
+func lineAddr(line string) (netip.Addr, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return netip.Addr{}, false
+	}
+	first, _, _ := strings.Cut(line, " ")
+	if addr, err := netip.ParseAddr(first); err == nil {
+		return addr, true
+	}
+	if matches := extract.Find(line); len(matches) > 0 {
+		return matches[0].Addr, true
+	}
+	return netip.Addr{}, false
+}
@@ -0,0 +1,286 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/jcjc-dev/ipwhere/internal/country"
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+	"github.com/jcjc-dev/ipwhere/internal/geo/geotest"
+)
+
+// testReader knows Google and Andrews & Arnold addresses
+func testReader() *geotest.Reader {
+	return &geotest.Reader{
+		Networks: map[string]geo.IPInfo{
+			"8.8.0.0/16": {
+				ISOCode: "US", Country: "United States", Region: "California",
+				ASN: geotest.ASN(15169), Organization: "Google LLC", NetworkType: "hosting",
+			},
+			"81.2.69.142": {
+				ISOCode: "GB", Country: "United Kingdom", Region: "England",
+				ASN: geotest.ASN(20712), Organization: "Andrews & Arnold <Ltd>", NetworkType: "isp",
+			},
+		},
+	}
+}
+
+const testLog = `8.8.8.8 - - [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.1" 200 12 "-" "curl"
+8.8.8.8 - - [10/Oct/2000:13:55:37 -0700] "GET / HTTP/1.1" 200 12 "-" "curl"
+8.8.4.4
+{"ts": 1, "client": "81.2.69.142"}
+192.168.1.1
+1.1.1.1 - - [10/Oct/2000:13:55:38 -0700] "GET / HTTP/1.1" 404 0 "-" "curl"
+
+no address here
+`
+
+func buildTestReport(t *testing.T) *Report {
+	t.Helper()
+	r, err := Build(context.Background(), testReader(), strings.NewReader(testLog))
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	return r
+}
+
+func TestBuild(t *testing.T) {
+	r := buildTestReport(t)
+
+	if r.Lines != 8 || r.Requests != 6 || r.UniqueIPs != 5 || r.Private != 1 {
+		t.Errorf("totals = lines %d, requests %d, unique %d, private %d; want 8, 6, 5, 1", r.Lines, r.Requests, r.UniqueIPs, r.Private)
+	}
+
+	tests := []struct {
+		name     string
+		groups   []Group
+		expected []Group
+	}{
+		{
+			name:   "Countries",
+			groups: r.Countries,
+			expected: []Group{
+				{Key: "US", Name: "United States", Requests: 3, UniqueIPs: 2},
+				{Key: "GB", Name: "United Kingdom", Requests: 1, UniqueIPs: 1},
+				{Key: "", Name: "Unknown", Requests: 1, UniqueIPs: 1},
+			},
+		},
+		{
+			name:   "Regions",
+			groups: r.Regions,
+			expected: []Group{
+				{Key: "US/California", Name: "California, United States", Requests: 3, UniqueIPs: 2},
+				{Key: "GB/England", Name: "England, United Kingdom", Requests: 1, UniqueIPs: 1},
+				{Key: "", Name: "Unknown", Requests: 1, UniqueIPs: 1},
+			},
+		},
+		{
+			name:   "ASNs",
+			groups: r.ASNs,
+			expected: []Group{
+				{Key: "AS15169", Name: "Google LLC", Requests: 3, UniqueIPs: 2},
+				{Key: "AS20712", Name: "Andrews & Arnold <Ltd>", Requests: 1, UniqueIPs: 1},
+				{Key: "", Name: "Unknown", Requests: 1, UniqueIPs: 1},
+			},
+		},
+		{
+			name:   "Networks",
+			groups: r.Networks,
+			expected: []Group{
+				{Key: "8.8.8.0/24", Name: "Google LLC", Requests: 2, UniqueIPs: 1},
+				{Key: "1.1.1.0/24", Requests: 1, UniqueIPs: 1},
+				{Key: "8.8.4.0/24", Name: "Google LLC", Requests: 1, UniqueIPs: 1},
+				{Key: "81.2.69.0/24", Name: "Andrews & Arnold <Ltd>", Requests: 1, UniqueIPs: 1},
+			},
+		},
+		{
+			name:   "Network types",
+			groups: r.NetworkTypes,
+			expected: []Group{
+				{Key: "hosting", Requests: 3, UniqueIPs: 2},
+				{Key: "isp", Requests: 1, UniqueIPs: 1},
+				{Key: "", Name: "Unknown", Requests: 1, UniqueIPs: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.groups) != len(tt.expected) {
+				t.Fatalf("got %d groups, want %d: %+v", len(tt.groups), len(tt.expected), tt.groups)
+			}
+			for i := range tt.expected {
+				if tt.groups[i] != tt.expected[i] {
+					t.Errorf("group %d = %+v, want %+v", i, tt.groups[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNetworks(t *testing.T) {
+	a := NewAggregator(testReader())
+	for _, ip := range []string{"2a00:1450:4009:81f::200e", "2a00:1450:4009:1::1", "2a00:1450:400a::1", "::ffff:8.8.8.8"} {
+		if err := a.Add(context.Background(), netip.MustParseAddr(ip)); err != nil {
+			t.Fatalf("Add(%s) error = %v", ip, err)
+		}
+	}
+
+	expected := []Group{
+		{Key: "2a00:1450:4009::/48", Requests: 2, UniqueIPs: 2},
+		{Key: "2a00:1450:400a::/48", Requests: 1, UniqueIPs: 1},
+		{Key: "8.8.8.0/24", Name: "Google LLC", Requests: 1, UniqueIPs: 1},
+	}
+	got := a.Report().Networks
+	if len(got) != len(expected) {
+		t.Fatalf("got %d networks, want %d: %+v", len(got), len(expected), got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("network %d = %+v, want %+v", i, got[i], expected[i])
+		}
+	}
+}
+
+func TestLineAddr(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{line: "8.8.8.8\n", expected: "8.8.8.8"},
+		{line: "  2001:db8::1  ", expected: "2001:db8::1"},
+		{line: `10.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /?ip=1.2.3.4 HTTP/1.1" 200 1`, expected: "10.0.0.1"},
+		{line: `{"ip":"81.2.69.142","via":"10.0.0.1"}`, expected: "81.2.69.142"},
+		{line: "Oct 10 sshd: Failed password from 45[.]33[.]32[.]156", expected: "45.33.32.156"},
+		{line: "no address here"},
+		{line: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			addr, ok := lineAddr(tt.line)
+			if tt.expected == "" {
+				if ok {
+					t.Errorf("lineAddr() = %s, want none", addr)
+				}
+				return
+			}
+			if !ok || addr.String() != tt.expected {
+				t.Errorf("lineAddr() = %s, %v; want %s", addr, ok, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	r := buildTestReport(t)
+
+	tests := []struct {
+		format   string
+		contains []string
+		excludes []string
+	}{
+		{
+			format:   Table,
+			contains: []string{"COUNTRIES", "KEY  NAME", "US   United States  3         2           60.0%", "AUTONOMOUS SYSTEMS", "NETWORKS", "8.8.8.0/24", r.Attribution},
+			excludes: []string{"GB"},
+		},
+		{
+			format:   CSV,
+			contains: []string{"dimension,key,name,requests,unique_ips\n", "country,US,United States,3,2\n", "asn,AS15169,Google LLC,3,2\n", "network,8.8.8.0/24,Google LLC,2,1\n", "network_type,hosting,,3,2\n"},
+			excludes: []string{"GB"},
+		},
+		{
+			format:   HTML,
+			contains: []string{"<svg", `class="shade-5"`, "<title>United States: 3 requests</title>", "<title>United Kingdom: 1 requests</title>", "Andrews &amp; Arnold &lt;Ltd&gt;", "60.0%", "81.2.69.0/24"},
+			excludes: []string{"Andrews & Arnold <Ltd>", "<script", "<link", "src="},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			top := 1
+			if tt.format == HTML {
+				top = 0
+			}
+			var buf bytes.Buffer
+			if err := Write(&buf, r, tt.format, top); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			out := buf.String()
+			for _, s := range tt.contains {
+				if !strings.Contains(out, s) {
+					t.Errorf("output does not contain %q:\n%s", s, out)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(out, s) {
+					t.Errorf("output contains %q", s)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	r := buildTestReport(t)
+
+	var buf bytes.Buffer
+	if err := Write(&buf, r, JSON, 2); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	var got Report
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got.Requests != 6 || len(got.Countries) != 2 || len(got.ASNs) != 2 || len(got.Networks) != 2 || got.Countries[0].Key != "US" {
+		t.Errorf("got %+v", got)
+	}
+	if len(r.Countries) != 3 {
+		t.Error("Write() modified the report")
+	}
+
+	if err := Write(&buf, r, "xml", 0); err == nil {
+		t.Error("Write() with unknown format error = nil")
+	}
+}
+
+func TestTiles(t *testing.T) {
+	positions := make(map[[2]int]string)
+	for _, tile := range tiles {
+		if _, ok := country.Lookup(tile.ISOCode); !ok {
+			t.Errorf("unknown country %s", tile.ISOCode)
+		}
+		pos := [2]int{tile.Row, tile.Col}
+		if other, ok := positions[pos]; ok {
+			t.Errorf("%s and %s share tile %v", tile.ISOCode, other, pos)
+		}
+		positions[pos] = tile.ISOCode
+	}
+	if len(tiles) < 150 {
+		t.Errorf("got %d tiles, want at least 150", len(tiles))
+	}
+}
+
+func TestMapTiles(t *testing.T) {
+	got, width, height := mapTiles([]Group{
+		{Key: "US", Name: "United States", Requests: 1000},
+		{Key: "GB", Name: "United Kingdom", Requests: 1},
+		{Key: "", Name: "Unknown", Requests: 5},
+	})
+	if width == 0 || height == 0 {
+		t.Errorf("size = %dx%d", width, height)
+	}
+
+	shades := make(map[string]int)
+	for _, tile := range got {
+		shades[tile.ISOCode] = tile.Shade
+	}
+	if shades["US"] != mapShades || shades["GB"] != 1 || shades["FR"] != 0 {
+		t.Errorf("shades US %d, GB %d, FR %d; want %d, 1, 0", shades["US"], shades["GB"], shades["FR"], mapShades)
+	}
+}
@@ -0,0 +1,196 @@
+// Package tlsconfig builds the server TLS configuration from certificate
+// files, reloading the certificate when the files change so that renewed
+// certificates are picked up without a restart.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config holds the TLS settings for the server
+type Config struct {
+	CertFile     string
+	KeyFile      string
+	MinVersion   string   // "1.2" or "1.3" (default 1.2)
+	CipherSuites []string // TLS 1.2 cipher suite names (default: Go's secure defaults)
+	ClientCAFile string   // CA bundle for verifying client certificates (optional)
+}
+
+// New creates a TLS configuration for cfg along with the certificate it
+// serves. If a client CA file is set, clients may present a certificate,
+// which is then verified against it; requiring one is left to the routes
+// that need it.
+func New(cfg Config) (*tls.Config, *Certificate, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, nil, errors.New("both a certificate and a key file are required")
+	}
+	minVersion, err := ParseVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	ciphers, err := ParseCipherSuites(cfg.CipherSuites)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := LoadCertificate(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   ciphers,
+		GetCertificate: cert.GetCertificate,
+	}
+	if cfg.ClientCAFile != "" {
+		pool, err := loadCertPool(cfg.ClientCAFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsCfg, cert, nil
+}
+
+// ParseVersion parses a minimum TLS version ("1.2" or "1.3", default 1.2)
+func ParseVersion(s string) (uint16, error) {
+	switch strings.TrimPrefix(strings.ToLower(s), "tls") {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version %q (want 1.2 or 1.3)", s)
+	}
+}
+
+// ParseCipherSuites maps cipher suite names, as listed by tls.CipherSuites,
+// to their IDs. Insecure suites are rejected. Go does not allow configuring
+// TLS 1.3 suites, so these only apply to TLS 1.2 connections.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	known := make(map[string]uint16)
+	for _, s := range tls.CipherSuites() {
+		known[s.Name] = s.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[strings.ToUpper(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Certificate is a key pair loaded from files
+type Certificate struct {
+	certFile string
+	keyFile  string
+
+	mu          sync.RWMutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+// LoadCertificate reads a PEM certificate (chain) and its private key
+func LoadCertificate(certFile, keyFile string) (*Certificate, error) {
+	c := &Certificate{certFile: certFile, keyFile: keyFile}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reload re-reads the certificate and key if either changed on disk.
+// On failure, e.g. while only one of the files has been replaced, the
+// previously loaded certificate stays in use.
+func (c *Certificate) Reload() error {
+	certModTime, err := modTime(c.certFile)
+	if err != nil {
+		return fmt.Errorf("failed to stat certificate: %w", err)
+	}
+	keyModTime, err := modTime(c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to stat key: %w", err)
+	}
+
+	c.mu.RLock()
+	unchanged := certModTime.Equal(c.certModTime) && keyModTime.Equal(c.keyModTime)
+	c.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	return c.load()
+}
+
+func (c *Certificate) load() error {
+	certModTime, err := modTime(c.certFile)
+	if err != nil {
+		return fmt.Errorf("failed to read certificate: %w", err)
+	}
+	keyModTime, err := modTime(c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read key: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	c.mu.Lock()
+	c.cert = &cert
+	c.certModTime = certModTime
+	c.keyModTime = keyModTime
+	c.mu.Unlock()
+	return nil
+}
+
+// GetCertificate returns the current certificate, for use as
+// tls.Config.GetCertificate
+func (c *Certificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// NotAfter returns the expiry time of the current leaf certificate
+func (c *Certificate) NotAfter() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert.Leaf.NotAfter
+}
+
+// loadCertPool reads the PEM certificates in path
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in client CA file %s", path)
+	}
+	return pool, nil
+}
+
+func modTime(path string) (time.Time, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return st.ModTime(), nil
+}
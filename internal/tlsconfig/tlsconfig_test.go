@@ -0,0 +1,285 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert is a generated certificate and key
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+// newTestCert creates a certificate for cn, self-signed if parent is nil
+func newTestCert(t *testing.T, cn string, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// write stores the certificate and key as PEM files in dir
+func (c *testCert) write(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, c.pem, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    uint16
+		wantErr bool
+	}{
+		{"", tls.VersionTLS12, false},
+		{"1.2", tls.VersionTLS12, false},
+		{"1.3", tls.VersionTLS13, false},
+		{"TLS1.3", tls.VersionTLS13, false},
+		{"1.1", 0, true},
+		{"1.0", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseVersion(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseVersion(%q) = %x, want %x", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	tests := []struct {
+		input   []string
+		want    []uint16
+		wantErr bool
+	}{
+		{nil, nil, false},
+		{[]string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, false},
+		{[]string{" tls_ecdhe_rsa_with_chacha20_poly1305_sha256 ", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"}, []uint16{tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}, false},
+		{[]string{"TLS_RSA_WITH_RC4_128_SHA"}, nil, true}, // insecure
+		{[]string{"nope"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCipherSuites(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCipherSuites(%v) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseCipherSuites(%v) = %v, want %v", tt.input, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("ParseCipherSuites(%v) = %v, want %v", tt.input, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	first := newTestCert(t, "first.example", nil)
+	certFile, keyFile := first.write(t, dir)
+
+	cert, err := LoadCertificate(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("LoadCertificate failed: %v", err)
+	}
+	current := func() string {
+		c, _ := cert.GetCertificate(nil)
+		return c.Leaf.Subject.CommonName
+	}
+	if got := current(); got != "first.example" {
+		t.Fatalf("expected first.example, got %s", got)
+	}
+
+	// Unchanged files are not reloaded
+	if err := cert.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	// A certificate without its matching key keeps the previous pair in use
+	second := newTestCert(t, "second.example", nil)
+	future := time.Now().Add(time.Minute)
+	if err := os.WriteFile(certFile, second.pem, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(certFile, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if err := cert.Reload(); err == nil {
+		t.Error("expected reload of a mismatched key pair to fail")
+	}
+	if got := current(); got != "first.example" {
+		t.Errorf("expected first.example after a failed reload, got %s", got)
+	}
+
+	// Once both files are replaced the new certificate is served
+	second.write(t, dir)
+	future = future.Add(time.Minute)
+	for _, f := range []string{certFile, keyFile} {
+		if err := os.Chtimes(f, future, future); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cert.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got := current(); got != "second.example" {
+		t.Errorf("expected second.example, got %s", got)
+	}
+	if !cert.NotAfter().Equal(second.cert.NotAfter) {
+		t.Errorf("NotAfter = %v, want %v", cert.NotAfter(), second.cert.NotAfter)
+	}
+}
+
+func TestNewClientCA(t *testing.T) {
+	dir := t.TempDir()
+	server := newTestCert(t, "localhost", nil)
+	certFile, keyFile := server.write(t, dir)
+
+	ca := newTestCert(t, "Test CA", nil)
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, ca.pem, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tlsCfg, _, err := New(Config{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.3", ClientCAFile: caFile})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.VerifiedChains) > 0 {
+			w.Write([]byte(r.TLS.VerifiedChains[0][0].Subject.CommonName))
+		}
+	}))
+	srv.TLS = tlsCfg
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.cert)
+	get := func(cert *tls.Certificate) (string, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:    roots,
+			ServerName: "localhost",
+			// Always send the certificate, even if the server does not list its issuer
+			GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				if cert == nil {
+					return &tls.Certificate{}, nil
+				}
+				return cert, nil
+			},
+		}}}
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	// Client certificates are optional at the TLS layer
+	if got, err := get(nil); err != nil || got != "" {
+		t.Errorf("without client certificate: got %q, %v", got, err)
+	}
+
+	// A certificate issued by the client CA is verified
+	client := newTestCert(t, "admin", ca).tlsCertificate()
+	if got, err := get(&client); err != nil || got != "admin" {
+		t.Errorf("with client certificate: got %q, %v", got, err)
+	}
+
+	// Certificates from other issuers are rejected during the handshake
+	other := newTestCert(t, "other", nil).tlsCertificate()
+	if _, err := get(&other); err == nil {
+		t.Error("expected certificate from an unknown CA to be rejected")
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := newTestCert(t, "localhost", nil).write(t, dir)
+
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"missing key", Config{CertFile: certFile}},
+		{"missing files", Config{CertFile: filepath.Join(dir, "nope.pem"), KeyFile: keyFile}},
+		{"bad version", Config{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.1"}},
+		{"bad cipher", Config{CertFile: certFile, KeyFile: keyFile, CipherSuites: []string{"nope"}}},
+		{"bad client CA", Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile}},
+	}
+
+	for _, tt := range tests {
+		if _, _, err := New(tt.cfg); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+// Package client is a Go client for the ipwhere HTTP API.
+//
+//	c, err := client.New("http://localhost:8080",
+//		client.WithRetries(3),
+//		client.WithTimeout(2*time.Second),
+//		client.WithCache(1024, 10*time.Minute))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	info, err := c.Lookup(ctx, "8.8.8.8")
+//
+// Response types are declared in this package and only depend on the JSON
+// API, so using the client does not pull in the server's dependencies.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultConcurrency = 8
+	defaultBackoff     = 100 * time.Millisecond
+	defaultMaxBackoff  = 5 * time.Second
+	// maxResponseBytes limits the size of a response body
+	maxResponseBytes = 1 << 20
+)
+
+// APIError is returned for non-2xx responses
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("ipwhere: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("ipwhere: %d %s", e.StatusCode, e.Message)
+}
+
+// Client calls the ipwhere API. It is safe for concurrent use.
+type Client struct {
+	baseURL     *url.URL
+	httpClient  *http.Client
+	retries     int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	timeout     time.Duration
+	concurrency int
+	cache       *cache
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the underlying HTTP client
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithRetries retries failed requests up to n times. Network errors, 429 and
+// 5xx responses are retried; other errors are returned immediately.
+func WithRetries(n int) Option {
+	return func(c *Client) {
+		c.retries = n
+	}
+}
+
+// WithBackoff sets the delay before the first retry and the upper bound for
+// later ones. Delays double after each attempt and include random jitter.
+func WithBackoff(initial, max time.Duration) Option {
+	return func(c *Client) {
+		c.backoff = initial
+		c.maxBackoff = max
+	}
+}
+
+// WithTimeout limits the duration of each attempt
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithConcurrency limits the number of parallel requests of LookupBatch
+func WithConcurrency(n int) Option {
+	return func(c *Client) {
+		c.concurrency = n
+	}
+}
+
+// WithCache caches up to size lookup responses for ttl. Lookups of the
+// caller's own IP are never cached.
+func WithCache(size int, ttl time.Duration) Option {
+	return func(c *Client) {
+		if size > 0 && ttl > 0 {
+			c.cache = newCache(size, ttl)
+		}
+	}
+}
+
+// New creates a client for the server at baseURL
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", baseURL)
+	}
+	c := &Client{
+		baseURL:     u,
+		httpClient:  http.DefaultClient,
+		backoff:     defaultBackoff,
+		maxBackoff:  defaultMaxBackoff,
+		concurrency: defaultConcurrency,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.concurrency < 1 {
+		c.concurrency = 1
+	}
+	return c, nil
+}
+
+// LookupOption adds query parameters to a lookup
+type LookupOption func(url.Values)
+
+// AsOf looks up against the newest database built on or before date
+func AsOf(date time.Time) LookupOption {
+	return func(q url.Values) {
+		q.Set("as_of", date.Format(time.DateOnly))
+	}
+}
+
+// At computes the local time fields at the given instant
+func At(t time.Time) LookupOption {
+	return func(q url.Values) {
+		q.Set("at", t.Format(time.RFC3339))
+	}
+}
+
+// Lookup returns geolocation data for ip. An empty ip looks up the caller's
+// own address as seen by the server.
+func (c *Client) Lookup(ctx context.Context, ip string, opts ...LookupOption) (*IPInfo, error) {
+	var info IPInfo
+	if err := c.lookup(ctx, ip, nil, opts, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// LookupFields returns only the given fields, keyed by their JSON names. It
+// accepts every field of the return parameter, including country reference
+// fields that are not part of IPInfo.
+func (c *Client) LookupFields(ctx context.Context, ip string, fields []string, opts ...LookupOption) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	if err := c.lookup(ctx, ip, fields, opts, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *Client) lookup(ctx context.Context, ip string, fields []string, opts []LookupOption, v interface{}) error {
+	q := url.Values{}
+	if ip != "" {
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid IP address %q", ip)
+		}
+		q.Set("ip", ip)
+	}
+	for _, f := range fields {
+		q.Add("return", f)
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	// Encode sorts by key, so equal queries share a cache entry
+	key := q.Encode()
+	cacheable := c.cache != nil && ip != "" && q.Get("at") == ""
+	if cacheable {
+		if body, ok := c.cache.get(key); ok {
+			return json.Unmarshal(body, v)
+		}
+	}
+
+	body, err := c.get(ctx, "/api/ip", q)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	if cacheable {
+		c.cache.put(key, body)
+	}
+	return nil
+}
+
+// BatchResult is the outcome of one lookup of a batch
+type BatchResult struct {
+	IP   string
+	Info *IPInfo
+	Err  error
+}
+
+// LookupBatch looks up several addresses in parallel. Results are returned in
+// the order of ips; failed lookups carry their error.
+func (c *Client) LookupBatch(ctx context.Context, ips []string, opts ...LookupOption) []BatchResult {
+	results := make([]BatchResult, len(ips))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(c.concurrency, len(ips)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				info, err := c.Lookup(ctx, ips[i], opts...)
+				results[i] = BatchResult{IP: ips[i], Info: info, Err: err}
+			}
+		}()
+	}
+
+	for i, ip := range ips {
+		if ctx.Err() != nil {
+			results[i] = BatchResult{IP: ip, Err: ctx.Err()}
+			continue
+		}
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			results[i] = BatchResult{IP: ip, Err: ctx.Err()}
+		}
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+// Features returns the server's feature flags
+func (c *Client) Features(ctx context.Context) (*FeaturesResponse, error) {
+	body, err := c.get(ctx, "/api/features", nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp FeaturesResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return &resp, nil
+}
+
+// get performs a GET request with retries and returns the response body
+func (c *Client) get(ctx context.Context, path string, q url.Values) ([]byte, error) {
+	u := c.baseURL.JoinPath(path)
+	u.RawQuery = q.Encode()
+
+	delay := c.backoff
+	for attempt := 0; ; attempt++ {
+		body, retryAfter, err := c.do(ctx, u.String())
+		if err == nil || attempt >= c.retries || !retryable(err) {
+			return body, err
+		}
+
+		wait := jitter(delay)
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		delay = min(delay*2, c.maxBackoff)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// do performs a single attempt, returning the Retry-After delay of the
+// response if any
+func (c *Client) do(ctx context.Context, u string) ([]byte, time.Duration, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		var errResp ErrorResponse
+		if json.Unmarshal(body, &errResp) == nil {
+			apiErr.Message = errResp.Error
+		}
+		return nil, retryAfter(resp.Header.Get("Retry-After")), apiErr
+	}
+	return body, 0, nil
+}
+
+// retryable reports whether a failed attempt may succeed when repeated
+func retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	// The caller's context is checked separately; a per-attempt timeout is retryable
+	return !errors.Is(err, context.Canceled)
+}
+
+// retryAfter parses a Retry-After header given in seconds
+func retryAfter(v string) time.Duration {
+	secs, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// jitter returns a random delay between d/2 and d
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
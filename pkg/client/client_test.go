@@ -0,0 +1,316 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jcjc-dev/ipwhere/internal/api"
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+	"github.com/jcjc-dev/ipwhere/internal/lists"
+)
+
+// mockReader implements geo.ReaderInterface and counts lookups
+type mockReader struct {
+	lookups atomic.Int32
+}
+
+func (m *mockReader) Lookup(ctx context.Context, ip net.IP) (*geo.IPInfo, error) {
+	m.lookups.Add(1)
+	asn := uint(15169)
+	return &geo.IPInfo{
+		IP:           ip.String(),
+		Country:      "United States",
+		ISOCode:      "US",
+		Timezone:     "America/Los_Angeles",
+		ASN:          &asn,
+		Organization: "Google LLC",
+		Attribution:  geo.Attribution,
+	}, nil
+}
+
+func (m *mockReader) LookupAsOf(ctx context.Context, ip net.IP, asOf time.Time) (*geo.IPInfo, error) {
+	if asOf.Before(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		return nil, geo.ErrNoDatabaseForDate
+	}
+	info, _ := m.Lookup(ctx, ip)
+	info.DatabaseBuild = "2024-01-01"
+	return info, nil
+}
+
+func (m *mockReader) LookupHost(ctx context.Context, host string, opts geo.HostOptions) (*geo.HostInfo, error) {
+	return nil, geo.ErrHostNotFound
+}
+
+func (m *mockReader) Metadata() *geo.Metadata     { return &geo.Metadata{} }
+func (m *mockReader) Close() error                { return nil }
+func (m *mockReader) OnlineFeaturesEnabled() bool { return false }
+
+// newTestServer serves the real API handler, optionally wrapped
+func newTestServer(t *testing.T, wrap func(http.Handler) http.Handler) (*httptest.Server, *mockReader) {
+	t.Helper()
+	reader := &mockReader{}
+	r := chi.NewRouter()
+	api.NewHandler(reader, true).SetupRoutes(r)
+
+	var h http.Handler = r
+	if wrap != nil {
+		h = wrap(h)
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv, reader
+}
+
+func TestLookup(t *testing.T) {
+	srv, _ := newTestServer(t, nil)
+	c, err := New(srv.URL)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ctx := context.Background()
+
+	info, err := c.Lookup(ctx, "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if info.IP != "8.8.8.8" || info.ISOCode != "US" || info.ASN == nil || *info.ASN != 15169 {
+		t.Errorf("unexpected lookup result: %+v", info)
+	}
+
+	info, err = c.Lookup(ctx, "8.8.8.8", AsOf(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatalf("Lookup with AsOf failed: %v", err)
+	}
+	if info.DatabaseBuild != "2024-01-01" {
+		t.Errorf("expected database_build 2024-01-01, got %q", info.DatabaseBuild)
+	}
+
+	_, err = c.Lookup(ctx, "8.8.8.8", AsOf(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)))
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Message == "" {
+		t.Errorf("expected a 404 APIError with message, got %v", err)
+	}
+
+	if _, err := c.Lookup(ctx, "not-an-ip"); err == nil {
+		t.Error("expected an invalid IP to fail")
+	}
+}
+
+func TestLookupFields(t *testing.T) {
+	srv, _ := newTestServer(t, nil)
+	c, _ := New(srv.URL)
+
+	fields, err := c.LookupFields(context.Background(), "8.8.8.8", []string{"country", "currency"})
+	if err != nil {
+		t.Fatalf("LookupFields failed: %v", err)
+	}
+	if fields["country"] != "United States" || fields["currency"] != "USD" {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+	if _, ok := fields["organization"]; ok {
+		t.Error("expected unrequested fields to be omitted")
+	}
+}
+
+func TestLookupBatch(t *testing.T) {
+	srv, _ := newTestServer(t, nil)
+	c, _ := New(srv.URL, WithConcurrency(2))
+
+	ips := []string{"8.8.8.8", "1.1.1.1", "invalid", "9.9.9.9"}
+	results := c.LookupBatch(context.Background(), ips)
+	if len(results) != len(ips) {
+		t.Fatalf("expected %d results, got %d", len(ips), len(results))
+	}
+	for i, res := range results {
+		if res.IP != ips[i] {
+			t.Errorf("result %d: expected IP %s, got %s", i, ips[i], res.IP)
+		}
+		if ips[i] == "invalid" {
+			if res.Err == nil {
+				t.Error("expected an error for the invalid IP")
+			}
+			continue
+		}
+		if res.Err != nil || res.Info.IP != ips[i] {
+			t.Errorf("result %d: unexpected %+v", i, res)
+		}
+	}
+}
+
+func TestLookupBatchConcurrency(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	track := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				peak := maxInFlight.Load()
+				if n <= peak || maxInFlight.CompareAndSwap(peak, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			next.ServeHTTP(w, r)
+		})
+	}
+	srv, _ := newTestServer(t, track)
+	c, _ := New(srv.URL, WithConcurrency(3))
+
+	ips := make([]string, 20)
+	for i := range ips {
+		ips[i] = "8.8.8.8"
+	}
+	for _, res := range c.LookupBatch(context.Background(), ips) {
+		if res.Err != nil {
+			t.Fatalf("unexpected error: %v", res.Err)
+		}
+	}
+	if n := maxInFlight.Load(); n > 3 {
+		t.Errorf("expected at most 3 concurrent requests, got %d", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, res := range c.LookupBatch(ctx, ips) {
+		if !errors.Is(res.Err, context.Canceled) {
+			t.Errorf("expected context.Canceled after cancellation, got %v", res.Err)
+		}
+	}
+}
+
+func TestIPInfoMatchesServer(t *testing.T) {
+	lat, lon, asn, verified, dst := 51.5, -0.12, uint(15169), true, false
+	server := geo.IPInfo{
+		IP: "8.8.8.8", Hostname: "dns.google", Hostnames: []string{"dns.google"}, HostnameVerified: &verified,
+		Country: "United Kingdom", ISOCode: "GB", InEU: true, City: "London", Region: "England",
+		Latitude: &lat, Longitude: &lon, AccuracyRadius: 20,
+		Timezone: "Europe/London", LocalTime: "2026-01-05T10:00:00Z", UTCOffset: "+00:00", IsDST: &dst, TZAbbreviation: "GMT",
+		ASN: &asn, Organization: "Google LLC", NetworkType: "hosting", IsHosting: true,
+		CloudProvider: "gcp", CloudRegion: "europe-west2", CloudService: "compute",
+		Lists: []lists.Match{{Name: "tor", Category: "anonymizer"}}, DatabaseBuild: "2026-01-01", Attribution: geo.Attribution,
+	}
+
+	// Every field the server sends survives decoding into the client type
+	want, _ := json.Marshal(server)
+	var info IPInfo
+	if err := json.Unmarshal(want, &info); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(info)
+	if string(got) != string(want) {
+		t.Errorf("client IPInfo = %s, want %s", got, want)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var calls atomic.Int32
+	flaky := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) <= 2 {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	srv, _ := newTestServer(t, flaky)
+
+	c, _ := New(srv.URL, WithRetries(2), WithBackoff(time.Millisecond, 5*time.Millisecond))
+	if _, err := c.Lookup(context.Background(), "8.8.8.8"); err != nil {
+		t.Fatalf("expected lookup to succeed after retries, got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls.Load())
+	}
+
+	// Client errors are not retried
+	calls.Store(10)
+	c, _ = New(srv.URL, WithRetries(3), WithBackoff(time.Millisecond, time.Millisecond))
+	if _, err := c.Lookup(context.Background(), "8.8.8.8", AsOf(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))); err == nil {
+		t.Fatal("expected a 404 error")
+	}
+	if calls.Load() != 11 {
+		t.Errorf("expected a single attempt for a 404, got %d", calls.Load()-10)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	slow := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	srv, _ := newTestServer(t, slow)
+
+	c, _ := New(srv.URL, WithTimeout(20*time.Millisecond))
+	start := time.Now()
+	_, err := c.Lookup(context.Background(), "8.8.8.8")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline error, got %v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Error("expected the per-request timeout to abort the request")
+	}
+}
+
+func TestCache(t *testing.T) {
+	srv, reader := newTestServer(t, nil)
+	c, _ := New(srv.URL, WithCache(2, time.Minute))
+	ctx := context.Background()
+
+	for _, ip := range []string{"8.8.8.8", "8.8.8.8", "1.1.1.1", "8.8.8.8"} {
+		if _, err := c.Lookup(ctx, ip); err != nil {
+			t.Fatalf("Lookup(%s) failed: %v", ip, err)
+		}
+	}
+	if n := reader.lookups.Load(); n != 2 {
+		t.Errorf("expected 2 server lookups, got %d", n)
+	}
+
+	// A third address evicts the least recently used one (1.1.1.1)
+	c.Lookup(ctx, "9.9.9.9")
+	c.Lookup(ctx, "8.8.8.8")
+	c.Lookup(ctx, "1.1.1.1")
+	if n := reader.lookups.Load(); n != 4 {
+		t.Errorf("expected 4 server lookups after eviction, got %d", n)
+	}
+
+	// The caller's own address is never cached
+	c.Lookup(ctx, "")
+	c.Lookup(ctx, "")
+	if n := reader.lookups.Load(); n != 6 {
+		t.Errorf("expected own-IP lookups to bypass the cache, got %d lookups", n)
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	srv, _ := newTestServer(t, nil)
+	c, _ := New(srv.URL)
+
+	features, err := c.Features(context.Background())
+	if err != nil {
+		t.Fatalf("Features failed: %v", err)
+	}
+	if !features.OnlineFeatures {
+		t.Error("expected online features to be enabled")
+	}
+}
+
+func TestNewInvalidURL(t *testing.T) {
+	if _, err := New("localhost:8080"); err == nil {
+		t.Error("expected a base URL without scheme to fail")
+	}
+}
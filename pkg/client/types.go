@@ -0,0 +1,49 @@
+package client
+
+// IPInfo is the response of a lookup. Fields are documented in the README.
+type IPInfo struct {
+	IP               string      `json:"ip"`
+	Hostname         string      `json:"hostname,omitempty"`
+	Hostnames        []string    `json:"hostnames,omitempty"`
+	HostnameVerified *bool       `json:"hostname_verified,omitempty"`
+	Country          string      `json:"country,omitempty"`
+	ISOCode          string      `json:"iso_code,omitempty"`
+	InEU             bool        `json:"in_eu,omitempty"`
+	City             string      `json:"city,omitempty"`
+	Region           string      `json:"region,omitempty"`
+	Latitude         *float64    `json:"latitude,omitempty"`
+	Longitude        *float64    `json:"longitude,omitempty"`
+	AccuracyRadius   uint16      `json:"accuracy_radius,omitempty"`
+	Timezone         string      `json:"timezone,omitempty"`
+	LocalTime        string      `json:"local_time,omitempty"`
+	UTCOffset        string      `json:"utc_offset,omitempty"`
+	IsDST            *bool       `json:"is_dst,omitempty"`
+	TZAbbreviation   string      `json:"tz_abbreviation,omitempty"`
+	ASN              *uint       `json:"asn,omitempty"`
+	Organization     string      `json:"organization,omitempty"`
+	NetworkType      string      `json:"network_type,omitempty"`
+	IsHosting        bool        `json:"is_hosting,omitempty"`
+	CloudProvider    string      `json:"cloud_provider,omitempty"`
+	CloudRegion      string      `json:"cloud_region,omitempty"`
+	CloudService     string      `json:"cloud_service,omitempty"`
+	Lists            []ListMatch `json:"lists,omitempty"`
+	DatabaseBuild    string      `json:"database_build,omitempty"`
+	Attribution      string      `json:"attribution"`
+}
+
+// ListMatch names a threat list containing the looked up address
+type ListMatch struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+}
+
+// ErrorResponse is the body of an error response
+type ErrorResponse struct {
+	Error       string `json:"error"`
+	Attribution string `json:"attribution"`
+}
+
+// FeaturesResponse reports the server's feature flags
+type FeaturesResponse struct {
+	OnlineFeatures bool `json:"onlineFeatures"`
+}
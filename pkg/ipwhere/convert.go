@@ -0,0 +1,80 @@
+package ipwhere
+
+import (
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+	"github.com/jcjc-dev/ipwhere/internal/lists"
+)
+
+// fromGeo converts an internal lookup result to the public IPInfo. Fields
+// are copied one by one so that changes to the internal type do not change
+// this package's API.
+func fromGeo(info *geo.IPInfo) *IPInfo {
+	out := &IPInfo{
+		IP:               info.IP,
+		Hostname:         info.Hostname,
+		Hostnames:        info.Hostnames,
+		HostnameVerified: info.HostnameVerified,
+		Country:          info.Country,
+		ISOCode:          info.ISOCode,
+		InEU:             info.InEU,
+		City:             info.City,
+		Region:           info.Region,
+		Latitude:         info.Latitude,
+		Longitude:        info.Longitude,
+		AccuracyRadius:   info.AccuracyRadius,
+		Timezone:         info.Timezone,
+		LocalTime:        info.LocalTime,
+		UTCOffset:        info.UTCOffset,
+		IsDST:            info.IsDST,
+		TZAbbreviation:   info.TZAbbreviation,
+		ASN:              info.ASN,
+		Organization:     info.Organization,
+		NetworkType:      info.NetworkType,
+		IsHosting:        info.IsHosting,
+		CloudProvider:    info.CloudProvider,
+		CloudRegion:      info.CloudRegion,
+		CloudService:     info.CloudService,
+		DatabaseBuild:    info.DatabaseBuild,
+		Attribution:      info.Attribution,
+	}
+	for _, m := range info.Lists {
+		out.Lists = append(out.Lists, ListMatch{Name: m.Name, Category: m.Category})
+	}
+	return out
+}
+
+// toGeo converts a public IPInfo back, e.g. for field filtering
+func toGeo(info *IPInfo) *geo.IPInfo {
+	out := &geo.IPInfo{
+		IP:               info.IP,
+		Hostname:         info.Hostname,
+		Hostnames:        info.Hostnames,
+		HostnameVerified: info.HostnameVerified,
+		Country:          info.Country,
+		ISOCode:          info.ISOCode,
+		InEU:             info.InEU,
+		City:             info.City,
+		Region:           info.Region,
+		Latitude:         info.Latitude,
+		Longitude:        info.Longitude,
+		AccuracyRadius:   info.AccuracyRadius,
+		Timezone:         info.Timezone,
+		LocalTime:        info.LocalTime,
+		UTCOffset:        info.UTCOffset,
+		IsDST:            info.IsDST,
+		TZAbbreviation:   info.TZAbbreviation,
+		ASN:              info.ASN,
+		Organization:     info.Organization,
+		NetworkType:      info.NetworkType,
+		IsHosting:        info.IsHosting,
+		CloudProvider:    info.CloudProvider,
+		CloudRegion:      info.CloudRegion,
+		CloudService:     info.CloudService,
+		DatabaseBuild:    info.DatabaseBuild,
+		Attribution:      info.Attribution,
+	}
+	for _, m := range info.Lists {
+		out.Lists = append(out.Lists, lists.Match{Name: m.Name, Category: m.Category})
+	}
+	return out
+}
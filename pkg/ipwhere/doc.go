@@ -0,0 +1,33 @@
+// Package ipwhere embeds ipwhere's IP geolocation in Go programs.
+//
+// It exposes the database reader, the IPInfo result type, field filtering
+// and an HTTP middleware that attaches the lookup result for the client to
+// the request context:
+//
+//	reader, err := ipwhere.Open("dbip-city-lite.mmdb", "dbip-asn-lite.mmdb")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer reader.Close()
+//
+//	mux := http.NewServeMux()
+//	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+//		if info, ok := ipwhere.FromContext(r.Context()); ok {
+//			fmt.Fprintf(w, "Hello from %s\n", info.Country)
+//		}
+//	})
+//	http.ListenAndServe(":8080", ipwhere.Middleware(reader)(mux))
+//
+// # Stability
+//
+// This package follows semantic versioning together with the ipwhere module.
+// Within a major version, exported identifiers are not removed or changed
+// incompatibly. New fields may be added to IPInfo and new options may be
+// added; JSON field names of existing IPInfo fields do not change. Types are
+// declared in this package and converted from the internal ones, so code
+// outside it (internal/...) carries no such guarantee and must not be relied
+// upon.
+//
+// Lookup data is provided by DB-IP under CC BY 4.0; applications that show
+// it must display Attribution.
+package ipwhere
@@ -0,0 +1,146 @@
+package ipwhere
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+
+	"github.com/jcjc-dev/ipwhere/internal/api"
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+)
+
+// IPInfo is the result of a lookup. Fields are documented in the README;
+// FilterFields accepts the same field names as the API's return parameter.
+type IPInfo struct {
+	IP               string      `json:"ip"`
+	Hostname         string      `json:"hostname,omitempty"`
+	Hostnames        []string    `json:"hostnames,omitempty"`
+	HostnameVerified *bool       `json:"hostname_verified,omitempty"`
+	Country          string      `json:"country,omitempty"`
+	ISOCode          string      `json:"iso_code,omitempty"`
+	InEU             bool        `json:"in_eu,omitempty"`
+	City             string      `json:"city,omitempty"`
+	Region           string      `json:"region,omitempty"`
+	Latitude         *float64    `json:"latitude,omitempty"`
+	Longitude        *float64    `json:"longitude,omitempty"`
+	AccuracyRadius   uint16      `json:"accuracy_radius,omitempty"`
+	Timezone         string      `json:"timezone,omitempty"`
+	LocalTime        string      `json:"local_time,omitempty"`
+	UTCOffset        string      `json:"utc_offset,omitempty"`
+	IsDST            *bool       `json:"is_dst,omitempty"`
+	TZAbbreviation   string      `json:"tz_abbreviation,omitempty"`
+	ASN              *uint       `json:"asn,omitempty"`
+	Organization     string      `json:"organization,omitempty"`
+	NetworkType      string      `json:"network_type,omitempty"`
+	IsHosting        bool        `json:"is_hosting,omitempty"`
+	CloudProvider    string      `json:"cloud_provider,omitempty"`
+	CloudRegion      string      `json:"cloud_region,omitempty"`
+	CloudService     string      `json:"cloud_service,omitempty"`
+	Lists            []ListMatch `json:"lists,omitempty"`
+	DatabaseBuild    string      `json:"database_build,omitempty"`
+	Attribution      string      `json:"attribution"`
+}
+
+// ListMatch names a threat list containing the looked up address
+type ListMatch struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+}
+
+// Attribution is the attribution required by the DB-IP license
+const Attribution = geo.Attribution
+
+// Lookuper looks up IP addresses. It is implemented by Reader and may be
+// implemented by callers, e.g. to add caching or for tests.
+type Lookuper interface {
+	Lookup(ip net.IP) (*IPInfo, error)
+}
+
+// ContextLookuper is a Lookuper whose lookups can be bounded by a context.
+// Middleware uses LookupContext with the request context when available.
+type ContextLookuper interface {
+	Lookuper
+	LookupContext(ctx context.Context, ip net.IP) (*IPInfo, error)
+}
+
+// Reader looks up IP addresses in DB-IP (or compatible MaxMind) databases.
+// It is safe for concurrent use.
+type Reader struct {
+	r *geo.Reader
+}
+
+// Option configures a Reader
+type Option func(*options)
+
+type options struct {
+	reverseDNS bool
+}
+
+// WithReverseDNS resolves the hostname of each looked up address. This
+// performs network requests.
+func WithReverseDNS() Option {
+	return func(o *options) {
+		o.reverseDNS = true
+	}
+}
+
+// Open opens the city and ASN databases at the given paths
+func Open(cityDB, asnDB string, opts ...Option) (*Reader, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	r, err := geo.NewReader(cityDB, asnDB, o.reverseDNS)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{r: r}, nil
+}
+
+// Lookup returns geolocation data for ip
+func (r *Reader) Lookup(ip net.IP) (*IPInfo, error) {
+	return r.LookupContext(context.Background(), ip)
+}
+
+// LookupContext is like Lookup; ctx bounds the reverse DNS lookup
+func (r *Reader) LookupContext(ctx context.Context, ip net.IP) (*IPInfo, error) {
+	info, err := r.r.Lookup(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+	return fromGeo(info), nil
+}
+
+// Close releases the databases
+func (r *Reader) Close() error {
+	return r.r.Close()
+}
+
+// FilterFields returns only the requested fields of info, keyed by their
+// JSON names. ip and attribution are always included; unknown names are
+// ignored.
+func FilterFields(info *IPInfo, fields []string) map[string]interface{} {
+	result := toGeo(info).FilterFields(fields)
+	if _, ok := result["lists"]; ok {
+		result["lists"] = info.Lists
+	}
+	return result
+}
+
+// TrustedProxies lists the networks of reverse proxies whose forwarding
+// headers are believed
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies parses IP addresses and CIDR prefixes
+func ParseTrustedProxies(specs []string) (TrustedProxies, error) {
+	t, err := api.ParseTrustedProxies(specs)
+	return TrustedProxies(t), err
+}
+
+// ClientIP returns the address of the client that sent r. Forwarding headers
+// are only used when the peer is one of the trusted proxies; with none, the
+// peer address is returned.
+func ClientIP(r *http.Request, trusted TrustedProxies) string {
+	return api.TrustedProxies(trusted).ClientIP(r)
+}
@@ -0,0 +1,144 @@
+package ipwhere
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/jcjc-dev/ipwhere/internal/geo"
+	"github.com/jcjc-dev/ipwhere/internal/lists"
+)
+
+// mockLookuper returns a fixed country for every address
+type mockLookuper struct {
+	err error
+}
+
+func (m *mockLookuper) Lookup(ip net.IP) (*IPInfo, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &IPInfo{IP: ip.String(), Country: "United States", ISOCode: "US", Attribution: Attribution}, nil
+}
+
+func TestMiddleware(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		opts       []MiddlewareOption
+		remoteAddr string
+		xff        string
+		expectedIP string
+	}{
+		{
+			name:       "peer address",
+			remoteAddr: "8.8.8.8:1234",
+			xff:        "1.2.3.4",
+			expectedIP: "8.8.8.8",
+		},
+		{
+			name:       "trusted proxy",
+			opts:       []MiddlewareOption{WithTrustedProxies(trusted)},
+			remoteAddr: "10.0.0.1:1234",
+			xff:        "8.8.4.4",
+			expectedIP: "8.8.4.4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *IPInfo
+			handler := Middleware(&mockLookuper{}, tt.opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = FromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", tt.xff)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got == nil {
+				t.Fatal("expected a lookup result in the request context")
+			}
+			if got.IP != tt.expectedIP || got.ISOCode != "US" {
+				t.Errorf("unexpected lookup result: %+v", got)
+			}
+		})
+	}
+}
+
+func TestMiddlewareLookupError(t *testing.T) {
+	lookupErr := errors.New("database closed")
+	var reported error
+	called := false
+
+	handler := Middleware(&mockLookuper{err: lookupErr}, WithErrorHandler(func(r *http.Request, err error) {
+		reported = err
+	}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		if _, ok := FromContext(r.Context()); ok {
+			t.Error("expected no lookup result after a failed lookup")
+		}
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !called {
+		t.Error("expected the request to be passed on")
+	}
+	if !errors.Is(reported, lookupErr) {
+		t.Errorf("expected the lookup error to be reported, got %v", reported)
+	}
+}
+
+func TestFilterFields(t *testing.T) {
+	info, _ := (&mockLookuper{}).Lookup(net.ParseIP("8.8.8.8"))
+	result := FilterFields(info, []string{"iso_code", "unknown"})
+
+	if len(result) != 3 || result["iso_code"] != "US" || result["ip"] != "8.8.8.8" || result["attribution"] != Attribution {
+		t.Errorf("unexpected filtered fields: %v", result)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	lat, lon, asn, verified, dst := 51.5, -0.12, uint(15169), true, false
+	info := &geo.IPInfo{
+		IP: "8.8.8.8", Hostname: "dns.google", Hostnames: []string{"dns.google"}, HostnameVerified: &verified,
+		Country: "United Kingdom", ISOCode: "GB", City: "London", Region: "England",
+		Latitude: &lat, Longitude: &lon, AccuracyRadius: 20,
+		Timezone: "Europe/London", LocalTime: "2026-01-05T10:00:00Z", UTCOffset: "+00:00", IsDST: &dst, TZAbbreviation: "GMT",
+		ASN: &asn, Organization: "Google LLC", NetworkType: "hosting", IsHosting: true,
+		CloudProvider: "gcp", CloudRegion: "europe-west2", CloudService: "compute",
+		Lists: []lists.Match{{Name: "tor", Category: "anonymizer"}}, DatabaseBuild: "2026-01-01", Attribution: Attribution,
+	}
+
+	// The public type serializes like the server's
+	want, _ := json.Marshal(info)
+	got, _ := json.Marshal(fromGeo(info))
+	if string(got) != string(want) {
+		t.Errorf("fromGeo() = %s, want %s", got, want)
+	}
+	if back := toGeo(fromGeo(info)); !reflect.DeepEqual(back, info) {
+		t.Errorf("toGeo(fromGeo()) = %+v, want %+v", back, info)
+	}
+
+	result := FilterFields(fromGeo(info), []string{"lists", "capital"})
+	if l, ok := result["lists"].([]ListMatch); !ok || len(l) != 1 || l[0].Name != "tor" || result["capital"] != "London" {
+		t.Errorf("unexpected filtered fields: %v", result)
+	}
+}
+
+func TestOpenMissingDatabase(t *testing.T) {
+	if _, err := Open("missing-city.mmdb", "missing-asn.mmdb"); err == nil {
+		t.Error("expected Open to fail for missing databases")
+	}
+}
@@ -0,0 +1,81 @@
+package ipwhere
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// contextKey is the context key for lookup results
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying info
+func NewContext(ctx context.Context, info *IPInfo) context.Context {
+	return context.WithValue(ctx, contextKey{}, info)
+}
+
+// FromContext returns the lookup result attached by Middleware
+func FromContext(ctx context.Context) (*IPInfo, bool) {
+	info, ok := ctx.Value(contextKey{}).(*IPInfo)
+	return info, ok
+}
+
+// MiddlewareOption configures Middleware
+type MiddlewareOption func(*middleware)
+
+type middleware struct {
+	trusted TrustedProxies
+	onError func(r *http.Request, err error)
+}
+
+// WithTrustedProxies resolves the client IP from forwarding headers set by
+// the given proxies
+func WithTrustedProxies(t TrustedProxies) MiddlewareOption {
+	return func(m *middleware) {
+		m.trusted = t
+	}
+}
+
+// WithErrorHandler is called when a lookup fails. The request is still
+// passed on, without a lookup result in its context.
+func WithErrorHandler(fn func(r *http.Request, err error)) MiddlewareOption {
+	return func(m *middleware) {
+		m.onError = fn
+	}
+}
+
+// Middleware looks up the client IP of each request and attaches the result
+// to the request context, where handlers retrieve it with FromContext
+func Middleware(l Lookuper, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	var m middleware
+	for _, opt := range opts {
+		opt(&m)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := net.ParseIP(ClientIP(r, m.trusted))
+			if ip == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			info, err := lookup(r.Context(), l, ip)
+			if err != nil {
+				if m.onError != nil {
+					m.onError(r, err)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), info)))
+		})
+	}
+}
+
+// lookup uses LookupContext if l supports it
+func lookup(ctx context.Context, l Lookuper, ip net.IP) (*IPInfo, error) {
+	if cl, ok := l.(ContextLookuper); ok {
+		return cl.LookupContext(ctx, ip)
+	}
+	return l.Lookup(ip)
+}